@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/allending313/cs2-demo-parser/internal/broadcast"
+)
+
+// runBroadcastRecord saves a live broadcast's fragments, to replay later
+// with broadcast-replay.
+func runBroadcastRecord(args []string) error {
+	fs := flag.NewFlagSet("broadcast-record", flag.ExitOnError)
+	url := fs.String("url", "", "broadcast URL including the token, e.g. http://localhost:8080/s123t456")
+	out := fs.String("out", "", "recording to write (a .zip)")
+	timeout := fs.Duration("timeout", 30*time.Second, "treat the broadcast as over after this long without a fragment")
+	fs.Parse(args)
+
+	if *url == "" || *out == "" {
+		return errors.New("-url and -out are required")
+	}
+
+	// Stopping with Ctrl-C keeps what was recorded so far.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rec := broadcast.NewRecorder(f)
+	r, err := broadcast.Open(ctx, *url, broadcast.Options{
+		Timeout:    *timeout,
+		OnFragment: rec.Add,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "recording %s from fragment %d\n", r.Sync().Map, r.Sync().Fragment)
+	if _, err := io.Copy(io.Discard, r); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	if err := rec.Finish(r.Sync()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "recording written to %s\n", *out)
+	return nil
+}
+
+// runBroadcastReplay serves a recording as a broadcast relay, a stand-in
+// for a game server when trying live ingest.
+func runBroadcastReplay(args []string) error {
+	fs := flag.NewFlagSet("broadcast-replay", flag.ExitOnError)
+	file := fs.String("file", "", "recording written by broadcast-record")
+	addr := fs.String("addr", "localhost:8080", "address to serve on")
+	speed := fs.Float64("speed", 1, "playback speed")
+	fs.Parse(args)
+
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+
+	rec, err := broadcast.LoadRecording(*file)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "replaying %s (%d fragments) at http://%s/replay\n", rec.Sync.Map, len(rec.Fragments), *addr)
+	return http.ListenAndServe(*addr, broadcast.NewReplay(rec, *speed))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/allending313/cs2-demo-parser/internal/export"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+)
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	matchDir := fs.String("match-dir", envOrDefault("MATCH_DIR", "./data/matches"), "directory of stored matches")
+	tableName := fs.String("table", "", "table to export: "+tableNames())
+	matchIDs := fs.String("matches", "", "comma-separated match IDs (default: all stored matches)")
+	format := fs.String("format", "csv", "output format: csv or ndjson")
+	out := fs.String("out", "", "output file (default: stdout)")
+	fs.Parse(args)
+
+	table, ok := export.LookupTable(*tableName)
+	if !ok {
+		return fmt.Errorf("-table must be one of %s", tableNames())
+	}
+
+	matches, err := store.NewMatchStore(*matchDir)
+	if err != nil {
+		return err
+	}
+
+	ids := util.SplitList(*matchIDs)
+	if len(ids) == 0 {
+		for _, e := range matches.Entries() {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return errors.New("no stored matches to export")
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	rw, err := export.NewRowWriter(*format, w)
+	if err != nil {
+		return err
+	}
+	if err := rw.WriteHeader(table.Columns); err != nil {
+		return err
+	}
+
+	// Load one match at a time so exporting a large store doesn't hold
+	// every match in memory.
+	for _, id := range ids {
+		match, err := matches.Load(id)
+		if err != nil {
+			return err
+		}
+		if err := table.EachRow(match, rw.WriteRow); err != nil {
+			return err
+		}
+	}
+	return rw.Flush()
+}
+
+func tableNames() string {
+	names := make([]string, len(export.Tables))
+	for i, t := range export.Tables {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ", ")
+}
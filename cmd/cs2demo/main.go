@@ -0,0 +1,63 @@
+// Command cs2demo parses demos into MATCH_DIR and runs analyses and exports
+// against the matches stored there, without going through the HTTP server.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"parse", "parse demos into MATCH_DIR", runParse},
+	{"scout", "build a scouting report for a team", runScout},
+	{"winprob-fit", "fit the win probability model on stored matches", runWinProbFit},
+	{"export", "export a table of stored matches as CSV or NDJSON", runExport},
+	{"parquet", "export stored matches as partitioned Parquet files", runParquet},
+	{"vdm", "write a CS2 playback script for rounds or highlights", runVDM},
+	{"broadcast-record", "record a live CS2 broadcast to a file", runBroadcastRecord},
+	{"broadcast-replay", "serve a recorded broadcast like a broadcast relay", runBroadcastReplay},
+	{"token", "generate an API token for the server's auth config", runToken},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: cs2demo <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-17s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
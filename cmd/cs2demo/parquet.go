@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/allending313/cs2-demo-parser/internal/export"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+)
+
+func runParquet(args []string) error {
+	fs := flag.NewFlagSet("parquet", flag.ExitOnError)
+	matchDir := fs.String("match-dir", envOrDefault("MATCH_DIR", "./data/matches"), "directory of stored matches")
+	out := fs.String("out", "./parquet", "output directory")
+	tableList := fs.String("tables", "", "comma-separated tables (default: all): "+tableNames())
+	matchIDs := fs.String("matches", "", "comma-separated match IDs (default: all stored matches)")
+	overwrite := fs.Bool("overwrite", false, "rewrite matches that were already exported")
+	fs.Parse(args)
+
+	tables := export.Tables
+	if names := util.SplitList(*tableList); len(names) > 0 {
+		tables = nil
+		for _, name := range names {
+			t, ok := export.LookupTable(name)
+			if !ok {
+				return fmt.Errorf("unknown table %q", name)
+			}
+			tables = append(tables, t)
+		}
+	}
+
+	matches, err := store.NewMatchStore(*matchDir)
+	if err != nil {
+		return err
+	}
+
+	ids := util.SplitList(*matchIDs)
+	if len(ids) == 0 {
+		for _, e := range matches.Entries() {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return errors.New("no stored matches to export")
+	}
+
+	var exported, skipped, files int
+	for _, id := range ids {
+		pending := tables
+		if !*overwrite {
+			pending = nil
+			for _, t := range tables {
+				if _, err := os.Stat(filepath.Join(*out, t.Name, "match_id="+id)); err != nil {
+					pending = append(pending, t)
+				}
+			}
+		}
+		if len(pending) == 0 {
+			skipped++
+			continue
+		}
+
+		match, err := matches.Load(id)
+		if err != nil {
+			return err
+		}
+		for _, t := range pending {
+			n, err := export.WriteParquetPartitions(*out, t, match)
+			if err != nil {
+				return err
+			}
+			files += n
+		}
+		exported++
+	}
+
+	fmt.Printf("exported %d matches (%d files) to %s, skipped %d already exported\n", exported, files, *out, skipped)
+	return nil
+}
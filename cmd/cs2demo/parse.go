@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	cs2demoparser "github.com/allending313/cs2-demo-parser"
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+	"github.com/allending313/cs2-demo-parser/internal/parser"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+)
+
+func runParse(args []string) error {
+	defaults := models.DefaultParseOptions()
+
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	matchDir := flags.String("match-dir", envOrDefault("MATCH_DIR", "./data/matches"), "directory of stored matches")
+	rate := flags.String("snapshot-rate", fmt.Sprint(defaults.SnapshotRate), `snapshots per second, or "tick" for every tick`)
+	postRound := flags.Float64("post-round", defaults.PostRoundSeconds, "seconds to keep recording after a round is decided")
+	trajectory := flags.Int("trajectory-points", defaults.TrajectoryPoints, "maximum waypoints per grenade (0 keeps all)")
+	eventFamilies := flags.String("events", "", "event families to collect: "+strings.Join(models.EventFamilies, ",")+" (default: all)")
+	winProbPath := flags.String("winprob", os.Getenv("WINPROB_MODEL"), "win probability weights (default: built-in)")
+	workspace := flags.String("workspace", "", "workspace the matches belong to, when the server uses authentication")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: cs2demo parse [flags] demo.dem...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return errors.New("no demo files given")
+	}
+
+	opts := models.ParseOptions{
+		PostRoundSeconds: *postRound,
+		TrajectoryPoints: *trajectory,
+		Events:           util.SplitList(*eventFamilies),
+	}
+	var err error
+	if opts.SnapshotRate, err = models.ParseSnapshotRate(*rate); err != nil {
+		return err
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	matches, err := store.NewMatchStore(*matchDir)
+	if err != nil {
+		return err
+	}
+
+	winProb := &analysis.DefaultWinProbModel
+	if *winProbPath != "" {
+		if winProb, err = analysis.LoadWinProbModel(*winProbPath); err != nil {
+			return err
+		}
+	}
+
+	mapsFS, err := fs.Sub(cs2demoparser.MapsFS, "assets/maps")
+	if err != nil {
+		return err
+	}
+	mapConfigs, err := models.LoadMapConfigs(mapsFS, "configs")
+	if err != nil {
+		return err
+	}
+
+	for _, path := range flags.Args() {
+		match, err := parser.ParseDemo(path, util.GenerateID(), opts, parser.Hooks{})
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		match.MapConfig = mapConfigs[match.Map]
+		match.Workspace = *workspace
+		analysis.AnnotateMatch(match, winProb)
+
+		if err := matches.Save(match); err != nil {
+			return err
+		}
+		fmt.Printf("%s: stored as %s (%s, %d rounds)\n", path, match.ID, match.Map, len(match.Rounds))
+	}
+	return nil
+}
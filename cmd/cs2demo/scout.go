@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+)
+
+func runScout(args []string) error {
+	fs := flag.NewFlagSet("scout", flag.ExitOnError)
+	matchDir := fs.String("match-dir", envOrDefault("MATCH_DIR", "./data/matches"), "directory of stored matches")
+	teamName := fs.String("team", "", "team (clan) name")
+	players := fs.String("players", "", "comma-separated SteamIDs of the team's roster")
+	matchIDs := fs.String("matches", "", "comma-separated match IDs (default: every match the team played)")
+	format := fs.String("format", "markdown", "output format: markdown or json")
+	fs.Parse(args)
+
+	team := analysis.TeamSelector{Name: *teamName}
+	for _, field := range util.SplitList(*players) {
+		id, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid steam id %q", field)
+		}
+		team.Players = append(team.Players, id)
+	}
+	if team.Name == "" && len(team.Players) == 0 {
+		return errors.New("-team or -players is required")
+	}
+
+	matches, err := store.NewMatchStore(*matchDir)
+	if err != nil {
+		return err
+	}
+
+	ids := util.SplitList(*matchIDs)
+	if len(ids) == 0 {
+		for _, e := range matches.EntriesWhere(func(e *store.IndexEntry) bool { return team.PlayedIn(e.Teams) }) {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return errors.New("no matches found for team")
+	}
+
+	loaded, err := matches.LoadAll(ids)
+	if err != nil {
+		return err
+	}
+
+	report := analysis.BuildScoutingReport(team, loaded)
+
+	switch *format {
+	case "markdown":
+		_, err = os.Stdout.WriteString(report.Markdown())
+		return err
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
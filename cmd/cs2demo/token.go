@@ -0,0 +1,21 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/allending313/cs2-demo-parser/internal/auth"
+)
+
+// runToken prints a random token to paste into the server's AUTH_CONFIG.
+func runToken(args []string) error {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	fs.Parse(args)
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
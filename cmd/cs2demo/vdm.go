@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	"github.com/allending313/cs2-demo-parser/internal/export"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+)
+
+func runVDM(args []string) error {
+	fs := flag.NewFlagSet("vdm", flag.ExitOnError)
+	matchDir := fs.String("match-dir", envOrDefault("MATCH_DIR", "./data/matches"), "directory of stored matches")
+	matchID := fs.String("match", "", "match ID")
+	rounds := fs.String("rounds", "", "rounds to play, e.g. 3-5,9")
+	player := fs.String("player", "", "SteamID to spectate during -rounds")
+	highlights := fs.String("highlights", "", `"all" or 1-based highlight ranks, e.g. 1,3`)
+	kind := fs.String("kind", "", "only use highlights of this kind")
+	limit := fs.Int("limit", 0, "maximum number of highlights to use")
+	out := fs.String("out", "", "output file (default: stdout); name it after the demo")
+	fs.Parse(args)
+
+	if *matchID == "" {
+		return errors.New("-match is required")
+	}
+
+	matches, err := store.NewMatchStore(*matchDir)
+	if err != nil {
+		return err
+	}
+	match, err := matches.Load(*matchID)
+	if err != nil {
+		return err
+	}
+
+	var segments []export.Segment
+	switch {
+	case *rounds != "":
+		numbers, err := export.ParseRanges(*rounds, export.LastRound(match))
+		if err != nil {
+			return fmt.Errorf("invalid -rounds: %w", err)
+		}
+		var steamID uint64
+		if *player != "" {
+			if steamID, err = strconv.ParseUint(*player, 10, 64); err != nil {
+				return fmt.Errorf("invalid steam id %q", *player)
+			}
+		}
+		segments = export.RoundSegments(match, numbers, steamID)
+
+	case *highlights != "":
+		selected := analysis.FilterHighlights(analysis.Highlights(match), *kind, *limit)
+		if *highlights != "all" {
+			ranks, err := export.ParseRanges(*highlights, len(selected))
+			if err != nil {
+				return fmt.Errorf("invalid -highlights: %w", err)
+			}
+			if selected, err = export.SelectRanks(selected, ranks); err != nil {
+				return err
+			}
+		}
+		segments = export.HighlightSegments(selected)
+
+	default:
+		return errors.New("-rounds or -highlights is required")
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return export.WriteVDM(w, match, segments)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+)
+
+func runWinProbFit(args []string) error {
+	fs := flag.NewFlagSet("winprob-fit", flag.ExitOnError)
+	matchDir := fs.String("match-dir", envOrDefault("MATCH_DIR", "./data/matches"), "directory of stored matches")
+	matchIDs := fs.String("matches", "", "comma-separated match IDs (default: all stored matches)")
+	out := fs.String("out", "winprob.json", "where to write the fitted weights")
+	fs.Parse(args)
+
+	matches, err := store.NewMatchStore(*matchDir)
+	if err != nil {
+		return err
+	}
+
+	ids := util.SplitList(*matchIDs)
+	if len(ids) == 0 {
+		for _, e := range matches.Entries() {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return errors.New("no stored matches to fit on")
+	}
+
+	loaded, err := matches.LoadAll(ids)
+	if err != nil {
+		return err
+	}
+
+	model, samples := analysis.FitWinProbModel(loaded)
+	if err := analysis.SaveWinProbModel(*out, model); err != nil {
+		return err
+	}
+
+	fmt.Printf("fitted on %d snapshots from %d matches, wrote %s\n", samples, len(loaded), *out)
+	fmt.Println("set WINPROB_MODEL to this file to use it in the server")
+	return nil
+}
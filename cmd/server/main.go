@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	cs2demoparser "github.com/allending313/cs2-demo-parser"
+	"github.com/allending313/cs2-demo-parser/internal/auth"
 	"github.com/allending313/cs2-demo-parser/internal/server"
+	"github.com/allending313/cs2-demo-parser/internal/util"
 )
 
 func main() {
@@ -28,24 +38,125 @@ func main() {
 		os.Exit(1)
 	}
 
+	uploadExpiry, err := time.ParseDuration(envOrDefault("UPLOAD_EXPIRY", "24h"))
+	if err != nil {
+		logger.Error("invalid UPLOAD_EXPIRY", "error", err)
+		os.Exit(1)
+	}
+
+	// How long a shutdown waits for uploads and parses to finish. Keep it
+	// below the time the process manager allows before killing the server.
+	drainTimeout, err := time.ParseDuration(envOrDefault("DRAIN_TIMEOUT", "30s"))
+	if err != nil {
+		logger.Error("invalid DRAIN_TIMEOUT", "error", err)
+		os.Exit(1)
+	}
+
+	// Without AUTH_CONFIG the API is open to anyone who can reach it.
+	var authConfig *auth.Config
+	if path := os.Getenv("AUTH_CONFIG"); path != "" {
+		if authConfig, err = auth.LoadConfig(path); err != nil {
+			logger.Error("failed to load auth config", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Per-client limits; 0 turns one off.
+	limits := server.Limits{TrustProxy: os.Getenv("TRUST_PROXY") == "true"}
+	for _, l := range []struct {
+		key, fallback string
+		value         *int
+	}{
+		{"RATE_LIMIT", "600", &limits.RequestsPerMinute},
+		{"UPLOAD_RATE_LIMIT", "30", &limits.UploadsPerHour},
+		{"MAX_JOBS_PER_CLIENT", "4", &limits.JobsPerClient},
+	} {
+		n, err := strconv.Atoi(envOrDefault(l.key, l.fallback))
+		if err != nil || n < 0 {
+			logger.Error("invalid "+l.key, "value", os.Getenv(l.key))
+			os.Exit(1)
+		}
+		*l.value = n
+	}
+	if v := os.Getenv("WORKSPACE_QUOTA"); v != "" {
+		if limits.WorkspaceQuota, err = parseSize(v); err != nil {
+			logger.Error("invalid WORKSPACE_QUOTA", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	srv, err := server.New(server.Config{
 		UploadDir: envOrDefault("UPLOAD_DIR", "./data/uploads"),
 		MatchDir:  envOrDefault("MATCH_DIR", "./data/matches"),
 		WebFS:     webFS,
 		MapsFS:    mapsFS,
+
+		WinProbModelPath: os.Getenv("WINPROB_MODEL"),
+		UploadExpiry:     uploadExpiry,
+		Auth:             authConfig,
+		Limits:           limits,
+		LiveRelayHosts:   util.SplitList(os.Getenv("LIVE_RELAY_HOSTS")),
+		MetricsToken:     os.Getenv("METRICS_TOKEN"),
 	}, logger)
 	if err != nil {
 		logger.Error("failed to initialize server", "error", err)
 		os.Exit(1)
 	}
 
-	handler := server.CORSMiddleware(srv)
+	handler := server.CORSMiddleware(srv, util.SplitList(os.Getenv("ALLOWED_ORIGINS")))
 
-	logger.Info("server starting", "port", port)
-	if err := http.ListenAndServe(fmt.Sprintf(":%s", port), handler); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Request contexts are cancelled as the shutdown starts, which ends
+	// event streams instead of letting them hold it up. Uploads don't
+	// watch the context and get to finish.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	httpServer := &http.Server{
+		Addr:        fmt.Sprintf(":%s", port),
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	httpServer.RegisterOnShutdown(cancelRequests)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("server starting", "port", port)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		logger.Error("server error", "error", err)
 		os.Exit(1)
+	case <-ctx.Done():
+	}
+	// A second signal kills the server straight away.
+	stop()
+
+	logger.Info("shutting down", "drainTimeout", drainTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	// Parse jobs drain alongside the requests still running, since an
+	// upload in flight is also a parse in progress.
+	httpDone := make(chan struct{})
+	go func() {
+		defer close(httpDone)
+		if err := httpServer.Shutdown(drainCtx); err != nil {
+			logger.Warn("closing requests still running", "error", err)
+			httpServer.Close()
+		}
+	}()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		logger.Warn("shutdown incomplete", "error", err)
 	}
+	<-httpDone
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("server error", "error", err)
+	}
+	logger.Info("server stopped")
 }
 
 func envOrDefault(key, fallback string) string {
@@ -54,3 +165,21 @@ func envOrDefault(key, fallback string) string {
 	}
 	return fallback
 }
+
+// parseSize reads a size in bytes, optionally with a KB, MB, GB or TB
+// suffix (powers of 1024), e.g. "20GB".
+func parseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for i, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		if n, ok := strings.CutSuffix(v, suffix); ok {
+			v, mult = strings.TrimSpace(n), 1<<(10*(i+1))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(v, "B"), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
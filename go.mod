@@ -2,7 +2,10 @@ module github.com/allending313/cs2-demo-parser
 
 go 1.25.6
 
-require github.com/markus-wa/demoinfocs-golang/v5 v5.1.2
+require (
+	github.com/klauspost/compress v1.20.1
+	github.com/markus-wa/demoinfocs-golang/v5 v5.1.2
+)
 
 require (
 	github.com/golang/geo v0.0.0-20230421003525-6adc56603217 // indirect
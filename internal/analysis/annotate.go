@@ -0,0 +1,11 @@
+package analysis
+
+import models "github.com/allending313/cs2-demo-parser/internal/model"
+
+// AnnotateMatch runs the analyses whose results are stored with a freshly
+// parsed match: the win probability series, trades and clutches.
+func AnnotateMatch(match *models.Match, winProb *WinProbModel) {
+	winProb.AttachWinProb(match)
+	MarkTrades(match, DefaultTradeWindow)
+	DetectClutches(match)
+}
@@ -0,0 +1,234 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// Advantage is a man-advantage state (e.g. 5v4) reached during a round and
+// whether the side holding it went on to win.
+type Advantage struct {
+	Round       int     `json:"round"`
+	Side        string  `json:"side"`
+	State       string  `json:"state"`
+	TimeInRound float64 `json:"timeInRound"`
+	Won         bool    `json:"won"`
+}
+
+// AdvantageSummary is how often a man-advantage state was converted.
+type AdvantageSummary struct {
+	State       string  `json:"state"`
+	Occurrences int     `json:"occurrences"`
+	Converted   int     `json:"converted"`
+	Rate        float64 `json:"rate"`
+}
+
+type ClutchRecord struct {
+	Attempts int `json:"attempts"`
+	Won      int `json:"won"`
+}
+
+type PlayerClutchStats struct {
+	SteamID  uint64                   `json:"steamId"`
+	Attempts int                      `json:"attempts"`
+	Won      int                      `json:"won"`
+	Kills    int                      `json:"kills"`
+	ByState  map[string]*ClutchRecord `json:"byState"`
+}
+
+// RoundClutch is a clutch along with the round it happened in.
+type RoundClutch struct {
+	Round int `json:"round"`
+	models.Clutch
+}
+
+// DetectClutches sets the Clutch of every round in the match.
+func DetectClutches(match *models.Match) {
+	for i := range match.Rounds {
+		match.Rounds[i].Clutch, _ = analyzeAliveCounts(&match.Rounds[i])
+	}
+}
+
+// analyzeAliveCounts replays the round's kills against the players alive at
+// its start. It returns the round's clutch, if any, and every man-advantage
+// state that arose from a kill.
+//
+// Only the first side to be reduced to a single player is clutching: if the
+// other side is later also down to one, that 1v1 is part of the same clutch.
+func analyzeAliveCounts(r *models.Round) (*models.Clutch, []Advantage) {
+	sides := RoundSides(r)
+	alive := initialAlive(r, sides)
+
+	counts := make(map[string]int)
+	for id := range alive {
+		counts[sides[id]]++
+	}
+
+	var clutch *models.Clutch
+	var advantages []Advantage
+	seen := make(map[string]bool)
+
+	for _, k := range r.Kills {
+		side, ok := sides[k.Victim]
+		if !ok || !alive[k.Victim] {
+			continue
+		}
+		delete(alive, k.Victim)
+		counts[side]--
+		enemy := opponentSide(side)
+
+		if clutch != nil && clutch.Survived {
+			if k.Victim == clutch.Player {
+				clutch.Survived = false
+				clutch.EndTime = k.TimeInRound
+			} else if k.Attacker == clutch.Player && side != clutch.Side {
+				clutch.Kills++
+			}
+		}
+
+		if clutch == nil && counts[side] == 1 && counts[enemy] >= 1 {
+			for id := range alive {
+				if sides[id] == side {
+					clutch = &models.Clutch{
+						Player:    id,
+						Side:      side,
+						Opponents: counts[enemy],
+						Survived:  true,
+						StartTick: k.Tick,
+						StartTime: k.TimeInRound,
+					}
+				}
+			}
+		}
+
+		leader, trailer := "ct", "t"
+		if counts["t"] > counts["ct"] {
+			leader, trailer = trailer, leader
+		}
+		if counts[trailer] > 0 && counts[leader] > counts[trailer] {
+			state := fmt.Sprintf("%dv%d", counts[leader], counts[trailer])
+			if key := leader + state; !seen[key] {
+				seen[key] = true
+				advantages = append(advantages, Advantage{
+					Round:       r.Number,
+					Side:        leader,
+					State:       state,
+					TimeInRound: k.TimeInRound,
+					Won:         r.Winner == leader,
+				})
+			}
+		}
+	}
+
+	if clutch != nil {
+		clutch.Won = r.Winner == clutch.Side
+		if clutch.Survived {
+			clutch.EndTime = roundEndTime(r)
+		}
+	}
+	return clutch, advantages
+}
+
+// initialAlive returns the players alive in the first snapshot each of them
+// appears in.
+func initialAlive(r *models.Round, sides map[uint64]string) map[uint64]bool {
+	alive := make(map[uint64]bool)
+	seen := make(map[uint64]bool)
+	for _, snap := range r.Snapshots {
+		for _, ps := range snap.Players {
+			if seen[ps.SteamID] || sides[ps.SteamID] == "" {
+				continue
+			}
+			seen[ps.SteamID] = true
+			if ps.IsAlive {
+				alive[ps.SteamID] = true
+			}
+		}
+	}
+	return alive
+}
+
+// Clutches lists every clutch in the match in round order.
+func Clutches(match *models.Match) []RoundClutch {
+	var out []RoundClutch
+	for i := range match.Rounds {
+		r := &match.Rounds[i]
+		if clutch, _ := analyzeAliveCounts(r); clutch != nil {
+			out = append(out, RoundClutch{Round: r.Number, Clutch: *clutch})
+		}
+	}
+	return out
+}
+
+// Advantages lists every man-advantage state reached in the match, and
+// summarizes how often each state was converted into a round win.
+func Advantages(match *models.Match) ([]Advantage, []AdvantageSummary) {
+	var all []Advantage
+	summaries := make(map[string]*AdvantageSummary)
+
+	for i := range match.Rounds {
+		_, advantages := analyzeAliveCounts(&match.Rounds[i])
+		for _, a := range advantages {
+			all = append(all, a)
+			s, ok := summaries[a.State]
+			if !ok {
+				s = &AdvantageSummary{State: a.State}
+				summaries[a.State] = s
+			}
+			s.Occurrences++
+			if a.Won {
+				s.Converted++
+			}
+		}
+	}
+
+	out := make([]AdvantageSummary, 0, len(summaries))
+	for _, s := range summaries {
+		s.Rate = ratio(s.Converted, s.Occurrences)
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].State > out[j].State })
+	return all, out
+}
+
+// ClutchStats aggregates clutches per player, sorted by SteamID.
+func ClutchStats(clutches []RoundClutch) []PlayerClutchStats {
+	stats := make(map[uint64]*PlayerClutchStats)
+	for _, c := range clutches {
+		s, ok := stats[c.Player]
+		if !ok {
+			s = &PlayerClutchStats{SteamID: c.Player, ByState: make(map[string]*ClutchRecord)}
+			stats[c.Player] = s
+		}
+		state := fmt.Sprintf("1v%d", c.Opponents)
+		rec, ok := s.ByState[state]
+		if !ok {
+			rec = &ClutchRecord{}
+			s.ByState[state] = rec
+		}
+
+		s.Attempts++
+		rec.Attempts++
+		s.Kills += c.Kills
+		if c.Won {
+			s.Won++
+			rec.Won++
+		}
+	}
+
+	out := make([]PlayerClutchStats, 0, len(stats))
+	for _, s := range stats {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SteamID < out[j].SteamID })
+	return out
+}
+
+func opponentSide(side string) string {
+	if side == "ct" {
+		return "t"
+	}
+	return "ct"
+}
@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestAnalyzeAliveCounts(t *testing.T) {
+	// CT 1, 2, 3 vs T 4, 5, 6. CT 3 is left alone against three and wins.
+	var players []models.PlayerState
+	for id := uint64(1); id <= 6; id++ {
+		team := "ct"
+		if id > 3 {
+			team = "t"
+		}
+		players = append(players, models.PlayerState{SteamID: id, Team: team, IsAlive: true})
+	}
+
+	r := &models.Round{
+		Number:    4,
+		Winner:    "ct",
+		EndTime:   60,
+		Snapshots: []models.Snapshot{{Players: players}},
+		Kills: []models.KillEvent{
+			{TimeInRound: 10, Attacker: 4, Victim: 1},
+			{TimeInRound: 20, Tick: 1280, Attacker: 5, Victim: 2},
+			{TimeInRound: 25, Attacker: 3, Victim: 4},
+			{TimeInRound: 30, Attacker: 3, Victim: 5},
+			{TimeInRound: 40, Attacker: 3, Victim: 6},
+		},
+	}
+
+	clutch, advantages := analyzeAliveCounts(r)
+	if clutch == nil {
+		t.Fatal("expected a clutch")
+	}
+
+	want := models.Clutch{
+		Player:    3,
+		Side:      "ct",
+		Opponents: 3,
+		Won:       true,
+		Survived:  true,
+		Kills:     3,
+		StartTick: 1280,
+		StartTime: 20,
+		EndTime:   60,
+	}
+	if *clutch != want {
+		t.Errorf("clutch = %+v, want %+v", *clutch, want)
+	}
+
+	states := make(map[string]bool)
+	for _, a := range advantages {
+		states[a.Side+" "+a.State] = a.Won
+	}
+	if len(advantages) != 3 {
+		t.Errorf("expected 3 advantage states, got %+v", advantages)
+	}
+	for _, s := range []string{"t 3v2", "t 3v1", "t 2v1"} {
+		if won, ok := states[s]; !ok || won {
+			t.Errorf("expected unconverted advantage %q, got %+v", s, advantages)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+const (
+	// Padding around the action so a clip doesn't start on the first shot.
+	highlightLeadIn  = 3.0
+	highlightLeadOut = 2.0
+
+	// A multi-kill is "fast" if it averages at most this many seconds per
+	// kill after the first.
+	fastKillInterval = 1.5
+
+	defaultTickRate = 64.0
+)
+
+// Highlight kinds.
+const (
+	HighlightMultiKill   = "multikill"
+	HighlightClutch      = "clutch"
+	HighlightSpecialKill = "special_kill"
+	HighlightNinjaDefuse = "ninja_defuse"
+)
+
+// Highlight is a moment worth clipping, with enough timing information to
+// jump to it in the viewer or in-game.
+type Highlight struct {
+	Round       int      `json:"round"`
+	Kind        string   `json:"kind"`
+	StartTime   float64  `json:"startTime"`
+	EndTime     float64  `json:"endTime"`
+	StartTick   int      `json:"startTick"`
+	EndTick     int      `json:"endTick"`
+	Players     []uint64 `json:"players"`
+	Description string   `json:"description"`
+	Score       float64  `json:"score"`
+}
+
+// Highlights scans every round of the match and returns its highlights,
+// best first.
+func Highlights(match *models.Match) []Highlight {
+	names := playerNames(match)
+	tickRate := match.TickRate
+	if tickRate <= 0 {
+		tickRate = defaultTickRate
+	}
+
+	var out []Highlight
+	for i := range match.Rounds {
+		r := &match.Rounds[i]
+		clock := newRoundClock(r, tickRate)
+
+		var found []Highlight
+		found = append(found, multiKillHighlights(r, names)...)
+		found = append(found, specialKillHighlights(r, names)...)
+		if h, ok := clutchHighlight(r, names); ok {
+			found = append(found, h)
+		}
+		if h, ok := ninjaDefuseHighlight(r, names); ok {
+			found = append(found, h)
+		}
+
+		for _, h := range found {
+			h.Round = r.Number
+			h.StartTime = math.Max(0, h.StartTime-highlightLeadIn)
+			h.EndTime += highlightLeadOut
+			h.StartTick = clock.tick(h.StartTime)
+			h.EndTick = clock.tick(h.EndTime)
+			out = append(out, h)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Round < out[j].Round
+	})
+	return out
+}
+
+// FilterHighlights keeps highlights of the given kind, or all of them if
+// kind is empty, and truncates the result to limit if it is positive.
+func FilterHighlights(highlights []Highlight, kind string, limit int) []Highlight {
+	out := make([]Highlight, 0, len(highlights))
+	for _, h := range highlights {
+		if kind == "" || h.Kind == kind {
+			out = append(out, h)
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func multiKillHighlights(r *models.Round, names map[uint64]string) []Highlight {
+	sides := RoundSides(r)
+	byPlayer := make(map[uint64][]models.KillEvent)
+	var order []uint64
+	for _, k := range r.Kills {
+		if k.Attacker == 0 || sides[k.Attacker] == sides[k.Victim] {
+			continue
+		}
+		if _, ok := byPlayer[k.Attacker]; !ok {
+			order = append(order, k.Attacker)
+		}
+		byPlayer[k.Attacker] = append(byPlayer[k.Attacker], k)
+	}
+
+	var out []Highlight
+	for _, id := range order {
+		kills := byPlayer[id]
+		n := len(kills)
+		first, last := kills[0].TimeInRound, kills[n-1].TimeInRound
+		span := last - first
+		fast := n >= 2 && span <= fastKillInterval*float64(n-1)
+
+		var label string
+		var score float64
+		switch {
+		case n >= 5:
+			label, score = "ace", 100
+		case n == 4:
+			label, score = "4k", 70
+		case n == 3:
+			label, score = "3k", 40
+		case n == 2 && fast:
+			label, score = "double kill", 12
+		default:
+			continue
+		}
+		if fast {
+			label = "fast " + label
+			score *= 1.3
+		}
+
+		out = append(out, Highlight{
+			Kind:        HighlightMultiKill,
+			StartTime:   first,
+			EndTime:     last,
+			Players:     []uint64{id},
+			Description: fmt.Sprintf("%s: %s with %s in %.1fs", displayName(names, id), label, weaponList(kills), span),
+			Score:       math.Round(score*10) / 10,
+		})
+	}
+	return out
+}
+
+func specialKillHighlights(r *models.Round, names map[uint64]string) []Highlight {
+	var out []Highlight
+	for _, k := range r.Kills {
+		var traits []string
+		score := 0.0
+		if k.NoScope {
+			traits = append(traits, "no-scope")
+			score += 15
+		}
+		if k.Wallbang {
+			traits = append(traits, "wallbang")
+			score += 12
+		}
+		if k.ThroughSmoke {
+			traits = append(traits, "through smoke")
+			score += 10
+		}
+		if k.AttackerBlind {
+			traits = append(traits, "while blind")
+			score += 10
+		}
+		if len(traits) == 0 || k.Attacker == 0 {
+			continue
+		}
+		if k.Headshot {
+			traits = append(traits, "headshot")
+			score += 3
+		}
+
+		out = append(out, Highlight{
+			Kind:        HighlightSpecialKill,
+			StartTime:   k.TimeInRound,
+			EndTime:     k.TimeInRound,
+			Players:     []uint64{k.Attacker, k.Victim},
+			Description: fmt.Sprintf("%s kills %s with %s (%s)", displayName(names, k.Attacker), displayName(names, k.Victim), k.Weapon, strings.Join(traits, ", ")),
+			Score:       score,
+		})
+	}
+	return out
+}
+
+func clutchHighlight(r *models.Round, names map[uint64]string) (Highlight, bool) {
+	clutch, _ := analyzeAliveCounts(r)
+	if clutch == nil || !clutch.Won {
+		return Highlight{}, false
+	}
+
+	return Highlight{
+		Kind:        HighlightClutch,
+		StartTime:   clutch.StartTime,
+		EndTime:     clutch.EndTime,
+		Players:     []uint64{clutch.Player},
+		Description: fmt.Sprintf("%s wins a 1v%d clutch with %d kill(s)", displayName(names, clutch.Player), clutch.Opponents, clutch.Kills),
+		Score:       25 + 15*float64(clutch.Opponents),
+	}, true
+}
+
+// ninjaDefuseHighlight finds defuses completed while Terrorists were still
+// alive to stop them.
+func ninjaDefuseHighlight(r *models.Round, names map[uint64]string) (Highlight, bool) {
+	if r.Defuse == nil {
+		return Highlight{}, false
+	}
+
+	sides := RoundSides(r)
+	alive := initialAlive(r, sides)
+	for _, k := range r.Kills {
+		if k.Tick <= r.Defuse.Tick {
+			delete(alive, k.Victim)
+		}
+	}
+	tAlive := 0
+	for id := range alive {
+		if sides[id] == "t" {
+			tAlive++
+		}
+	}
+	if tAlive == 0 {
+		return Highlight{}, false
+	}
+
+	start := r.Defuse.TimeInRound - defuseSeconds
+	if plant := roundPlant(r); plant != nil {
+		start = math.Max(start, plant.TimeInRound)
+	}
+
+	return Highlight{
+		Kind:        HighlightNinjaDefuse,
+		StartTime:   start,
+		EndTime:     r.Defuse.TimeInRound,
+		Players:     []uint64{r.Defuse.Player},
+		Description: fmt.Sprintf("%s ninja defuses with %d T alive", displayName(names, r.Defuse.Player), tAlive),
+		Score:       50 + 10*float64(tAlive),
+	}, true
+}
+
+// roundClock converts seconds after freeze time to demo ticks.
+type roundClock struct {
+	startTick int
+	tickRate  float64
+}
+
+func newRoundClock(r *models.Round, tickRate float64) roundClock {
+	c := roundClock{tickRate: tickRate}
+	if len(r.Snapshots) > 0 {
+		s := r.Snapshots[0]
+		c.startTick = s.Tick - int(math.Round(s.TimeInRound*tickRate))
+	} else if len(r.Kills) > 0 {
+		k := r.Kills[0]
+		c.startTick = k.Tick - int(math.Round(k.TimeInRound*tickRate))
+	}
+	return c
+}
+
+func (c roundClock) tick(t float64) int {
+	return c.startTick + int(math.Round(t*c.tickRate))
+}
+
+func playerNames(match *models.Match) map[uint64]string {
+	names := make(map[uint64]string)
+	for _, team := range []models.TeamInfo{match.Teams.CT, match.Teams.T} {
+		for _, p := range team.Players {
+			names[p.SteamID] = p.Name
+		}
+	}
+	return names
+}
+
+func displayName(names map[uint64]string, id uint64) string {
+	if name := names[id]; name != "" {
+		return name
+	}
+	return fmt.Sprintf("%d", id)
+}
+
+func weaponList(kills []models.KillEvent) string {
+	seen := make(map[string]bool)
+	var weapons []string
+	for _, k := range kills {
+		if !seen[k.Weapon] {
+			seen[k.Weapon] = true
+			weapons = append(weapons, k.Weapon)
+		}
+	}
+	return strings.Join(weapons, "/")
+}
@@ -0,0 +1,153 @@
+package analysis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestHighlights(t *testing.T) {
+	// CT 1, 2, 3 vs T 4, 5, 6.
+	snap := func(tick int, timeInRound float64) models.Snapshot {
+		s := models.Snapshot{Tick: tick, TimeInRound: timeInRound}
+		for id := uint64(1); id <= 6; id++ {
+			team := "ct"
+			if id > 3 {
+				team = "t"
+			}
+			s.Players = append(s.Players, models.PlayerState{SteamID: id, Team: team, IsAlive: true})
+		}
+		return s
+	}
+
+	match := &models.Match{
+		TickRate: 64,
+		Teams:    models.Teams{CT: models.TeamInfo{Players: []models.PlayerInfo{{SteamID: 3, Name: "three"}}}},
+		Rounds: []models.Round{
+			{
+				// Freeze time ended at tick 872. CT 3 is left alone against
+				// three and wins with a quick triple, opening with a
+				// no-scope.
+				Number:    4,
+				Winner:    "ct",
+				EndTime:   30,
+				Snapshots: []models.Snapshot{snap(1000, 2)},
+				Kills: []models.KillEvent{
+					{TimeInRound: 10, Tick: 1512, Attacker: 4, Victim: 1, Weapon: "ak47"},
+					{TimeInRound: 20, Tick: 2152, Attacker: 5, Victim: 2, Weapon: "ak47"},
+					{TimeInRound: 25, Tick: 2472, Attacker: 3, Victim: 4, Weapon: "awp", NoScope: true, Headshot: true},
+					{TimeInRound: 26, Tick: 2536, Attacker: 3, Victim: 5, Weapon: "awp"},
+					{TimeInRound: 27, Tick: 2600, Attacker: 3, Victim: 6, Weapon: "deagle"},
+				},
+			},
+			{
+				// CT 3 defuses with T 4 and 6 still alive; T 6 only dies
+				// afterwards. CT 2's two kills are too far apart to be a
+				// highlight.
+				Number:    5,
+				Winner:    "ct",
+				Snapshots: []models.Snapshot{snap(5000, 0)},
+				Plant:     &models.BombEvent{TimeInRound: 40, Tick: 7560, Player: 4},
+				Defuse:    &models.BombEvent{TimeInRound: 45, Tick: 7880, Player: 3},
+				Kills: []models.KillEvent{
+					{TimeInRound: 30, Tick: 6920, Attacker: 4, Victim: 1, Weapon: "ak47"},
+					{TimeInRound: 35, Tick: 7240, Attacker: 2, Victim: 5, Weapon: "m4a1"},
+					{TimeInRound: 50, Tick: 8200, Attacker: 2, Victim: 6, Weapon: "m4a1"},
+				},
+			},
+		},
+	}
+
+	want := []Highlight{
+		{Round: 4, Kind: HighlightClutch, StartTime: 17, EndTime: 32, StartTick: 1960, EndTick: 2920,
+			Players: []uint64{3}, Description: "three wins a 1v3 clutch with 3 kill(s)", Score: 70},
+		{Round: 5, Kind: HighlightNinjaDefuse, StartTime: 37, EndTime: 47, StartTick: 7368, EndTick: 8008,
+			Players: []uint64{3}, Description: "three ninja defuses with 2 T alive", Score: 70},
+		{Round: 4, Kind: HighlightMultiKill, StartTime: 22, EndTime: 29, StartTick: 2280, EndTick: 2728,
+			Players: []uint64{3}, Description: "three: fast 3k with awp/deagle in 2.0s", Score: 52},
+		{Round: 4, Kind: HighlightSpecialKill, StartTime: 22, EndTime: 27, StartTick: 2280, EndTick: 2600,
+			Players: []uint64{3, 4}, Description: "three kills 4 with awp (no-scope, headshot)", Score: 18},
+	}
+
+	got := Highlights(match)
+	if len(got) != len(want) {
+		t.Fatalf("got %d highlights, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("highlight %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if f := FilterHighlights(got, HighlightMultiKill, 0); len(f) != 1 || f[0].Kind != HighlightMultiKill {
+		t.Errorf("filtering by kind: %+v", f)
+	}
+	if f := FilterHighlights(got, "", 2); len(f) != 2 || f[1].Kind != HighlightNinjaDefuse {
+		t.Errorf("limiting to the best two: %+v", f)
+	}
+}
+
+func TestMultiKillLabels(t *testing.T) {
+	snap := models.Snapshot{Players: []models.PlayerState{
+		{SteamID: 1, Team: "ct"}, {SteamID: 2, Team: "ct"},
+		{SteamID: 3, Team: "t"}, {SteamID: 4, Team: "t"}, {SteamID: 5, Team: "t"},
+		{SteamID: 6, Team: "t"}, {SteamID: 7, Team: "t"},
+	}}
+	kills := func(attacker uint64, times ...float64) []models.KillEvent {
+		var out []models.KillEvent
+		for i, ti := range times {
+			out = append(out, models.KillEvent{TimeInRound: ti, Attacker: attacker, Victim: uint64(3 + i), Weapon: "ak47"})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		kills []models.KillEvent
+		want  string
+		score float64
+	}{
+		{"slow ace", kills(1, 10, 20, 30, 40, 50), "ace", 100},
+		{"fast 4k", kills(1, 10, 11, 12, 13), "fast 4k", 91},
+		{"fast double", kills(1, 10, 11.5), "fast double kill", 15.6},
+		{"slow double", kills(1, 10, 20), "", 0},
+		{"teamkill doesn't count", append(kills(1, 10), models.KillEvent{TimeInRound: 11, Attacker: 1, Victim: 2}), "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &models.Round{Snapshots: []models.Snapshot{snap}, Kills: tt.kills}
+			got := multiKillHighlights(r, nil)
+			if tt.want == "" {
+				if len(got) != 0 {
+					t.Errorf("expected no highlight, got %+v", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Score != tt.score || label(got[0].Description) != tt.want {
+				t.Errorf("got %+v, want %q scoring %v", got, tt.want, tt.score)
+			}
+		})
+	}
+}
+
+func TestRoundClockFromKills(t *testing.T) {
+	// Without snapshots the round's start comes from its first kill.
+	r := &models.Round{Kills: []models.KillEvent{{Tick: 2000, TimeInRound: 10}}}
+	c := newRoundClock(r, 64)
+	if got := c.tick(0); got != 1360 {
+		t.Errorf("tick at freeze time end = %d, want 1360", got)
+	}
+	if got := c.tick(12.5); got != 2160 {
+		t.Errorf("tick 12.5s in = %d, want 2160", got)
+	}
+}
+
+// label returns the multi-kill label from a highlight description such as
+// "1: fast 4k with ak47 in 3.0s".
+func label(description string) string {
+	_, rest, _ := strings.Cut(description, ": ")
+	l, _, _ := strings.Cut(rest, " with ")
+	return l
+}
@@ -0,0 +1,131 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Markdown renders the report as a document suitable for sharing with the
+// rest of the staff.
+func (r *ScoutingReport) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Scouting report: %s\n\n", r.Team)
+	fmt.Fprintf(&b, "Based on %d match(es).\n\n", len(r.Matches))
+
+	if len(r.Players) > 0 {
+		names := make([]string, len(r.Players))
+		for i, p := range r.Players {
+			names[i] = p.Name
+		}
+		fmt.Fprintf(&b, "Players: %s\n\n", strings.Join(names, ", "))
+	}
+
+	b.WriteString("## Map pool\n\n")
+	b.WriteString("| Map | Matches | Won | Rounds | Round win % | T rounds | CT rounds |\n")
+	b.WriteString("|-----|---------|-----|--------|-------------|----------|-----------|\n")
+	for _, m := range r.MapPool {
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %s | %d/%d | %d/%d |\n",
+			m.Map, m.Matches, m.MatchesWon, m.Rounds, percent(m.WinRate),
+			m.TRoundsWon, m.TRounds, m.CTRoundsWon, m.CTRounds)
+	}
+	b.WriteString("\n")
+
+	for _, m := range r.Maps {
+		fmt.Fprintf(&b, "## %s\n\n", m.Map)
+
+		if len(m.PistolRounds) > 0 {
+			b.WriteString("### Pistol rounds\n\n")
+			b.WriteString("| Match | Round | Side | Result | Positions @20s | Utility | Plant |\n")
+			b.WriteString("|-------|-------|------|--------|----------------|---------|-------|\n")
+			for _, p := range m.PistolRounds {
+				result := "lost"
+				if p.Won {
+					result = "won"
+				}
+				fmt.Fprintf(&b, "| %s | %d | %s | %s | %s | %s | %s |\n",
+					p.MatchID, p.Round, strings.ToUpper(p.Side), result,
+					strings.Join(p.Positions, ", "), countList(p.Utility), p.PlantSite)
+			}
+			b.WriteString("\n")
+		}
+
+		if len(m.TDefaults) > 0 {
+			b.WriteString("### T default @20s\n\n")
+			b.WriteString("| Place | Rounds | Avg players |\n")
+			b.WriteString("|-------|--------|-------------|\n")
+			for _, p := range m.TDefaults {
+				fmt.Fprintf(&b, "| %s | %s | %.1f |\n", p.Place, percent(p.Share), p.AvgPlayers)
+			}
+			b.WriteString("\n")
+		}
+
+		if len(m.Executes) > 0 {
+			b.WriteString("### Executes\n\n")
+			b.WriteString("| Site | Rounds | Share of T rounds | Win % | Avg plant time | Utility per execute |\n")
+			b.WriteString("|------|--------|-------------------|-------|----------------|---------------------|\n")
+			for _, e := range m.Executes {
+				fmt.Fprintf(&b, "| %s | %d | %s | %s | %.0fs | %.1f (%s) |\n",
+					e.Site, e.Rounds, percent(e.Share), percent(e.WinRate),
+					e.AvgPlantTime, e.AvgUtility, avgList(e.Utility))
+			}
+			b.WriteString("\n")
+		}
+
+		if m.CT.Rounds > 0 {
+			b.WriteString("### CT setups @20s\n\n")
+			fmt.Fprintf(&b, "Stacked in %s of %d CT rounds", percent(m.CT.StackRate), m.CT.Rounds)
+			if len(m.CT.StackRounds) > 0 {
+				fmt.Fprintf(&b, " (%s)", countList(m.CT.StackRounds))
+			}
+			b.WriteString(".\n")
+			if len(m.CT.AvgPlayers) > 0 {
+				fmt.Fprintf(&b, "Average players per site: %s.\n", avgList(m.CT.AvgPlayers))
+			}
+			b.WriteString("\n")
+		}
+
+		if len(m.Utility) > 0 {
+			b.WriteString("### Utility by site\n\n")
+			b.WriteString("| Side | Site | Type | Lands at | Count | Per round |\n")
+			b.WriteString("|------|------|------|----------|-------|-----------|\n")
+			for _, u := range m.Utility {
+				fmt.Fprintf(&b, "| %s | %s | %s | %s | %d | %.2f |\n",
+					strings.ToUpper(u.Side), u.Site, u.Type, u.Place, u.Count, u.PerRound)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+func percent(v float64) string {
+	return fmt.Sprintf("%.0f%%", v*100)
+}
+
+func countList(m map[string]int) string {
+	parts := make([]string, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		parts = append(parts, fmt.Sprintf("%s %d", k, m[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func avgList(m map[string]float64) string {
+	parts := make([]string, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		parts = append(parts, fmt.Sprintf("%s %.1f", k, m[k]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
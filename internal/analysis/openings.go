@@ -0,0 +1,174 @@
+package analysis
+
+import (
+	"sort"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// siteOther groups opening duels that didn't happen on or near a bombsite.
+const siteOther = "other"
+
+// OpeningDuel is the first kill of a round.
+type OpeningDuel struct {
+	Round        int     `json:"round"`
+	Tick         int     `json:"tick"`
+	TimeInRound  float64 `json:"timeInRound"`
+	Attacker     uint64  `json:"attacker"`
+	Victim       uint64  `json:"victim"`
+	AttackerSide string  `json:"attackerSide"`
+	Weapon       string  `json:"weapon"`
+	Place        string  `json:"place"`
+	Site         string  `json:"site"`
+	PlantSite    string  `json:"plantSite,omitempty"`
+	RoundWinner  string  `json:"roundWinner"`
+	Converted    bool    `json:"converted"`
+}
+
+// PlayerOpenings is a player's record in opening duels. Entries are the
+// duels they took part in while on T.
+type PlayerOpenings struct {
+	SteamID        uint64  `json:"steamId"`
+	Duels          int     `json:"duels"`
+	Wins           int     `json:"wins"`
+	SuccessRate    float64 `json:"successRate"`
+	Converted      int     `json:"converted"`
+	ConversionRate float64 `json:"conversionRate"`
+	Entries        int     `json:"entries"`
+	EntryWins      int     `json:"entryWins"`
+	EntrySuccess   float64 `json:"entrySuccess"`
+}
+
+// SiteOpenings summarizes opening duels fought on one bombsite.
+type SiteOpenings struct {
+	Site           string  `json:"site"`
+	Duels          int     `json:"duels"`
+	TWins          int     `json:"tWins"`
+	CTWins         int     `json:"ctWins"`
+	EntrySuccess   float64 `json:"entrySuccess"`
+	TRoundsWon     int     `json:"tRoundsWon"`
+	TRoundWinRate  float64 `json:"tRoundWinRate"`
+	PlantsAfterWin int     `json:"plantsAfterWin"`
+}
+
+// OpeningDuels returns the first kill of every round in the match. The site
+// of a duel comes from the callout it happened in, or failing that from the
+// nearest bombsite as located by the match's plants.
+func OpeningDuels(match *models.Match) []OpeningDuel {
+	centers := siteCenters([]*models.Match{match})[match.Map]
+
+	var duels []OpeningDuel
+	for i := range match.Rounds {
+		r := &match.Rounds[i]
+		sides := RoundSides(r)
+
+		for _, k := range r.Kills {
+			side, ok := sides[k.Attacker]
+			if k.Attacker == 0 || !ok || side == sides[k.Victim] {
+				continue
+			}
+
+			place := k.VictimPlace
+			if place == "" {
+				place = nearestPlace(r, k.VictimX, k.VictimY)
+			}
+
+			site := siteFromPlace(place)
+			if site == "" {
+				site = classifySite(centers, k.VictimX, k.VictimY)
+			}
+			if site == "" {
+				site = siteOther
+			}
+
+			d := OpeningDuel{
+				Round:        r.Number,
+				Tick:         k.Tick,
+				TimeInRound:  k.TimeInRound,
+				Attacker:     k.Attacker,
+				Victim:       k.Victim,
+				AttackerSide: side,
+				Weapon:       k.Weapon,
+				Place:        place,
+				Site:         site,
+				RoundWinner:  r.Winner,
+				Converted:    r.Winner == side,
+			}
+			if plant := roundPlant(r); plant != nil {
+				d.PlantSite = plant.Site
+			}
+			duels = append(duels, d)
+			break
+		}
+	}
+	return duels
+}
+
+// OpeningStats aggregates opening duels per player and per site.
+func OpeningStats(duels []OpeningDuel) ([]PlayerOpenings, []SiteOpenings) {
+	players := make(map[uint64]*PlayerOpenings)
+	getPlayer := func(id uint64) *PlayerOpenings {
+		p, ok := players[id]
+		if !ok {
+			p = &PlayerOpenings{SteamID: id}
+			players[id] = p
+		}
+		return p
+	}
+	sites := make(map[string]*SiteOpenings)
+
+	for _, d := range duels {
+		winner, loser := getPlayer(d.Attacker), getPlayer(d.Victim)
+		winner.Duels++
+		winner.Wins++
+		loser.Duels++
+		if d.Converted {
+			winner.Converted++
+		}
+
+		tWon := d.AttackerSide == "t"
+		if tWon {
+			winner.Entries++
+			winner.EntryWins++
+		} else {
+			loser.Entries++
+		}
+
+		s, ok := sites[d.Site]
+		if !ok {
+			s = &SiteOpenings{Site: d.Site}
+			sites[d.Site] = s
+		}
+		s.Duels++
+		if d.RoundWinner == "t" {
+			s.TRoundsWon++
+		}
+		if tWon {
+			s.TWins++
+			if d.PlantSite != "" {
+				s.PlantsAfterWin++
+			}
+		} else {
+			s.CTWins++
+		}
+	}
+
+	playerOut := make([]PlayerOpenings, 0, len(players))
+	for _, p := range players {
+		p.SuccessRate = ratio(p.Wins, p.Duels)
+		p.ConversionRate = ratio(p.Converted, p.Wins)
+		p.EntrySuccess = ratio(p.EntryWins, p.Entries)
+		playerOut = append(playerOut, *p)
+	}
+	sort.Slice(playerOut, func(i, j int) bool { return playerOut[i].SteamID < playerOut[j].SteamID })
+
+	siteOut := make([]SiteOpenings, 0, len(sites))
+	for _, s := range sites {
+		s.EntrySuccess = ratio(s.TWins, s.Duels)
+		s.TRoundWinRate = ratio(s.TRoundsWon, s.Duels)
+		siteOut = append(siteOut, *s)
+	}
+	sort.Slice(siteOut, func(i, j int) bool { return siteOut[i].Site < siteOut[j].Site })
+
+	return playerOut, siteOut
+}
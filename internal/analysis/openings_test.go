@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestOpenings(t *testing.T) {
+	// CT 1, 2, 3 vs T 4, 5, 6.
+	snap := models.Snapshot{Players: []models.PlayerState{
+		{SteamID: 1, Team: "ct"}, {SteamID: 2, Team: "ct"}, {SteamID: 3, Team: "ct"},
+		{SteamID: 4, Team: "t"}, {SteamID: 5, Team: "t"}, {SteamID: 6, Team: "t"},
+	}}
+	snaps := []models.Snapshot{snap}
+
+	match := &models.Match{Map: "de_test", Rounds: []models.Round{
+		{
+			// A fall death and a teamkill before the real opening duel.
+			Number:    1,
+			Winner:    "t",
+			Snapshots: snaps,
+			Plant:     &models.BombEvent{Site: "A", X: 1000, Y: 1000},
+			Kills: []models.KillEvent{
+				{TimeInRound: 5, Victim: 3, Weapon: "world"},
+				{TimeInRound: 8, Attacker: 6, Victim: 5},
+				{TimeInRound: 12, Tick: 768, Attacker: 4, Victim: 1, Weapon: "ak47", VictimPlace: "BombsiteA"},
+				{TimeInRound: 14, Attacker: 2, Victim: 4},
+			},
+		},
+		{
+			// CT wins the opening in mid but loses the round.
+			Number:    2,
+			Winner:    "t",
+			Snapshots: snaps,
+			Kills: []models.KillEvent{
+				{TimeInRound: 20, Attacker: 2, Victim: 4, VictimPlace: "Mid", VictimX: -2000},
+			},
+		},
+		{
+			// No callout: the site comes from where round 1's bomb went down,
+			// and the place from the coarse map grid.
+			Number:    3,
+			Winner:    "ct",
+			Snapshots: snaps,
+			Kills: []models.KillEvent{
+				{TimeInRound: 25, Attacker: 1, Victim: 5, VictimX: 1100, VictimY: 900},
+			},
+		},
+		{Number: 4, Winner: "ct", Snapshots: snaps},
+	}}
+
+	duels := OpeningDuels(match)
+	want := []OpeningDuel{
+		{Round: 1, Tick: 768, TimeInRound: 12, Attacker: 4, Victim: 1, AttackerSide: "t", Weapon: "ak47",
+			Place: "BombsiteA", Site: "A", PlantSite: "A", RoundWinner: "t", Converted: true},
+		{Round: 2, TimeInRound: 20, Attacker: 2, Victim: 4, AttackerSide: "ct",
+			Place: "Mid", Site: siteOther, RoundWinner: "t"},
+		{Round: 3, TimeInRound: 25, Attacker: 1, Victim: 5, AttackerSide: "ct",
+			Place: "grid 2,1", Site: "A", RoundWinner: "ct", Converted: true},
+	}
+	if len(duels) != len(want) {
+		t.Fatalf("got %d opening duels, want %d: %+v", len(duels), len(want), duels)
+	}
+	for i := range want {
+		if duels[i] != want[i] {
+			t.Errorf("duel %d = %+v, want %+v", i, duels[i], want[i])
+		}
+	}
+
+	players, sites := OpeningStats(duels)
+	wantPlayers := []PlayerOpenings{
+		{SteamID: 1, Duels: 2, Wins: 1, SuccessRate: 0.5, Converted: 1, ConversionRate: 1},
+		{SteamID: 2, Duels: 1, Wins: 1, SuccessRate: 1},
+		{SteamID: 4, Duels: 2, Wins: 1, SuccessRate: 0.5, Converted: 1, ConversionRate: 1,
+			Entries: 2, EntryWins: 1, EntrySuccess: 0.5},
+		{SteamID: 5, Duels: 1, Entries: 1},
+	}
+	if len(players) != len(wantPlayers) {
+		t.Fatalf("got %d players, want %d: %+v", len(players), len(wantPlayers), players)
+	}
+	for i := range wantPlayers {
+		if players[i] != wantPlayers[i] {
+			t.Errorf("player %d = %+v, want %+v", i, players[i], wantPlayers[i])
+		}
+	}
+
+	wantSites := []SiteOpenings{
+		{Site: "A", Duels: 2, TWins: 1, CTWins: 1, EntrySuccess: 0.5, TRoundsWon: 1, TRoundWinRate: 0.5, PlantsAfterWin: 1},
+		{Site: siteOther, Duels: 1, CTWins: 1, TRoundsWon: 1, TRoundWinRate: 1},
+	}
+	if len(sites) != len(wantSites) {
+		t.Fatalf("got %d sites, want %d: %+v", len(sites), len(wantSites), sites)
+	}
+	for i := range wantSites {
+		if sites[i] != wantSites[i] {
+			t.Errorf("site %d = %+v, want %+v", i, sites[i], wantSites[i])
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// Number of favourite positions reported per map and side.
+const maxPositionsPerSide = 3
+
+// PlayerProfile aggregates one player's performance across stored matches.
+type PlayerProfile struct {
+	SteamID       uint64         `json:"steamId"`
+	Name          string         `json:"name"`
+	MatchesPlayed int            `json:"matchesPlayed"`
+	Overall       StatLine       `json:"overall"`
+	Maps          []MapStats     `json:"maps"`
+	Positions     []PositionStat `json:"favouritePositions"`
+	Utility       UtilityStats   `json:"utility"`
+	Trend         []TrendPoint   `json:"trend"`
+}
+
+type StatLine struct {
+	Rounds      int     `json:"rounds"`
+	RoundsWon   int     `json:"roundsWon"`
+	Kills       int     `json:"kills"`
+	Deaths      int     `json:"deaths"`
+	Headshots   int     `json:"headshots"`
+	Clutches    int     `json:"clutches"`
+	ClutchesWon int     `json:"clutchesWon"`
+	KD          float64 `json:"kd"`
+	KPR         float64 `json:"kpr"`
+	HeadshotPct float64 `json:"headshotPct"`
+}
+
+type MapStats struct {
+	Map     string `json:"map"`
+	Matches int    `json:"matches"`
+	StatLine
+}
+
+// PositionStat is a place the player spends time in while alive, with its
+// share of all alive snapshots on that map and side.
+type PositionStat struct {
+	Map   string  `json:"map"`
+	Side  string  `json:"side"`
+	Place string  `json:"place"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Share float64 `json:"share"`
+}
+
+type UtilityStats struct {
+	Thrown        map[string]int `json:"thrown"`
+	PerRound      float64        `json:"perRound"`
+	AvgThrowTime  float64        `json:"avgThrowTime"`
+	UnusedAtDeath float64        `json:"unusedAtDeath"`
+}
+
+// TrendPoint is the player's stat line for a single match, in the order the
+// matches were parsed.
+type TrendPoint struct {
+	MatchID  string    `json:"matchId"`
+	Map      string    `json:"map"`
+	ParsedAt time.Time `json:"parsedAt"`
+	StatLine
+}
+
+func (s *StatLine) add(o StatLine) {
+	s.Rounds += o.Rounds
+	s.RoundsWon += o.RoundsWon
+	s.Kills += o.Kills
+	s.Deaths += o.Deaths
+	s.Headshots += o.Headshots
+	s.Clutches += o.Clutches
+	s.ClutchesWon += o.ClutchesWon
+}
+
+func (s *StatLine) finish() {
+	s.KD = float64(s.Kills)
+	if s.Deaths > 0 {
+		s.KD = ratio(s.Kills, s.Deaths)
+	}
+	s.KPR = ratio(s.Kills, s.Rounds)
+	s.HeadshotPct = ratio(s.Headshots, s.Kills)
+}
+
+type positionKey struct {
+	mapName, side, place string
+}
+
+type positionAcc struct {
+	count  int
+	sumX   float64
+	sumY   float64
+	total  *int
+	parent positionKey
+}
+
+// BuildPlayerProfile aggregates the given matches, which should be ordered
+// oldest first, into a profile for steamID. Matches the player does not
+// appear in are ignored.
+func BuildPlayerProfile(steamID uint64, matches []*models.Match) *PlayerProfile {
+	profile := &PlayerProfile{
+		SteamID: steamID,
+		Utility: UtilityStats{Thrown: make(map[string]int)},
+	}
+
+	mapStats := make(map[string]*MapStats)
+	positions := make(map[positionKey]*positionAcc)
+	aliveTotals := make(map[positionKey]*int)
+
+	var thrown, deathsWithSnapshot, unusedAtDeath int
+	var throwTimeSum float64
+
+	for _, m := range matches {
+		line, played := matchStatLine(steamID, m)
+		if !played {
+			continue
+		}
+
+		profile.MatchesPlayed++
+		if name := playerName(steamID, m); name != "" {
+			profile.Name = name
+		}
+
+		ms, ok := mapStats[m.Map]
+		if !ok {
+			ms = &MapStats{Map: m.Map}
+			mapStats[m.Map] = ms
+		}
+		ms.Matches++
+		ms.add(line)
+		profile.Overall.add(line)
+
+		line.finish()
+		profile.Trend = append(profile.Trend, TrendPoint{
+			MatchID:  m.ID,
+			Map:      m.Map,
+			ParsedAt: m.ParsedAt,
+			StatLine: line,
+		})
+
+		for i := range m.Rounds {
+			r := &m.Rounds[i]
+			side, ok := RoundSides(r)[steamID]
+			if !ok {
+				continue
+			}
+
+			totalKey := positionKey{mapName: m.Map, side: side}
+			total, ok := aliveTotals[totalKey]
+			if !ok {
+				total = new(int)
+				aliveTotals[totalKey] = total
+			}
+
+			var lastAlive *models.PlayerState
+			for _, snap := range r.Snapshots {
+				for j := range snap.Players {
+					ps := &snap.Players[j]
+					if ps.SteamID != steamID || !ps.IsAlive {
+						continue
+					}
+					lastAlive = ps
+
+					key := positionKey{mapName: m.Map, side: side, place: regionLabel(ps.Place, ps.X, ps.Y)}
+					acc, ok := positions[key]
+					if !ok {
+						acc = &positionAcc{total: total, parent: totalKey}
+						positions[key] = acc
+					}
+					acc.count++
+					acc.sumX += ps.X
+					acc.sumY += ps.Y
+					*total++
+				}
+			}
+
+			for _, g := range r.Grenades {
+				if g.Thrower != steamID {
+					continue
+				}
+				profile.Utility.Thrown[g.Type]++
+				thrown++
+				throwTimeSum += g.ThrowTime
+			}
+
+			if lastAlive != nil && diedInRound(steamID, r) {
+				deathsWithSnapshot++
+				unusedAtDeath += len(lastAlive.Grenades)
+			}
+		}
+	}
+
+	profile.Overall.finish()
+
+	for _, ms := range mapStats {
+		ms.finish()
+		profile.Maps = append(profile.Maps, *ms)
+	}
+	sort.Slice(profile.Maps, func(i, j int) bool {
+		if profile.Maps[i].Matches != profile.Maps[j].Matches {
+			return profile.Maps[i].Matches > profile.Maps[j].Matches
+		}
+		return profile.Maps[i].Map < profile.Maps[j].Map
+	})
+
+	profile.Positions = topPositions(positions)
+
+	profile.Utility.PerRound = ratio(thrown, profile.Overall.Rounds)
+	if thrown > 0 {
+		profile.Utility.AvgThrowTime = throwTimeSum / float64(thrown)
+	}
+	profile.Utility.UnusedAtDeath = ratio(unusedAtDeath, deathsWithSnapshot)
+
+	return profile
+}
+
+// matchStatLine computes the player's stat line for a single match. The
+// second return value is false if the player never appeared in it.
+func matchStatLine(steamID uint64, m *models.Match) (StatLine, bool) {
+	var line StatLine
+	played := false
+
+	for i := range m.Rounds {
+		r := &m.Rounds[i]
+		side, ok := RoundSides(r)[steamID]
+		if !ok {
+			continue
+		}
+		played = true
+		line.Rounds++
+		if r.Winner == side {
+			line.RoundsWon++
+		}
+
+		if clutch, _ := analyzeAliveCounts(r); clutch != nil && clutch.Player == steamID {
+			line.Clutches++
+			if clutch.Won {
+				line.ClutchesWon++
+			}
+		}
+
+		for _, k := range r.Kills {
+			if k.Attacker == steamID && k.Victim != steamID {
+				line.Kills++
+				if k.Headshot {
+					line.Headshots++
+				}
+			}
+			if k.Victim == steamID {
+				line.Deaths++
+			}
+		}
+	}
+
+	return line, played
+}
+
+func diedInRound(steamID uint64, r *models.Round) bool {
+	for _, k := range r.Kills {
+		if k.Victim == steamID {
+			return true
+		}
+	}
+	return false
+}
+
+func playerName(steamID uint64, m *models.Match) string {
+	for _, team := range []models.TeamInfo{m.Teams.CT, m.Teams.T} {
+		for _, p := range team.Players {
+			if p.SteamID == steamID {
+				return p.Name
+			}
+		}
+	}
+	return ""
+}
+
+// topPositions keeps the most visited places for each map and side.
+func topPositions(positions map[positionKey]*positionAcc) []PositionStat {
+	grouped := make(map[positionKey][]PositionStat)
+	for key, acc := range positions {
+		grouped[acc.parent] = append(grouped[acc.parent], PositionStat{
+			Map:   key.mapName,
+			Side:  key.side,
+			Place: key.place,
+			X:     acc.sumX / float64(acc.count),
+			Y:     acc.sumY / float64(acc.count),
+			Share: ratio(acc.count, *acc.total),
+		})
+	}
+
+	var result []PositionStat
+	for _, stats := range grouped {
+		sort.Slice(stats, func(i, j int) bool {
+			if stats[i].Share != stats[j].Share {
+				return stats[i].Share > stats[j].Share
+			}
+			return stats[i].Place < stats[j].Place
+		})
+		if len(stats) > maxPositionsPerSide {
+			stats = stats[:maxPositionsPerSide]
+		}
+		result = append(result, stats...)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Map != result[j].Map {
+			return result[i].Map < result[j].Map
+		}
+		if result[i].Side != result[j].Side {
+			return result[i].Side < result[j].Side
+		}
+		return result[i].Share > result[j].Share
+	})
+	return result
+}
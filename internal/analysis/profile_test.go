@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestBuildPlayerProfile(t *testing.T) {
+	const me, other = 1, 2
+
+	snap := func(place string, alive bool, grenades ...string) models.Snapshot {
+		return models.Snapshot{Players: []models.PlayerState{
+			{SteamID: me, Team: "ct", Place: place, IsAlive: alive, Grenades: grenades, X: 100, Y: 200},
+			{SteamID: other, Team: "t", IsAlive: true},
+		}}
+	}
+
+	match := &models.Match{
+		ID:  "m1",
+		Map: "de_dust2",
+		Teams: models.Teams{
+			CT: models.TeamInfo{Players: []models.PlayerInfo{{SteamID: me, Name: "me"}}},
+			T:  models.TeamInfo{Players: []models.PlayerInfo{{SteamID: other, Name: "other"}}},
+		},
+		Rounds: []models.Round{
+			{
+				Winner:    "ct",
+				Snapshots: []models.Snapshot{snap("BombsiteB", true), snap("BombsiteB", true), snap("Tunnels", true)},
+				Kills:     []models.KillEvent{{Attacker: me, Victim: other, Headshot: true}},
+				Grenades:  []models.GrenadeEvent{{Type: "smoke", Thrower: me, ThrowTime: 10}},
+			},
+			{
+				Winner:    "t",
+				Snapshots: []models.Snapshot{snap("BombsiteB", true, "Flashbang", "Smoke Grenade"), snap("BombsiteB", false)},
+				Kills:     []models.KillEvent{{Attacker: other, Victim: me}},
+				Grenades:  []models.GrenadeEvent{{Type: "flash", Thrower: me, ThrowTime: 20}},
+			},
+		},
+	}
+	unrelated := &models.Match{ID: "m2", Map: "de_nuke"}
+
+	p := BuildPlayerProfile(me, []*models.Match{match, unrelated})
+
+	if p.Name != "me" || p.MatchesPlayed != 1 {
+		t.Fatalf("unexpected identity: name=%q matches=%d", p.Name, p.MatchesPlayed)
+	}
+
+	want := StatLine{Rounds: 2, RoundsWon: 1, Kills: 1, Deaths: 1, Headshots: 1, KD: 1, KPR: 0.5, HeadshotPct: 1}
+	if p.Overall != want {
+		t.Errorf("overall = %+v, want %+v", p.Overall, want)
+	}
+
+	if len(p.Maps) != 1 || p.Maps[0].Map != "de_dust2" || p.Maps[0].Matches != 1 {
+		t.Errorf("unexpected map stats: %+v", p.Maps)
+	}
+
+	if len(p.Positions) != 2 || p.Positions[0].Place != "BombsiteB" || p.Positions[0].Share != 0.75 {
+		t.Errorf("unexpected positions: %+v", p.Positions)
+	}
+
+	if p.Utility.Thrown["smoke"] != 1 || p.Utility.Thrown["flash"] != 1 {
+		t.Errorf("unexpected utility counts: %+v", p.Utility.Thrown)
+	}
+	if p.Utility.AvgThrowTime != 15 || p.Utility.UnusedAtDeath != 2 {
+		t.Errorf("unexpected utility habits: %+v", p.Utility)
+	}
+
+	if len(p.Trend) != 1 || p.Trend[0].MatchID != "m1" {
+		t.Errorf("unexpected trend: %+v", p.Trend)
+	}
+}
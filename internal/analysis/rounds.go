@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// Size of the grid cells used to label positions when the demo carries no
+// callout names. Roughly the footprint of a small room.
+const regionGridSize = 512.0
+
+// RoundSides maps each participant of the round to the side they played.
+// Sides are taken from the first snapshot a player appears in.
+func RoundSides(r *models.Round) map[uint64]string {
+	sides := make(map[uint64]string)
+	for _, snap := range r.Snapshots {
+		for _, ps := range snap.Players {
+			if ps.SteamID == 0 || ps.Team == "" {
+				continue
+			}
+			if _, ok := sides[ps.SteamID]; !ok {
+				sides[ps.SteamID] = ps.Team
+			}
+		}
+	}
+	return sides
+}
+
+// regionLabel names the area a player is standing in, preferring the map's
+// callout and falling back to a coarse grid cell.
+func regionLabel(place string, x, y float64) string {
+	if place != "" {
+		return place
+	}
+	return fmt.Sprintf("grid %d,%d", int(math.Floor(x/regionGridSize)), int(math.Floor(y/regionGridSize)))
+}
+
+// roundEndTime returns when the round was decided, in seconds after freeze
+// time. Older matches without a recorded end fall back to the last snapshot
+// minus the post-round buffer the parser keeps recording for.
+func roundEndTime(r *models.Round) float64 {
+	const postRoundBuffer = 3.0
+
+	if r.EndTime > 0 {
+		return r.EndTime
+	}
+	if n := len(r.Snapshots); n > 0 {
+		return math.Max(0, r.Snapshots[n-1].TimeInRound-postRoundBuffer)
+	}
+	return 0
+}
+
+// snapshotAt returns the snapshot closest to t seconds into the round, or
+// nil if the round has no snapshots.
+func snapshotAt(r *models.Round, t float64) *models.Snapshot {
+	var best *models.Snapshot
+	bestDiff := math.MaxFloat64
+	for i := range r.Snapshots {
+		diff := math.Abs(r.Snapshots[i].TimeInRound - t)
+		if diff < bestDiff {
+			bestDiff = diff
+			best = &r.Snapshots[i]
+		}
+	}
+	return best
+}
+
+// roundPlant returns the round's bomb plant. Matches parsed before plants
+// were recorded fall back to the first snapshot showing a planted bomb, with
+// the site guessed from the nearest callout.
+func roundPlant(r *models.Round) *models.BombEvent {
+	if r.Plant != nil {
+		return r.Plant
+	}
+	for _, snap := range r.Snapshots {
+		if snap.Bomb != nil && snap.Bomb.State == "planted" {
+			return &models.BombEvent{
+				Tick:        snap.Tick,
+				TimeInRound: snap.TimeInRound,
+				Site:        siteFromPlace(nearestPlace(r, snap.Bomb.X, snap.Bomb.Y)),
+				X:           snap.Bomb.X,
+				Y:           snap.Bomb.Y,
+			}
+		}
+	}
+	return nil
+}
+
+// nearestPlace labels a world position using the callout of the closest
+// player position recorded in the round.
+func nearestPlace(r *models.Round, x, y float64) string {
+	const maxDist = 400.0
+
+	place := ""
+	bestDist := maxDist * maxDist
+	for _, snap := range r.Snapshots {
+		for _, ps := range snap.Players {
+			if ps.Place == "" {
+				continue
+			}
+			dx, dy := ps.X-x, ps.Y-y
+			if d := dx*dx + dy*dy; d < bestDist {
+				bestDist = d
+				place = ps.Place
+			}
+		}
+	}
+	return regionLabel(place, x, y)
+}
+
+func siteFromPlace(place string) string {
+	switch {
+	case strings.Contains(place, "BombsiteA"):
+		return "A"
+	case strings.Contains(place, "BombsiteB"):
+		return "B"
+	default:
+		return ""
+	}
+}
+
+func ratio(num, den int) float64 {
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
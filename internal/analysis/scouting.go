@@ -0,0 +1,603 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+const (
+	// Seconds after freeze time at which "default" setups are sampled.
+	setupSampleTime = 20.0
+
+	// Utility thrown this many seconds before a plant counts towards the
+	// execute onto that site.
+	executeWindow = 15.0
+
+	// Number of CT players near one site for a setup to count as a stack.
+	stackThreshold = 3
+
+	maxDefaultPlaces      = 10
+	maxUtilityPerSiteSide = 8
+)
+
+// TeamSelector identifies a team across matches by clan name, by roster, or
+// both. A roster matches when at least three of its players (or all of them,
+// for smaller rosters) appear in a match.
+type TeamSelector struct {
+	Name    string   `json:"name,omitempty"`
+	Players []uint64 `json:"players,omitempty"`
+}
+
+func (t TeamSelector) Label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return "roster"
+}
+
+// PlayedIn reports whether the team is one of teams.
+func (t TeamSelector) PlayedIn(teams models.Teams) bool {
+	return t.roster(teams) != nil
+}
+
+// roster resolves the team's players among teams, or returns nil if the
+// team is not one of them.
+func (t TeamSelector) roster(teams models.Teams) map[uint64]bool {
+	if t.Name != "" {
+		for _, team := range []models.TeamInfo{teams.CT, teams.T} {
+			if !strings.EqualFold(team.Name, t.Name) {
+				continue
+			}
+			roster := make(map[uint64]bool, len(team.Players))
+			for _, p := range team.Players {
+				roster[p.SteamID] = true
+			}
+			return roster
+		}
+	}
+
+	if len(t.Players) == 0 {
+		return nil
+	}
+
+	required := min(stackThreshold, len(t.Players))
+	roster := make(map[uint64]bool)
+	for _, team := range []models.TeamInfo{teams.CT, teams.T} {
+		for _, p := range team.Players {
+			for _, id := range t.Players {
+				if p.SteamID == id {
+					roster[id] = true
+				}
+			}
+		}
+	}
+	if len(roster) < required {
+		return nil
+	}
+	return roster
+}
+
+// rosterSide returns the side most of the roster played in the round.
+func rosterSide(r *models.Round, roster map[uint64]bool) string {
+	counts := make(map[string]int)
+	for id, side := range RoundSides(r) {
+		if roster[id] {
+			counts[side]++
+		}
+	}
+	switch {
+	case counts["ct"] > counts["t"]:
+		return "ct"
+	case counts["t"] > counts["ct"]:
+		return "t"
+	default:
+		return ""
+	}
+}
+
+// isPistolRound reports whether n is the first round of a regulation half.
+func isPistolRound(n int) bool {
+	return n == 1 || n == 13
+}
+
+type ScoutingReport struct {
+	Team    string              `json:"team"`
+	Matches []string            `json:"matches"`
+	MapPool []MapRecord         `json:"mapPool"`
+	Maps    []MapScouting       `json:"maps"`
+	Players []models.PlayerInfo `json:"players"`
+}
+
+type MapRecord struct {
+	Map         string  `json:"map"`
+	Matches     int     `json:"matches"`
+	MatchesWon  int     `json:"matchesWon"`
+	Rounds      int     `json:"rounds"`
+	RoundsWon   int     `json:"roundsWon"`
+	TRounds     int     `json:"tRounds"`
+	TRoundsWon  int     `json:"tRoundsWon"`
+	CTRounds    int     `json:"ctRounds"`
+	CTRoundsWon int     `json:"ctRoundsWon"`
+	WinRate     float64 `json:"winRate"`
+}
+
+type MapScouting struct {
+	Map          string           `json:"map"`
+	PistolRounds []PistolRound    `json:"pistolRounds"`
+	TDefaults    []PlaceFrequency `json:"tDefaults"`
+	Executes     []Execute        `json:"executes"`
+	CT           CTTendencies     `json:"ct"`
+	Utility      []SiteUtility    `json:"utility"`
+}
+
+type PistolRound struct {
+	MatchID   string         `json:"matchId"`
+	Round     int            `json:"round"`
+	Side      string         `json:"side"`
+	Won       bool           `json:"won"`
+	Positions []string       `json:"positions"`
+	Utility   map[string]int `json:"utility"`
+	PlantSite string         `json:"plantSite,omitempty"`
+}
+
+// PlaceFrequency describes how often the team has someone in a place at
+// the sampling time: Share is the fraction of rounds with at least one
+// player there, AvgPlayers the mean number of players there.
+type PlaceFrequency struct {
+	Place      string  `json:"place"`
+	Share      float64 `json:"share"`
+	AvgPlayers float64 `json:"avgPlayers"`
+}
+
+type Execute struct {
+	Site         string             `json:"site"`
+	Rounds       int                `json:"rounds"`
+	Share        float64            `json:"share"`
+	WinRate      float64            `json:"winRate"`
+	AvgPlantTime float64            `json:"avgPlantTime"`
+	AvgUtility   float64            `json:"avgUtility"`
+	Utility      map[string]float64 `json:"utility"`
+}
+
+type CTTendencies struct {
+	Rounds      int                `json:"rounds"`
+	AvgPlayers  map[string]float64 `json:"avgPlayers"`
+	StackRounds map[string]int     `json:"stackRounds"`
+	StackRate   float64            `json:"stackRate"`
+}
+
+type SiteUtility struct {
+	Side     string  `json:"side"`
+	Site     string  `json:"site"`
+	Type     string  `json:"type"`
+	Place    string  `json:"place"`
+	Count    int     `json:"count"`
+	PerRound float64 `json:"perRound"`
+}
+
+type placeAcc struct {
+	rounds  int
+	players int
+}
+
+type executeAcc struct {
+	rounds     int
+	won        int
+	plantTime  float64
+	utility    int
+	utilByType map[string]int
+}
+
+type utilityKey struct {
+	side, site, typ, place string
+}
+
+type mapScout struct {
+	record   MapRecord
+	pistols  []PistolRound
+	tPlaces  map[string]*placeAcc
+	executes map[string]*executeAcc
+	ct       CTTendencies
+	ctCounts map[string]int
+	utility  map[utilityKey]int
+}
+
+// BuildScoutingReport summarizes how team plays across matches. Matches the
+// team did not take part in are skipped.
+func BuildScoutingReport(team TeamSelector, matches []*models.Match) *ScoutingReport {
+	report := &ScoutingReport{Team: team.Label()}
+	centers := siteCenters(matches)
+	scouts := make(map[string]*mapScout)
+	players := make(map[uint64]string)
+
+	for _, m := range matches {
+		roster := team.roster(m.Teams)
+		if roster == nil {
+			continue
+		}
+		report.Matches = append(report.Matches, m.ID)
+		for _, t := range []models.TeamInfo{m.Teams.CT, m.Teams.T} {
+			for _, info := range t.Players {
+				if roster[info.SteamID] {
+					players[info.SteamID] = info.Name
+				}
+			}
+		}
+
+		ms, ok := scouts[m.Map]
+		if !ok {
+			ms = &mapScout{
+				record:   MapRecord{Map: m.Map},
+				tPlaces:  make(map[string]*placeAcc),
+				executes: make(map[string]*executeAcc),
+				ct: CTTendencies{
+					AvgPlayers:  make(map[string]float64),
+					StackRounds: make(map[string]int),
+				},
+				ctCounts: make(map[string]int),
+				utility:  make(map[utilityKey]int),
+			}
+			scouts[m.Map] = ms
+		}
+		ms.addMatch(m, roster, centers[m.Map])
+	}
+
+	for _, ms := range scouts {
+		report.Maps = append(report.Maps, ms.finish())
+		report.MapPool = append(report.MapPool, ms.record)
+	}
+
+	sort.Slice(report.MapPool, func(i, j int) bool {
+		if report.MapPool[i].Matches != report.MapPool[j].Matches {
+			return report.MapPool[i].Matches > report.MapPool[j].Matches
+		}
+		return report.MapPool[i].Map < report.MapPool[j].Map
+	})
+	sort.Slice(report.Maps, func(i, j int) bool {
+		return report.Maps[i].Map < report.Maps[j].Map
+	})
+
+	for id, name := range players {
+		report.Players = append(report.Players, models.PlayerInfo{SteamID: id, Name: name})
+	}
+	sort.Slice(report.Players, func(i, j int) bool {
+		return report.Players[i].Name < report.Players[j].Name
+	})
+
+	return report
+}
+
+func (ms *mapScout) addMatch(m *models.Match, roster map[uint64]bool, centers map[string][2]float64) {
+	ms.record.Matches++
+
+	finalSide := ""
+	for i := range m.Rounds {
+		r := &m.Rounds[i]
+		side := rosterSide(r, roster)
+		if side == "" {
+			continue
+		}
+		finalSide = side
+		won := r.Winner == side
+
+		ms.record.Rounds++
+		if won {
+			ms.record.RoundsWon++
+		}
+		switch side {
+		case "t":
+			ms.record.TRounds++
+			if won {
+				ms.record.TRoundsWon++
+			}
+			ms.addTRound(r, roster, won)
+		case "ct":
+			ms.record.CTRounds++
+			if won {
+				ms.record.CTRoundsWon++
+			}
+			ms.addCTRound(r, roster, centers)
+		}
+
+		if isPistolRound(r.Number) {
+			ms.pistols = append(ms.pistols, pistolRound(m.ID, r, roster, side, won))
+		}
+
+		for _, g := range r.Grenades {
+			if !roster[g.Thrower] {
+				continue
+			}
+			key := utilityKey{
+				side:  side,
+				site:  classifySite(centers, g.DetonateX, g.DetonateY),
+				typ:   g.Type,
+				place: nearestPlace(r, g.DetonateX, g.DetonateY),
+			}
+			ms.utility[key]++
+		}
+	}
+
+	if n := len(m.Rounds); n > 0 && finalSide != "" {
+		last := m.Rounds[n-1]
+		own, opp := last.EndCTScore, last.EndTScore
+		if finalSide == "t" {
+			own, opp = opp, own
+		}
+		if own > opp {
+			ms.record.MatchesWon++
+		}
+	}
+}
+
+func (ms *mapScout) addTRound(r *models.Round, roster map[uint64]bool, won bool) {
+	if snap := snapshotAt(r, setupSampleTime); snap != nil {
+		counts := make(map[string]int)
+		for _, ps := range snap.Players {
+			if roster[ps.SteamID] && ps.IsAlive {
+				counts[regionLabel(ps.Place, ps.X, ps.Y)]++
+			}
+		}
+		for place, n := range counts {
+			acc, ok := ms.tPlaces[place]
+			if !ok {
+				acc = &placeAcc{}
+				ms.tPlaces[place] = acc
+			}
+			acc.rounds++
+			acc.players += n
+		}
+	}
+
+	plant := roundPlant(r)
+	if plant == nil {
+		return
+	}
+
+	site := plant.Site
+	if site == "" {
+		site = "unknown"
+	}
+	acc, ok := ms.executes[site]
+	if !ok {
+		acc = &executeAcc{utilByType: make(map[string]int)}
+		ms.executes[site] = acc
+	}
+	acc.rounds++
+	if won {
+		acc.won++
+	}
+	acc.plantTime += plant.TimeInRound
+
+	for _, g := range r.Grenades {
+		if !roster[g.Thrower] || g.ThrowTime > plant.TimeInRound || g.ThrowTime < plant.TimeInRound-executeWindow {
+			continue
+		}
+		acc.utility++
+		acc.utilByType[g.Type]++
+	}
+}
+
+func (ms *mapScout) addCTRound(r *models.Round, roster map[uint64]bool, centers map[string][2]float64) {
+	ms.ct.Rounds++
+
+	snap := snapshotAt(r, setupSampleTime)
+	if snap == nil || len(centers) == 0 {
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, ps := range snap.Players {
+		if !roster[ps.SteamID] || !ps.IsAlive {
+			continue
+		}
+		if site := classifySite(centers, ps.X, ps.Y); site != "" {
+			counts[site]++
+		}
+	}
+	for site, n := range counts {
+		ms.ctCounts[site] += n
+		if n >= stackThreshold {
+			ms.ct.StackRounds[site]++
+		}
+	}
+}
+
+func (ms *mapScout) finish() MapScouting {
+	rec := &ms.record
+	rec.WinRate = ratio(rec.RoundsWon, rec.Rounds)
+
+	out := MapScouting{
+		Map:          rec.Map,
+		PistolRounds: ms.pistols,
+		CT:           ms.ct,
+	}
+
+	for place, acc := range ms.tPlaces {
+		out.TDefaults = append(out.TDefaults, PlaceFrequency{
+			Place:      place,
+			Share:      ratio(acc.rounds, rec.TRounds),
+			AvgPlayers: ratio(acc.players, rec.TRounds),
+		})
+	}
+	sort.Slice(out.TDefaults, func(i, j int) bool {
+		if out.TDefaults[i].Share != out.TDefaults[j].Share {
+			return out.TDefaults[i].Share > out.TDefaults[j].Share
+		}
+		return out.TDefaults[i].Place < out.TDefaults[j].Place
+	})
+	if len(out.TDefaults) > maxDefaultPlaces {
+		out.TDefaults = out.TDefaults[:maxDefaultPlaces]
+	}
+
+	for site, acc := range ms.executes {
+		ex := Execute{
+			Site:         site,
+			Rounds:       acc.rounds,
+			Share:        ratio(acc.rounds, rec.TRounds),
+			WinRate:      ratio(acc.won, acc.rounds),
+			AvgPlantTime: acc.plantTime / float64(acc.rounds),
+			AvgUtility:   ratio(acc.utility, acc.rounds),
+			Utility:      make(map[string]float64),
+		}
+		for typ, n := range acc.utilByType {
+			ex.Utility[typ] = ratio(n, acc.rounds)
+		}
+		out.Executes = append(out.Executes, ex)
+	}
+	sort.Slice(out.Executes, func(i, j int) bool {
+		if out.Executes[i].Rounds != out.Executes[j].Rounds {
+			return out.Executes[i].Rounds > out.Executes[j].Rounds
+		}
+		return out.Executes[i].Site < out.Executes[j].Site
+	})
+
+	for site, n := range ms.ctCounts {
+		out.CT.AvgPlayers[site] = ratio(n, ms.ct.Rounds)
+	}
+	stacks := 0
+	for _, n := range ms.ct.StackRounds {
+		stacks += n
+	}
+	out.CT.StackRate = ratio(stacks, ms.ct.Rounds)
+
+	sideRounds := map[string]int{"t": rec.TRounds, "ct": rec.CTRounds}
+	grouped := make(map[[2]string][]SiteUtility)
+	for key, n := range ms.utility {
+		site := key.site
+		if site == "" {
+			site = "other"
+		}
+		g := [2]string{key.side, site}
+		grouped[g] = append(grouped[g], SiteUtility{
+			Side:     key.side,
+			Site:     site,
+			Type:     key.typ,
+			Place:    key.place,
+			Count:    n,
+			PerRound: ratio(n, sideRounds[key.side]),
+		})
+	}
+	for _, list := range grouped {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+			if list[i].Type != list[j].Type {
+				return list[i].Type < list[j].Type
+			}
+			return list[i].Place < list[j].Place
+		})
+		if len(list) > maxUtilityPerSiteSide {
+			list = list[:maxUtilityPerSiteSide]
+		}
+		out.Utility = append(out.Utility, list...)
+	}
+	sort.SliceStable(out.Utility, func(i, j int) bool {
+		a, b := out.Utility[i], out.Utility[j]
+		if a.Side != b.Side {
+			return a.Side > b.Side
+		}
+		if a.Site != b.Site {
+			return a.Site < b.Site
+		}
+		return a.Count > b.Count
+	})
+
+	return out
+}
+
+func pistolRound(matchID string, r *models.Round, roster map[uint64]bool, side string, won bool) PistolRound {
+	pr := PistolRound{
+		MatchID: matchID,
+		Round:   r.Number,
+		Side:    side,
+		Won:     won,
+		Utility: make(map[string]int),
+	}
+
+	if snap := snapshotAt(r, setupSampleTime); snap != nil {
+		for _, ps := range snap.Players {
+			if roster[ps.SteamID] && ps.IsAlive {
+				pr.Positions = append(pr.Positions, regionLabel(ps.Place, ps.X, ps.Y))
+			}
+		}
+		sort.Strings(pr.Positions)
+	}
+
+	for _, g := range r.Grenades {
+		if roster[g.Thrower] {
+			pr.Utility[g.Type]++
+		}
+	}
+
+	if side == "t" {
+		if plant := roundPlant(r); plant != nil {
+			pr.PlantSite = plant.Site
+		}
+	}
+	return pr
+}
+
+// siteCenters estimates each bombsite's location per map from the plants
+// recorded across all given matches.
+func siteCenters(matches []*models.Match) map[string]map[string][2]float64 {
+	type acc struct {
+		x, y float64
+		n    int
+	}
+	sums := make(map[string]map[string]*acc)
+
+	for _, m := range matches {
+		for i := range m.Rounds {
+			plant := roundPlant(&m.Rounds[i])
+			if plant == nil || plant.Site == "" {
+				continue
+			}
+			bySite, ok := sums[m.Map]
+			if !ok {
+				bySite = make(map[string]*acc)
+				sums[m.Map] = bySite
+			}
+			a, ok := bySite[plant.Site]
+			if !ok {
+				a = &acc{}
+				bySite[plant.Site] = a
+			}
+			a.x += plant.X
+			a.y += plant.Y
+			a.n++
+		}
+	}
+
+	centers := make(map[string]map[string][2]float64)
+	for mapName, bySite := range sums {
+		centers[mapName] = make(map[string][2]float64)
+		for site, a := range bySite {
+			centers[mapName][site] = [2]float64{a.x / float64(a.n), a.y / float64(a.n)}
+		}
+	}
+	return centers
+}
+
+// classifySite returns the bombsite closest to (x, y), or "" if the point
+// is not reasonably close to any known site.
+func classifySite(centers map[string][2]float64, x, y float64) string {
+	const maxDist = 1200.0
+
+	best := ""
+	bestDist := math.MaxFloat64
+	for site, c := range centers {
+		dx, dy := c[0]-x, c[1]-y
+		if d := math.Sqrt(dx*dx + dy*dy); d < bestDist {
+			bestDist = d
+			best = site
+		}
+	}
+	if bestDist > maxDist {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestBuildScoutingReport(t *testing.T) {
+	roster := []uint64{1, 2, 3}
+
+	players := func(side string, places ...string) []models.PlayerState {
+		var out []models.PlayerState
+		for i, id := range roster {
+			out = append(out, models.PlayerState{SteamID: id, Team: side, IsAlive: true, Place: places[i], X: 1000, Y: 1000})
+		}
+		return append(out, models.PlayerState{SteamID: 9, Team: opposite(side), IsAlive: true})
+	}
+
+	match := &models.Match{
+		ID:  "m1",
+		Map: "de_mirage",
+		Teams: models.Teams{
+			CT: models.TeamInfo{Name: "Opponents", Players: []models.PlayerInfo{{SteamID: 1}, {SteamID: 2}, {SteamID: 3}}},
+			T:  models.TeamInfo{Name: "Us", Players: []models.PlayerInfo{{SteamID: 9}}},
+		},
+		Rounds: []models.Round{
+			{
+				Number:    1,
+				Winner:    "t",
+				Plant:     &models.BombEvent{TimeInRound: 40, Site: "A", X: 1000, Y: 1000},
+				Snapshots: []models.Snapshot{{TimeInRound: 20, Players: players("t", "Ramp", "Ramp", "Palace")}},
+				Grenades: []models.GrenadeEvent{
+					{Type: "smoke", Thrower: 1, ThrowTime: 30, DetonateX: 1000, DetonateY: 1000},
+					{Type: "flash", Thrower: 2, ThrowTime: 10, DetonateX: 1000, DetonateY: 1000},
+				},
+			},
+			{
+				Number:    13,
+				Winner:    "t",
+				Snapshots: []models.Snapshot{{TimeInRound: 20, Players: players("ct", "BombsiteA", "BombsiteA", "BombsiteA")}},
+			},
+		},
+	}
+
+	report := BuildScoutingReport(TeamSelector{Name: "opponents"}, []*models.Match{match})
+
+	if len(report.Matches) != 1 || len(report.Maps) != 1 {
+		t.Fatalf("expected one match on one map, got %+v", report)
+	}
+
+	rec := report.MapPool[0]
+	if rec.TRounds != 1 || rec.TRoundsWon != 1 || rec.CTRounds != 1 || rec.CTRoundsWon != 0 || rec.MatchesWon != 0 {
+		t.Errorf("unexpected map record: %+v", rec)
+	}
+
+	m := report.Maps[0]
+	if len(m.PistolRounds) != 2 || m.PistolRounds[0].PlantSite != "A" {
+		t.Errorf("unexpected pistol rounds: %+v", m.PistolRounds)
+	}
+	if len(m.TDefaults) != 2 || m.TDefaults[0].Place != "Palace" || m.TDefaults[1].AvgPlayers != 2 {
+		t.Errorf("unexpected T defaults: %+v", m.TDefaults)
+	}
+	if len(m.Executes) != 1 || m.Executes[0].Site != "A" || m.Executes[0].AvgUtility != 1 {
+		t.Errorf("unexpected executes: %+v", m.Executes)
+	}
+	if m.CT.StackRounds["A"] != 1 || m.CT.StackRate != 1 {
+		t.Errorf("unexpected CT tendencies: %+v", m.CT)
+	}
+
+	md := report.Markdown()
+	for _, want := range []string{"# Scouting report: opponents", "## de_mirage", "### Executes"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q", want)
+		}
+	}
+
+	if BuildScoutingReport(TeamSelector{Players: []uint64{42}}, []*models.Match{match}).Matches != nil {
+		t.Error("expected unknown roster to match nothing")
+	}
+}
+
+func opposite(side string) string {
+	if side == "t" {
+		return "ct"
+	}
+	return "t"
+}
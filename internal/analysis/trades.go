@@ -0,0 +1,155 @@
+package analysis
+
+import (
+	"sort"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// DefaultTradeWindow is how many seconds a teammate has to avenge a death
+// for it to count as traded.
+const DefaultTradeWindow = 5.0
+
+// PlayerTradeStats summarizes how often a player gets traded and trades
+// for their teammates.
+type PlayerTradeStats struct {
+	SteamID         uint64  `json:"steamId"`
+	Kills           int     `json:"kills"`
+	Deaths          int     `json:"deaths"`
+	Refrags         int     `json:"refrags"`
+	TradedDeaths    int     `json:"tradedDeaths"`
+	UntradedDeaths  int     `json:"untradedDeaths"`
+	TradedDeathRate float64 `json:"tradedDeathRate"`
+	RefragRate      float64 `json:"refragRate"`
+	AvgTimeToTrade  float64 `json:"avgTimeToTrade"`
+	AvgRefragTime   float64 `json:"avgRefragTime"`
+}
+
+// trade pairs a kill with the refrag that avenged it.
+type trade struct {
+	kill, refrag int
+	delay        float64
+}
+
+// MarkTrades sets the Traded, TradeTime and Refrag fields of every kill in
+// the match using the given trade window in seconds.
+func MarkTrades(match *models.Match, window float64) {
+	for i := range match.Rounds {
+		kills := match.Rounds[i].Kills
+		for j := range kills {
+			kills[j].Traded, kills[j].TradeTime, kills[j].Refrag = false, 0, false
+		}
+		for _, t := range roundTrades(&match.Rounds[i], window) {
+			kills[t.kill].Traded = true
+			kills[t.kill].TradeTime = t.delay
+			kills[t.refrag].Refrag = true
+		}
+	}
+}
+
+// roundTrades finds every kill whose attacker was killed by a teammate of
+// the victim within window seconds.
+func roundTrades(r *models.Round, window float64) []trade {
+	sides := RoundSides(r)
+	kills := r.Kills
+
+	var trades []trade
+	for i, k := range kills {
+		victimSide, ok := sides[k.Victim]
+		if !ok || k.Attacker == 0 || sides[k.Attacker] == victimSide {
+			continue
+		}
+
+		for j := i + 1; j < len(kills); j++ {
+			next := kills[j]
+			delay := next.TimeInRound - k.TimeInRound
+			if delay > window {
+				break
+			}
+			if next.Victim == k.Attacker && sides[next.Attacker] == victimSide && next.Attacker != k.Victim {
+				trades = append(trades, trade{kill: i, refrag: j, delay: delay})
+				break
+			}
+		}
+	}
+	return trades
+}
+
+// TradeStats computes per-player trade statistics with the given trade
+// window in seconds, sorted by SteamID.
+func TradeStats(match *models.Match, window float64) []PlayerTradeStats {
+	type acc struct {
+		PlayerTradeStats
+		tradeTime  float64
+		refragTime float64
+	}
+	stats := make(map[uint64]*acc)
+	get := func(id uint64) *acc {
+		a, ok := stats[id]
+		if !ok {
+			a = &acc{PlayerTradeStats: PlayerTradeStats{SteamID: id}}
+			stats[id] = a
+		}
+		return a
+	}
+
+	for i := range match.Rounds {
+		r := &match.Rounds[i]
+		sides := RoundSides(r)
+
+		// One kill can avenge several deaths, e.g. of both players its
+		// victim had just killed; it counts as a single refrag, taking the
+		// average delay of the trades it made.
+		traded := make(map[int]float64)
+		var refrags []int
+		refragDelays := make(map[int][]float64)
+		for _, t := range roundTrades(r, window) {
+			traded[t.kill] = t.delay
+			if _, ok := refragDelays[t.refrag]; !ok {
+				refrags = append(refrags, t.refrag)
+			}
+			refragDelays[t.refrag] = append(refragDelays[t.refrag], t.delay)
+		}
+		for _, j := range refrags {
+			var sum float64
+			for _, d := range refragDelays[j] {
+				sum += d
+			}
+			a := get(r.Kills[j].Attacker)
+			a.Refrags++
+			a.refragTime += sum / float64(len(refragDelays[j]))
+		}
+
+		for j, k := range r.Kills {
+			if k.Victim != 0 {
+				v := get(k.Victim)
+				v.Deaths++
+				if delay, ok := traded[j]; ok {
+					v.TradedDeaths++
+					v.tradeTime += delay
+				} else {
+					v.UntradedDeaths++
+				}
+			}
+			if k.Attacker != 0 && sides[k.Attacker] != sides[k.Victim] {
+				get(k.Attacker).Kills++
+			}
+		}
+	}
+
+	out := make([]PlayerTradeStats, 0, len(stats))
+	for _, a := range stats {
+		s := a.PlayerTradeStats
+		s.TradedDeathRate = ratio(s.TradedDeaths, s.Deaths)
+		s.RefragRate = ratio(s.Refrags, s.Kills)
+		if s.TradedDeaths > 0 {
+			s.AvgTimeToTrade = a.tradeTime / float64(s.TradedDeaths)
+		}
+		if s.Refrags > 0 {
+			s.AvgRefragTime = a.refragTime / float64(s.Refrags)
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SteamID < out[j].SteamID })
+	return out
+}
@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestMarkTrades(t *testing.T) {
+	// CT 1, 2 vs T 3, 4.
+	snap := models.Snapshot{Players: []models.PlayerState{
+		{SteamID: 1, Team: "ct"}, {SteamID: 2, Team: "ct"},
+		{SteamID: 3, Team: "t"}, {SteamID: 4, Team: "t"},
+	}}
+
+	match := &models.Match{Rounds: []models.Round{{
+		Snapshots: []models.Snapshot{snap},
+		Kills: []models.KillEvent{
+			{TimeInRound: 10, Attacker: 3, Victim: 1}, // traded by 2
+			{TimeInRound: 12, Attacker: 2, Victim: 3}, // refrag
+			{TimeInRound: 30, Attacker: 4, Victim: 2}, // untraded
+		},
+	}}}
+
+	MarkTrades(match, DefaultTradeWindow)
+
+	kills := match.Rounds[0].Kills
+	if !kills[0].Traded || kills[0].TradeTime != 2 || kills[0].Refrag {
+		t.Errorf("first kill should be traded after 2s: %+v", kills[0])
+	}
+	if kills[1].Traded || !kills[1].Refrag {
+		t.Errorf("second kill should be a refrag: %+v", kills[1])
+	}
+	if kills[2].Traded || kills[2].Refrag {
+		t.Errorf("third kill should be untraded: %+v", kills[2])
+	}
+
+	stats := TradeStats(match, DefaultTradeWindow)
+	byID := make(map[uint64]PlayerTradeStats)
+	for _, s := range stats {
+		byID[s.SteamID] = s
+	}
+
+	if s := byID[1]; s.Deaths != 1 || s.TradedDeaths != 1 || s.AvgTimeToTrade != 2 {
+		t.Errorf("unexpected stats for traded player: %+v", s)
+	}
+	if s := byID[2]; s.Refrags != 1 || s.RefragRate != 1 || s.UntradedDeaths != 1 || s.AvgRefragTime != 2 {
+		t.Errorf("unexpected stats for refragging player: %+v", s)
+	}
+
+	MarkTrades(match, 1)
+	if match.Rounds[0].Kills[0].Traded {
+		t.Error("kill should not be traded with a 1s window")
+	}
+}
+
+func TestRefragAvengingTwoDeaths(t *testing.T) {
+	// T 4 opens on CT 1 and 2; CT 3 kills him, trading both deaths with
+	// one kill.
+	snap := models.Snapshot{Players: []models.PlayerState{
+		{SteamID: 1, Team: "ct"}, {SteamID: 2, Team: "ct"}, {SteamID: 3, Team: "ct"},
+		{SteamID: 4, Team: "t"},
+	}}
+	match := &models.Match{Rounds: []models.Round{{
+		Snapshots: []models.Snapshot{snap},
+		Kills: []models.KillEvent{
+			{TimeInRound: 10, Attacker: 4, Victim: 1},
+			{TimeInRound: 11, Attacker: 4, Victim: 2},
+			{TimeInRound: 13, Attacker: 3, Victim: 4},
+		},
+	}}}
+
+	var refragger PlayerTradeStats
+	for _, s := range TradeStats(match, DefaultTradeWindow) {
+		switch s.SteamID {
+		case 1, 2:
+			if s.TradedDeaths != 1 {
+				t.Errorf("player %d's death should be traded: %+v", s.SteamID, s)
+			}
+		case 3:
+			refragger = s
+		}
+	}
+	if refragger.Refrags != 1 || refragger.RefragRate != 1 || refragger.AvgRefragTime != 2.5 {
+		t.Errorf("one kill trading two deaths should be one refrag after 2.5s on average: %+v", refragger)
+	}
+}
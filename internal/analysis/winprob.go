@@ -0,0 +1,335 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+const (
+	roundTimeSeconds = 115.0
+	bombTimerSeconds = 40.0
+	defuseKitSeconds = 5.0
+	defuseSeconds    = 10.0
+
+	// Fitting uses at most one snapshot per this many seconds, whatever
+	// rate the match was parsed at, to keep neighbouring, nearly identical
+	// samples from dominating.
+	fitSampleSeconds = 1.0
+	fitIterations    = 500
+	fitLearningRate  = 0.5
+)
+
+// WinProbModel is a logistic model of the CT side's chance to win a round.
+// Each weight multiplies one CT-minus-T (or game state) feature; see
+// winState.features for their scaling.
+type WinProbModel struct {
+	Bias      float64 `json:"bias"`
+	Alive     float64 `json:"alive"`
+	HP        float64 `json:"hp"`
+	Equipment float64 `json:"equipment"`
+	Planted   float64 `json:"planted"`
+	BombTime  float64 `json:"bombTime"`
+	RoundTime float64 `json:"roundTime"`
+}
+
+// DefaultWinProbModel is a hand-tuned starting point used until a model has
+// been fitted on local matches.
+var DefaultWinProbModel = WinProbModel{
+	Bias:      0.6,
+	Alive:     0.55,
+	HP:        0.15,
+	Equipment: 0.08,
+	Planted:   -1.8,
+	BombTime:  0.8,
+	RoundTime: -1.0,
+}
+
+// LoadWinProbModel reads model weights written by SaveWinProbModel.
+func LoadWinProbModel(path string) (*WinProbModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading win probability model: %w", err)
+	}
+	var m WinProbModel
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("decoding win probability model: %w", err)
+	}
+	return &m, nil
+}
+
+func SaveWinProbModel(path string, m *WinProbModel) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (m *WinProbModel) weights() []float64 {
+	return []float64{m.Bias, m.Alive, m.HP, m.Equipment, m.Planted, m.BombTime, m.RoundTime}
+}
+
+func (m *WinProbModel) setWeights(w []float64) {
+	m.Bias, m.Alive, m.HP, m.Equipment, m.Planted, m.BombTime, m.RoundTime = w[0], w[1], w[2], w[3], w[4], w[5], w[6]
+}
+
+// winState is the part of a snapshot the model looks at.
+type winState struct {
+	aliveCT, aliveT int
+	hpCT, hpT       int
+	equipCT, equipT int
+	ctHasKit        bool
+	planted         bool
+	bombLeft        float64
+	roundLeft       float64
+	bombState       string
+}
+
+func newWinState(snap *models.Snapshot, plant *models.BombEvent) winState {
+	var s winState
+	for _, ps := range snap.Players {
+		if !ps.IsAlive {
+			continue
+		}
+		switch ps.Team {
+		case "ct":
+			s.aliveCT++
+			s.hpCT += ps.HP
+			s.equipCT += ps.EquipValue
+			s.ctHasKit = s.ctHasKit || ps.HasDefuser
+		case "t":
+			s.aliveT++
+			s.hpT += ps.HP
+			s.equipT += ps.EquipValue
+		}
+	}
+
+	if snap.Bomb != nil {
+		s.bombState = snap.Bomb.State
+	}
+	if plant != nil && snap.Tick >= plant.Tick {
+		s.planted = true
+		s.bombLeft = math.Max(0, bombTimerSeconds-(snap.TimeInRound-plant.TimeInRound))
+	} else {
+		s.roundLeft = math.Max(0, roundTimeSeconds-snap.TimeInRound)
+	}
+	return s
+}
+
+// terminal returns the outcome for states where the round is already
+// decided, and false otherwise.
+func (s winState) terminal() (float64, bool) {
+	switch {
+	case s.bombState == "defused":
+		return 1, true
+	case s.bombState == "exploded":
+		return 0, true
+	case s.aliveCT == 0:
+		return 0, true
+	case s.aliveT == 0 && !s.planted:
+		return 1, true
+	case s.aliveT == 0 && s.planted:
+		need := defuseSeconds
+		if s.ctHasKit {
+			need = defuseKitSeconds
+		}
+		if s.bombLeft >= need {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (s winState) features() []float64 {
+	planted, bombTime, roundTime := 0.0, 0.0, 0.0
+	if s.planted {
+		planted = 1
+		bombTime = s.bombLeft / bombTimerSeconds
+	} else {
+		roundTime = s.roundLeft / roundTimeSeconds
+	}
+	return []float64{
+		1,
+		float64(s.aliveCT - s.aliveT),
+		float64(s.hpCT-s.hpT) / 100,
+		float64(s.equipCT-s.equipT) / 1000,
+		planted,
+		bombTime,
+		roundTime,
+	}
+}
+
+// Predict returns the probability that CT wins from the given snapshot.
+func (m *WinProbModel) Predict(snap *models.Snapshot, plant *models.BombEvent) float64 {
+	s := newWinState(snap, plant)
+	if p, ok := s.terminal(); ok {
+		return p
+	}
+	return sigmoid(dot(m.weights(), s.features()))
+}
+
+// RoundWinProb evaluates the model at every snapshot of the round.
+func (m *WinProbModel) RoundWinProb(r *models.Round) []models.WinProbPoint {
+	plant := roundPlant(r)
+	points := make([]models.WinProbPoint, len(r.Snapshots))
+	for i := range r.Snapshots {
+		snap := &r.Snapshots[i]
+		points[i] = models.WinProbPoint{
+			Tick:        snap.Tick,
+			TimeInRound: snap.TimeInRound,
+			CT:          round3(m.Predict(snap, plant)),
+		}
+	}
+	return points
+}
+
+// AttachWinProb fills in the win probability series of every round.
+func (m *WinProbModel) AttachWinProb(match *models.Match) {
+	for i := range match.Rounds {
+		match.Rounds[i].WinProb = m.RoundWinProb(&match.Rounds[i])
+	}
+}
+
+// Swing is a kill or plant and how much it moved CT's win probability.
+type Swing struct {
+	Round       int     `json:"round"`
+	Tick        int     `json:"tick"`
+	TimeInRound float64 `json:"timeInRound"`
+	Type        string  `json:"type"`
+	Player      uint64  `json:"player"`
+	Victim      uint64  `json:"victim,omitempty"`
+	Before      float64 `json:"before"`
+	After       float64 `json:"after"`
+	Delta       float64 `json:"delta"`
+}
+
+// Swings returns every kill and plant in the match ordered by the size of
+// the change in win probability they caused, largest first.
+func (m *WinProbModel) Swings(match *models.Match) []Swing {
+	var swings []Swing
+	for i := range match.Rounds {
+		r := &match.Rounds[i]
+		series := r.WinProb
+		if len(series) != len(r.Snapshots) {
+			series = m.RoundWinProb(r)
+		}
+
+		for _, k := range r.Kills {
+			if sw, ok := swingAt(series, k.Tick); ok {
+				sw.Round, sw.Type, sw.Player, sw.Victim = r.Number, "kill", k.Attacker, k.Victim
+				swings = append(swings, sw)
+			}
+		}
+		if plant := roundPlant(r); plant != nil {
+			if sw, ok := swingAt(series, plant.Tick); ok {
+				sw.Round, sw.Type, sw.Player = r.Number, "plant", plant.Player
+				swings = append(swings, sw)
+			}
+		}
+	}
+
+	sort.SliceStable(swings, func(i, j int) bool {
+		return math.Abs(swings[i].Delta) > math.Abs(swings[j].Delta)
+	})
+	return swings
+}
+
+// swingAt compares the last point before tick with the first at or after it.
+func swingAt(series []models.WinProbPoint, tick int) (Swing, bool) {
+	idx := sort.Search(len(series), func(i int) bool { return series[i].Tick >= tick })
+	if idx == 0 || idx == len(series) {
+		return Swing{}, false
+	}
+	before, after := series[idx-1], series[idx]
+	return Swing{
+		Tick:        tick,
+		TimeInRound: after.TimeInRound,
+		Before:      before.CT,
+		After:       after.CT,
+		Delta:       round3(after.CT - before.CT),
+	}, true
+}
+
+// FitWinProbModel fits the model's weights by logistic regression on the
+// snapshots of the given matches, labelled with each round's winner.
+// Already-decided states are left out since they bypass the model anyway.
+func FitWinProbModel(matches []*models.Match) (*WinProbModel, int) {
+	var xs [][]float64
+	var ys []float64
+
+	for _, m := range matches {
+		for i := range m.Rounds {
+			r := &m.Rounds[i]
+			if r.Winner != "ct" && r.Winner != "t" {
+				continue
+			}
+			label := 0.0
+			if r.Winner == "ct" {
+				label = 1
+			}
+
+			plant := roundPlant(r)
+			next := math.Inf(-1)
+			for j := range r.Snapshots {
+				if r.Snapshots[j].TimeInRound < next {
+					continue
+				}
+				next = r.Snapshots[j].TimeInRound + fitSampleSeconds
+				s := newWinState(&r.Snapshots[j], plant)
+				if _, ok := s.terminal(); ok {
+					continue
+				}
+				xs = append(xs, s.features())
+				ys = append(ys, label)
+			}
+		}
+	}
+
+	model := DefaultWinProbModel
+	if len(xs) == 0 {
+		return &model, 0
+	}
+
+	w := model.weights()
+	grad := make([]float64, len(w))
+	n := float64(len(xs))
+	for iter := 0; iter < fitIterations; iter++ {
+		for k := range grad {
+			grad[k] = 0
+		}
+		for i, x := range xs {
+			diff := sigmoid(dot(w, x)) - ys[i]
+			for k := range x {
+				grad[k] += diff * x[k]
+			}
+		}
+		for k := range w {
+			w[k] -= fitLearningRate * grad[k] / n
+		}
+	}
+
+	model.setWeights(w)
+	return &model, len(xs)
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// winProbSnapshot returns a snapshot at time t with the given numbers of
+// full-health players alive on each side.
+func winProbSnapshot(t float64, aliveCT, aliveT int) models.Snapshot {
+	snap := models.Snapshot{Tick: int(t * 64), TimeInRound: t}
+	for i := 0; i < 5; i++ {
+		snap.Players = append(snap.Players,
+			models.PlayerState{SteamID: uint64(i + 1), Team: "ct", IsAlive: i < aliveCT, HP: 100},
+			models.PlayerState{SteamID: uint64(i + 6), Team: "t", IsAlive: i < aliveT, HP: 100},
+		)
+	}
+	return snap
+}
+
+func TestWinProbTerminal(t *testing.T) {
+	plant := &models.BombEvent{Tick: 30 * 64, TimeInRound: 30}
+
+	withBomb := func(snap models.Snapshot, state string) models.Snapshot {
+		snap.Bomb = &models.BombState{State: state}
+		return snap
+	}
+	withKit := func(snap models.Snapshot) models.Snapshot {
+		snap.Players[0].HasDefuser = true
+		return snap
+	}
+
+	tests := []struct {
+		name  string
+		snap  models.Snapshot
+		plant *models.BombEvent
+		want  float64
+	}{
+		{"bomb defused", withBomb(winProbSnapshot(60, 1, 3), "defused"), plant, 1},
+		{"bomb exploded", withBomb(winProbSnapshot(70, 4, 0), "exploded"), plant, 0},
+		{"CT eliminated", winProbSnapshot(50, 0, 2), nil, 0},
+		{"T eliminated before plant", winProbSnapshot(50, 1, 0), nil, 1},
+		{"T eliminated, time to defuse", winProbSnapshot(50, 1, 0), plant, 1},
+		{"T eliminated, too late without kit", winProbSnapshot(62, 1, 0), plant, 0},
+		{"T eliminated, just in time with kit", withKit(winProbSnapshot(62, 1, 0)), plant, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultWinProbModel.Predict(&tt.snap, tt.plant); got != tt.want {
+				t.Errorf("Predict = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWinProbMonotonicInAlive(t *testing.T) {
+	prev := 0.0
+	for ct := 1; ct <= 5; ct++ {
+		snap := winProbSnapshot(40, ct, 3)
+		p := DefaultWinProbModel.Predict(&snap, nil)
+		if p <= prev || p >= 1 {
+			t.Errorf("%dv3: CT win probability %v, want above %v and below 1", ct, p, prev)
+		}
+		prev = p
+	}
+
+	prev = 1.0
+	for tAlive := 1; tAlive <= 5; tAlive++ {
+		snap := winProbSnapshot(40, 3, tAlive)
+		p := DefaultWinProbModel.Predict(&snap, nil)
+		if p >= prev || p <= 0 {
+			t.Errorf("3v%d: CT win probability %v, want below %v and above 0", tAlive, p, prev)
+		}
+		prev = p
+	}
+}
+
+func TestFitWinProbModelRecoversSign(t *testing.T) {
+	// In these made-up matches the side with fewer players alive always
+	// wins, the opposite of the default model's assumption. Players carry
+	// no health or equipment so that alive count is the only difference.
+	round := func(number, aliveCT, aliveT int, winner string) models.Round {
+		r := models.Round{Number: number, Winner: winner}
+		for t := 0.0; t < 10; t += 0.5 {
+			snap := winProbSnapshot(t, aliveCT, aliveT)
+			for i := range snap.Players {
+				snap.Players[i].HP = 0
+			}
+			r.Snapshots = append(r.Snapshots, snap)
+		}
+		return r
+	}
+	match := &models.Match{Rounds: []models.Round{
+		round(1, 2, 4, "ct"),
+		round(2, 4, 2, "t"),
+		round(3, 3, 5, "ct"),
+		round(4, 5, 3, "t"),
+		// No winner: left out.
+		round(5, 5, 3, ""),
+	}}
+
+	model, samples := FitWinProbModel([]*models.Match{match})
+	// One sample per second of each of the four decided rounds.
+	if samples != 40 {
+		t.Errorf("fitted on %d samples, want 40", samples)
+	}
+	if model.Alive >= 0 {
+		t.Errorf("Alive weight = %v, want negative", model.Alive)
+	}
+
+	ahead, behind := winProbSnapshot(5, 4, 2), winProbSnapshot(5, 2, 4)
+	if model.Predict(&ahead, nil) >= model.Predict(&behind, nil) {
+		t.Error("fitted model still favours the side with more players alive")
+	}
+}
+
+func TestSwingAt(t *testing.T) {
+	series := []models.WinProbPoint{
+		{Tick: 100, TimeInRound: 1, CT: 0.5},
+		{Tick: 200, TimeInRound: 2, CT: 0.7},
+		{Tick: 300, TimeInRound: 3, CT: 0.2},
+	}
+
+	sw, ok := swingAt(series, 250)
+	if !ok {
+		t.Fatal("expected a swing between two points")
+	}
+	want := Swing{Tick: 250, TimeInRound: 3, Before: 0.7, After: 0.2, Delta: -0.5}
+	if sw != want {
+		t.Errorf("swing = %+v, want %+v", sw, want)
+	}
+
+	if sw, ok := swingAt(series, 200); !ok || sw.Before != 0.5 || sw.After != 0.7 {
+		t.Errorf("swing at a point's tick = %+v, %v; want 0.5 -> 0.7", sw, ok)
+	}
+	for _, tick := range []int{50, 100, 301} {
+		if _, ok := swingAt(series, tick); ok {
+			t.Errorf("swing at tick %d outside the series", tick)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Package annotation stores review notes and drawings attached to moments
+// in a match, so they stay with the demo rather than in a separate document.
+package annotation
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("annotation not found")
+	ErrInvalid  = errors.New("invalid annotation")
+)
+
+// Limits on what an annotation may hold.
+const (
+	maxText      = 4000
+	maxAuthor    = 100
+	maxTags      = 20
+	maxTag       = 40
+	maxColor     = 32
+	maxAreaPoint = 64
+)
+
+type Kind string
+
+const (
+	// KindNote is text, optionally pinned to a single point.
+	KindNote Kind = "note"
+	// KindArrow points from its first point to its second.
+	KindArrow Kind = "arrow"
+	// KindArea marks the polygon through its points.
+	KindArea Kind = "area"
+)
+
+// Point is a position in world coordinates, like player positions.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Annotation is a note or drawing at TimeInRound seconds into a round,
+// numbered like models.Round.
+type Annotation struct {
+	ID          string    `json:"id"`
+	MatchID     string    `json:"matchId"`
+	Round       int       `json:"round"`
+	TimeInRound float64   `json:"timeInRound"`
+	Kind        Kind      `json:"kind"`
+	Text        string    `json:"text"`
+	Points      []Point   `json:"points"`
+	Color       string    `json:"color,omitempty"`
+	Author      string    `json:"author"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// normalize trims the text fields, lowercases and deduplicates the tags,
+// and checks the annotation is complete for its kind.
+func (a *Annotation) normalize() error {
+	a.Text = strings.TrimSpace(a.Text)
+	a.Author = strings.TrimSpace(a.Author)
+	a.Color = strings.TrimSpace(a.Color)
+
+	var tags []string
+	for _, t := range a.Tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	a.Tags = tags
+	if a.Tags == nil {
+		a.Tags = []string{}
+	}
+	if a.Points == nil {
+		a.Points = []Point{}
+	}
+
+	switch {
+	case a.Round < 1:
+		return fmt.Errorf("%w: round must be 1 or more", ErrInvalid)
+	case a.TimeInRound < 0:
+		return fmt.Errorf("%w: timeInRound must not be negative", ErrInvalid)
+	case len(a.Text) > maxText:
+		return fmt.Errorf("%w: text is longer than %d bytes", ErrInvalid, maxText)
+	case len(a.Author) > maxAuthor:
+		return fmt.Errorf("%w: author is longer than %d bytes", ErrInvalid, maxAuthor)
+	case len(a.Color) > maxColor:
+		return fmt.Errorf("%w: color is longer than %d bytes", ErrInvalid, maxColor)
+	case len(a.Tags) > maxTags:
+		return fmt.Errorf("%w: more than %d tags", ErrInvalid, maxTags)
+	}
+	for _, t := range a.Tags {
+		if len(t) > maxTag {
+			return fmt.Errorf("%w: tag %q is longer than %d bytes", ErrInvalid, t, maxTag)
+		}
+	}
+
+	switch a.Kind {
+	case KindNote:
+		if a.Text == "" {
+			return fmt.Errorf("%w: a note needs text", ErrInvalid)
+		}
+		if len(a.Points) > 1 {
+			return fmt.Errorf("%w: a note has at most one point", ErrInvalid)
+		}
+	case KindArrow:
+		if len(a.Points) != 2 {
+			return fmt.Errorf("%w: an arrow has exactly two points", ErrInvalid)
+		}
+	case KindArea:
+		if len(a.Points) < 3 || len(a.Points) > maxAreaPoint {
+			return fmt.Errorf("%w: an area has 3 to %d points", ErrInvalid, maxAreaPoint)
+		}
+	default:
+		return fmt.Errorf("%w: kind must be note, arrow or area", ErrInvalid)
+	}
+	return nil
+}
+
+// Store keeps each match's annotations in a JSON file named after the
+// match, rewritten on every change.
+type Store struct {
+	dir string
+
+	mu sync.Mutex
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating annotation dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// List returns a match's annotations in playback order.
+func (s *Store) List(matchID string) ([]Annotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(matchID)
+}
+
+func (s *Store) Get(matchID, id string) (Annotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.load(matchID)
+	if err != nil {
+		return Annotation{}, err
+	}
+	i := indexOf(list, id)
+	if i < 0 {
+		return Annotation{}, ErrNotFound
+	}
+	return list[i], nil
+}
+
+// Create adds a to a match, assigning its ID and timestamps.
+func (s *Store) Create(matchID string, a Annotation) (Annotation, error) {
+	if err := a.normalize(); err != nil {
+		return Annotation{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.load(matchID)
+	if err != nil {
+		return Annotation{}, err
+	}
+	if a.ID, err = newID(); err != nil {
+		return Annotation{}, err
+	}
+	a.MatchID = matchID
+	a.CreatedAt = time.Now().UTC()
+	a.UpdatedAt = a.CreatedAt
+
+	if err := s.save(matchID, append(list, a)); err != nil {
+		return Annotation{}, err
+	}
+	return a, nil
+}
+
+// Update replaces an annotation's content, keeping its ID and creation
+// time, and its author unless a names one.
+func (s *Store) Update(matchID, id string, a Annotation) (Annotation, error) {
+	if err := a.normalize(); err != nil {
+		return Annotation{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.load(matchID)
+	if err != nil {
+		return Annotation{}, err
+	}
+	i := indexOf(list, id)
+	if i < 0 {
+		return Annotation{}, ErrNotFound
+	}
+	a.ID = id
+	a.MatchID = matchID
+	a.CreatedAt = list[i].CreatedAt
+	a.UpdatedAt = time.Now().UTC()
+	if a.Author == "" {
+		a.Author = list[i].Author
+	}
+	list[i] = a
+
+	if err := s.save(matchID, list); err != nil {
+		return Annotation{}, err
+	}
+	return a, nil
+}
+
+func (s *Store) Delete(matchID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.load(matchID)
+	if err != nil {
+		return err
+	}
+	i := indexOf(list, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return s.save(matchID, slices.Delete(list, i, i+1))
+}
+
+func (s *Store) path(matchID string) (string, error) {
+	if matchID == "" || matchID != filepath.Base(matchID) || strings.HasPrefix(matchID, ".") {
+		return "", fmt.Errorf("%w: invalid match id %q", ErrNotFound, matchID)
+	}
+	return filepath.Join(s.dir, matchID+".json"), nil
+}
+
+func (s *Store) load(matchID string) ([]Annotation, error) {
+	path, err := s.path(matchID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Annotation{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading annotations for %s: %w", matchID, err)
+	}
+
+	var list []Annotation
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("decoding annotations for %s: %w", matchID, err)
+	}
+	return list, nil
+}
+
+// save writes the list in playback order, removing the file once the last
+// annotation is deleted.
+func (s *Store) save(matchID string, list []Annotation) error {
+	path, err := s.path(matchID)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	slices.SortStableFunc(list, compare)
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing annotations for %s: %w", matchID, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// compare orders annotations by when they happen in the match.
+func compare(a, b Annotation) int {
+	return cmp.Or(
+		cmp.Compare(a.Round, b.Round),
+		cmp.Compare(a.TimeInRound, b.TimeInRound),
+		a.CreatedAt.Compare(b.CreatedAt),
+	)
+}
+
+func indexOf(list []Annotation, id string) int {
+	return slices.IndexFunc(list, func(a Annotation) bool { return a.ID == id })
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
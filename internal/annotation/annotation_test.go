@@ -0,0 +1,100 @@
+package annotation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCRUD(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	late, err := s.Create("m1", Annotation{Round: 2, TimeInRound: 40, Kind: KindNote, Text: "rotate earlier", Author: "coach", Tags: []string{"Rotation", "rotation "}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if late.ID == "" || late.MatchID != "m1" || len(late.Tags) != 1 || late.Tags[0] != "rotation" {
+		t.Fatalf("created: %+v", late)
+	}
+	early, err := s.Create("m1", Annotation{Round: 2, TimeInRound: 12.5, Kind: KindArrow, Points: []Point{{0, 0}, {100, 50}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening reads back what was written, in playback order.
+	s, _ = NewStore(dir)
+	list, err := s.List("m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != early.ID || list[1].ID != late.ID {
+		t.Fatalf("list = %+v", list)
+	}
+
+	updated, err := s.Update("m1", late.ID, Annotation{Round: 1, TimeInRound: 5, Kind: KindNote, Text: "moved"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.ID != late.ID || !updated.CreatedAt.Equal(late.CreatedAt) || updated.Text != "moved" || updated.Author != "coach" {
+		t.Fatalf("updated: %+v", updated)
+	}
+	if list, _ := s.List("m1"); list[0].ID != late.ID {
+		t.Fatal("update didn't reorder the list")
+	}
+
+	if err := s.Delete("m1", early.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("m1", early.ID); err != ErrNotFound {
+		t.Fatalf("get deleted: err = %v", err)
+	}
+	if err := s.Delete("m1", early.ID); err != ErrNotFound {
+		t.Fatalf("delete twice: err = %v", err)
+	}
+	if list, _ := s.List("other"); len(list) != 0 {
+		t.Fatalf("other match has %d annotations", len(list))
+	}
+}
+
+func TestValidation(t *testing.T) {
+	s, _ := NewStore(t.TempDir())
+	for _, a := range []Annotation{
+		{Round: 0, Kind: KindNote, Text: "x"},
+		{Round: 1, TimeInRound: -1, Kind: KindNote, Text: "x"},
+		{Round: 1, Kind: KindNote},
+		{Round: 1, Kind: KindArrow, Points: []Point{{1, 1}}},
+		{Round: 1, Kind: KindArea, Points: []Point{{1, 1}, {2, 2}}},
+		{Round: 1, Kind: "circle", Text: "x"},
+	} {
+		if _, err := s.Create("m1", a); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Create(%+v): err = %v, want ErrInvalid", a, err)
+		}
+	}
+	if _, err := s.List("../m1"); err == nil {
+		t.Error("List accepted a path as the match id")
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	md := Markdown("Review", []Annotation{
+		{Round: 3, TimeInRound: 65, Kind: KindNote, Text: "late flash", Author: "coach", Tags: []string{"utility"}},
+		{Round: 3, TimeInRound: 70, Kind: KindArea, Points: []Point{{0, 0}, {100, 0}, {100, 100}, {0, 100}}},
+		{Round: 7, TimeInRound: 9, Kind: KindArrow, Points: []Point{{1, 2}, {3, 4}}, Text: "push"},
+	})
+	for _, want := range []string{
+		"# Review",
+		"## Round 3",
+		"- **1:05** Note: late flash (coach) `#utility`",
+		"- **1:10** Area around (50, 50)",
+		"## Round 7",
+		"- **0:09** Arrow from (1, 2) to (3, 4): push",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
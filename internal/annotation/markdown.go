@@ -0,0 +1,71 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown renders annotations as a review document, grouped by round in
+// playback order. Times are from the start of the round, as in the viewer.
+func Markdown(title string, list []Annotation) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	if len(list) == 0 {
+		b.WriteString("No annotations.\n")
+		return b.String()
+	}
+
+	round := 0
+	for i, a := range list {
+		if a.Round != round {
+			round = a.Round
+			fmt.Fprintf(&b, "## Round %d\n\n", round)
+		}
+		fmt.Fprintf(&b, "- **%s** %s", clock(a.TimeInRound), describe(a))
+		if a.Text != "" {
+			fmt.Fprintf(&b, ": %s", strings.ReplaceAll(a.Text, "\n", " "))
+		}
+		if a.Author != "" {
+			fmt.Fprintf(&b, " (%s)", a.Author)
+		}
+		for _, t := range a.Tags {
+			fmt.Fprintf(&b, " `#%s`", t)
+		}
+		b.WriteString("\n")
+
+		if i+1 == len(list) || list[i+1].Round != round {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// describe says what kind of annotation a is and where it is on the map.
+func describe(a Annotation) string {
+	switch a.Kind {
+	case KindArrow:
+		return fmt.Sprintf("Arrow from %s to %s", point(a.Points[0]), point(a.Points[1]))
+	case KindArea:
+		var c Point
+		for _, p := range a.Points {
+			c.X += p.X / float64(len(a.Points))
+			c.Y += p.Y / float64(len(a.Points))
+		}
+		return fmt.Sprintf("Area around %s", point(c))
+	default:
+		if len(a.Points) == 1 {
+			return fmt.Sprintf("Note at %s", point(a.Points[0]))
+		}
+		return "Note"
+	}
+}
+
+func point(p Point) string {
+	return fmt.Sprintf("(%.0f, %.0f)", p.X, p.Y)
+}
+
+func clock(seconds float64) string {
+	s := int(seconds)
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,192 @@
+// Package auth identifies API clients. Each workspace (a team) has its own
+// tokens and owns the matches uploaded with them; a share grants read-only
+// access to a single match to anyone holding its token.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Tokens shorter than this are rejected so that they can't be guessed.
+const minTokenLength = 16
+
+// Config lists the workspaces and their tokens, usually loaded from a file:
+//
+//	{"workspaces": [
+//	  {"id": "main", "name": "Main roster", "tokens": [
+//	    {"name": "coach", "token": "5b0c..."}
+//	  ]}
+//	]}
+type Config struct {
+	Workspaces []Workspace `json:"workspaces"`
+
+	// The workspace that owns matches uploaded before authentication was
+	// turned on. Without one, nobody can reach those matches.
+	DefaultWorkspace string `json:"defaultWorkspace"`
+}
+
+type Workspace struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Tokens []Token `json:"tokens"`
+}
+
+// Token is a credential for a workspace. Name says who or what holds it,
+// for logs.
+type Token struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading auth config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("decoding auth config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Identity is who a request was made by. Share is set instead of Name when
+// the request used a share token, and limits it to reading that match.
+type Identity struct {
+	Workspace string
+	Name      string
+	Share     *Share
+}
+
+// CanRead reports whether the identity may read a match owned by
+// workspace.
+func (id Identity) CanRead(matchID, workspace string) bool {
+	if id.Share != nil {
+		return id.Share.MatchID == matchID
+	}
+	return id.Workspace == workspace
+}
+
+// CanWrite reports whether the identity may change a match owned by
+// workspace, e.g. annotate or share it. Shares are read-only.
+func (id Identity) CanWrite(workspace string) bool {
+	return id.Share == nil && id.Workspace == workspace
+}
+
+// Authenticator checks tokens against the configured workspaces and the
+// share store.
+type Authenticator struct {
+	// Keyed by the token's hash, so that lookups don't compare the secret
+	// itself.
+	tokens map[[sha256.Size]byte]Identity
+	shares *Shares
+
+	defaultWorkspace string
+}
+
+// New validates cfg and returns an authenticator for it. With no
+// workspaces configured, authentication is disabled: Enabled reports false
+// and the server stays open, as it was before workspaces existed.
+func New(cfg *Config, shares *Shares) (*Authenticator, error) {
+	a := &Authenticator{tokens: make(map[[sha256.Size]byte]Identity), shares: shares}
+	if cfg == nil {
+		return a, nil
+	}
+	a.defaultWorkspace = cfg.DefaultWorkspace
+
+	seen := make(map[string]bool)
+	for _, ws := range cfg.Workspaces {
+		if ws.ID == "" {
+			return nil, errors.New("auth config: workspace without an id")
+		}
+		if seen[ws.ID] {
+			return nil, fmt.Errorf("auth config: duplicate workspace %q", ws.ID)
+		}
+		seen[ws.ID] = true
+
+		for _, t := range ws.Tokens {
+			if len(t.Token) < minTokenLength {
+				return nil, fmt.Errorf("auth config: token %q in workspace %q is shorter than %d characters", t.Name, ws.ID, minTokenLength)
+			}
+			key := sha256.Sum256([]byte(t.Token))
+			if _, dup := a.tokens[key]; dup {
+				return nil, fmt.Errorf("auth config: token %q in workspace %q is used twice", t.Name, ws.ID)
+			}
+			a.tokens[key] = Identity{Workspace: ws.ID, Name: t.Name}
+		}
+	}
+	return a, nil
+}
+
+// Enabled reports whether any tokens are configured.
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0
+}
+
+// Owner returns the workspace a match belongs to, given the workspace it
+// was stored with.
+func (a *Authenticator) Owner(workspace string) string {
+	if workspace == "" {
+		return a.defaultWorkspace
+	}
+	return workspace
+}
+
+// Authenticate returns the identity a token belongs to: a workspace token,
+// or a share that hasn't expired.
+func (a *Authenticator) Authenticate(token string) (Identity, bool) {
+	if token == "" {
+		return Identity{}, false
+	}
+	if id, ok := a.tokens[sha256.Sum256([]byte(token))]; ok {
+		return id, true
+	}
+	if a.shares != nil {
+		if sh, ok := a.shares.Lookup(token); ok {
+			return Identity{Workspace: sh.Workspace, Share: &sh}, true
+		}
+	}
+	return Identity{}, false
+}
+
+// RequestToken returns the token a request carries, from an
+// "Authorization: Bearer" header or, for clients that can't set headers
+// such as EventSource and WebSocket, the "token" query parameter.
+func RequestToken(r *http.Request) string {
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	return r.URL.Query().Get("token")
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity stored by WithIdentity. Without one,
+// as when authentication is disabled, it's the zero Identity: workspace
+// "", which owns every match uploaded while authentication was off.
+func FromContext(ctx context.Context) Identity {
+	id, _ := ctx.Value(identityKey{}).(Identity)
+	return id
+}
+
+// NewToken returns a random token suitable for a workspace or a share.
+func NewToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
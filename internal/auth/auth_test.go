@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	coachToken = "coach-token-0123456789"
+	otherToken = "other-token-0123456789"
+)
+
+func testConfig() *Config {
+	return &Config{Workspaces: []Workspace{
+		{ID: "main", Tokens: []Token{{Name: "coach", Token: coachToken}}},
+		{ID: "academy", Tokens: []Token{{Name: "analyst", Token: otherToken}}},
+	}}
+}
+
+func TestAuthenticate(t *testing.T) {
+	a, err := New(testConfig(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Enabled() {
+		t.Fatal("not enabled with tokens configured")
+	}
+
+	id, ok := a.Authenticate(coachToken)
+	if !ok || id.Workspace != "main" || id.Name != "coach" {
+		t.Fatalf("coach token: %+v, %v", id, ok)
+	}
+	if !id.CanRead("m1", "main") || id.CanRead("m1", "academy") || !id.CanWrite("main") {
+		t.Error("workspace token should reach only its own workspace's matches")
+	}
+	for _, bad := range []string{"", "nope", coachToken + "x"} {
+		if _, ok := a.Authenticate(bad); ok {
+			t.Errorf("Authenticate(%q) succeeded", bad)
+		}
+	}
+
+	if a, _ := New(nil, nil); a.Enabled() {
+		t.Error("enabled without any tokens")
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	for name, cfg := range map[string]*Config{
+		"short token":  {Workspaces: []Workspace{{ID: "a", Tokens: []Token{{Token: "short"}}}}},
+		"no id":        {Workspaces: []Workspace{{Tokens: []Token{{Token: coachToken}}}}},
+		"duplicate id": {Workspaces: []Workspace{{ID: "a"}, {ID: "a"}}},
+		"reused token": {Workspaces: []Workspace{
+			{ID: "a", Tokens: []Token{{Token: coachToken}}},
+			{ID: "b", Tokens: []Token{{Token: coachToken}}},
+		}},
+	} {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestShares(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shares.json")
+	shares, err := OpenShares(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := New(testConfig(), shares)
+
+	sh, err := shares.Create("m1", "main", "coach", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := shares.Create("m1", "main", "coach", time.Now().Add(-time.Minute))
+
+	id, ok := a.Authenticate(sh.Token)
+	if !ok || id.Share == nil || id.Workspace != "main" {
+		t.Fatalf("share token: %+v, %v", id, ok)
+	}
+	if !id.CanRead("m1", "main") || id.CanRead("m2", "main") || id.CanWrite("main") {
+		t.Error("share should only read its own match")
+	}
+	if _, ok := a.Authenticate(expired.Token); ok {
+		t.Error("expired share accepted")
+	}
+
+	// Shares survive a restart, and revoking one takes effect at once.
+	shares, _ = OpenShares(path)
+	if got := shares.List("m1"); len(got) != 2 {
+		t.Fatalf("after reopen: %d shares, want 2", len(got))
+	}
+	if err := shares.Revoke("m1", sh.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := shares.Lookup(sh.Token); ok {
+		t.Error("revoked share still valid")
+	}
+	if err := shares.Revoke("m1", sh.Token); err != ErrShareNotFound {
+		t.Errorf("revoke twice: err = %v", err)
+	}
+}
+
+func TestRequestToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/match/m1?token=from-query", nil)
+	if got := RequestToken(r); got != "from-query" {
+		t.Errorf("query token = %q", got)
+	}
+	r.Header.Set("Authorization", "Bearer from-header")
+	if got := RequestToken(r); got != "from-header" {
+		t.Errorf("header token = %q", got)
+	}
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrShareNotFound = errors.New("share not found")
+
+// Share is a read-only link to one match. ExpiresAt is zero for shares
+// that don't expire.
+type Share struct {
+	Token     string    `json:"token"`
+	MatchID   string    `json:"matchId"`
+	Workspace string    `json:"workspace"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+}
+
+func (sh *Share) expired(now time.Time) bool {
+	return !sh.ExpiresAt.IsZero() && !now.Before(sh.ExpiresAt)
+}
+
+// Shares keeps every share in one JSON file, rewritten on each change.
+type Shares struct {
+	path string
+
+	mu     sync.Mutex
+	shares []Share
+}
+
+// OpenShares loads the shares stored at path, which needn't exist yet.
+func OpenShares(path string) (*Shares, error) {
+	s := &Shares{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading shares: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.shares); err != nil {
+		return nil, fmt.Errorf("decoding shares %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Create shares a match until expiresAt, or indefinitely if it's zero.
+func (s *Shares) Create(matchID, workspace, createdBy string, expiresAt time.Time) (Share, error) {
+	token, err := NewToken()
+	if err != nil {
+		return Share{}, err
+	}
+	sh := Share{
+		Token:     token,
+		MatchID:   matchID,
+		Workspace: workspace,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shares = append(s.shares, sh)
+	if err := s.saveLocked(); err != nil {
+		s.shares = s.shares[:len(s.shares)-1]
+		return Share{}, err
+	}
+	return sh, nil
+}
+
+// Lookup returns the unexpired share with the given token.
+func (s *Shares) Lookup(token string) (Share, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sh := range s.shares {
+		if sh.Token == token && !sh.expired(time.Now()) {
+			return sh, true
+		}
+	}
+	return Share{}, false
+}
+
+// List returns a match's shares, expired ones included, oldest first.
+func (s *Shares) List(matchID string) []Share {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Share{}
+	for _, sh := range s.shares {
+		if sh.MatchID == matchID {
+			list = append(list, sh)
+		}
+	}
+	return list
+}
+
+// Revoke deletes a match's share.
+func (s *Shares) Revoke(matchID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.shares, func(sh Share) bool { return sh.MatchID == matchID && sh.Token == token })
+	if i < 0 {
+		return ErrShareNotFound
+	}
+	s.shares = slices.Delete(s.shares, i, i+1)
+	return s.saveLocked()
+}
+
+func (s *Shares) saveLocked() error {
+	data, err := json.Marshal(s.shares)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing shares: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,131 @@
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeRecording records a small fake broadcast to a file.
+func writeRecording(t *testing.T, fragments []RecordedFragment) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "match.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rec := NewRecorder(f)
+	for _, frag := range fragments {
+		rec.Add(frag.Number, frag.Field, frag.Data)
+	}
+	if err := rec.Finish(Sync{Tps: 64, Map: "de_mirage", Protocol: 5}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	path := writeRecording(t, []RecordedFragment{
+		{Number: 3, Field: "start", Data: []byte("start;")},
+		{Number: 5, Field: "full", Data: []byte("full5;")},
+		{Number: 5, Field: "delta", Data: []byte("delta5;")},
+		{Number: 6, Field: "delta", Data: []byte("delta6;")},
+	})
+	rec, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Sync.Map != "de_mirage" || len(rec.Fragments) != 4 {
+		t.Fatalf("loaded %+v", rec)
+	}
+
+	srv := httptest.NewServer(NewReplay(rec, 1))
+	defer srv.Close()
+
+	// Record what the reader fetches, to check it can be replayed again.
+	var again bytes.Buffer
+	recorder := NewRecorder(&again)
+	r, err := Open(context.Background(), srv.URL+"/s1t2", Options{
+		Timeout:    time.Second,
+		OnFragment: recorder.Add,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Sync().SignupFragment != 3 || r.Sync().Fragment != 5 {
+		t.Fatalf("sync = %+v", r.Sync())
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "start;full5;delta5;delta6;"; string(data) != want {
+		t.Errorf("stream = %q, want %q", data, want)
+	}
+	if err := recorder.Finish(r.Sync()); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.manifest) != 4 {
+		t.Errorf("recorded %d fragments, want 4", len(recorder.manifest))
+	}
+}
+
+func TestReadStopsOnCancel(t *testing.T) {
+	path := writeRecording(t, []RecordedFragment{
+		{Number: 1, Field: "start", Data: []byte("s")},
+		{Number: 1, Field: "full", Data: []byte("f")},
+	})
+	rec, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewReplay(rec, 1))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := Open(ctx, srv.URL+"/token", Options{Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadFull(r, make([]byte, 2))
+
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := r.Read(make([]byte, 1)); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestOpenRefusesOversizedAndStalledRelays(t *testing.T) {
+	stall := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/huge/sync":
+			w.Write(bytes.Repeat([]byte(" "), maxSyncSize+1))
+		case "/stalled/sync":
+			<-stall
+		}
+	}))
+	defer srv.Close()
+	defer close(stall)
+
+	if _, err := Open(context.Background(), srv.URL+"/huge", Options{}); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("oversized sync: err = %v", err)
+	}
+
+	start := time.Now()
+	_, err := Open(context.Background(), srv.URL+"/stalled", Options{RequestTimeout: 100 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Errorf("stalled relay: err = %v after %v", err, time.Since(start))
+	}
+}
@@ -0,0 +1,217 @@
+// Package broadcast follows CS2 HTTP broadcasts (CSTV+). A relay's /sync
+// endpoint says where the live stream is; the start fragment, a full
+// keyframe and every delta fragment after it are then read back to back as
+// one stream, which the demo parser consumes as it arrives.
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Sync is a relay's answer to /sync.
+type Sync struct {
+	Tick             int     `json:"tick"`
+	EndTick          int     `json:"endtick"`
+	MaxTick          int     `json:"maxtick"`
+	RtDelay          float64 `json:"rtdelay"`
+	RcvAge           float64 `json:"rcvage"`
+	Fragment         int     `json:"fragment"`
+	SignupFragment   int     `json:"signup_fragment"`
+	Tps              int     `json:"tps"`
+	KeyframeInterval int     `json:"keyframe_interval"`
+	Map              string  `json:"map"`
+	Protocol         int     `json:"protocol"`
+	TokenRedirect    string  `json:"token_redirect,omitempty"`
+}
+
+// Options configure a Reader. The zero value is usable.
+type Options struct {
+	// Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// How long to wait for the next fragment before treating the broadcast
+	// as over. Defaults to 30 seconds.
+	Timeout time.Duration
+
+	// How long a single request to the relay may take, including reading
+	// its body. Defaults to 30 seconds.
+	RequestTimeout time.Duration
+
+	// Called with each fragment as it's fetched, if set. Used to record
+	// broadcasts for replay.
+	OnFragment func(number int, field string, data []byte)
+}
+
+// Polling interval bounds while waiting for a fragment the relay doesn't
+// have yet. Fragments are a few seconds long.
+const (
+	minPoll = 500 * time.Millisecond
+	maxPoll = 4 * time.Second
+)
+
+// Largest responses read from a relay. Fragments are a few seconds of the
+// match, and the sync response a short JSON object.
+const (
+	maxSyncSize     = 64 << 10
+	maxFragmentSize = 16 << 20
+)
+
+// errNotReady means the relay doesn't have a fragment yet.
+var errNotReady = errors.New("fragment not available yet")
+
+// Reader reads a broadcast as a continuous demo stream.
+type Reader struct {
+	ctx  context.Context
+	base string
+	sync Sync
+	opts Options
+
+	next int
+	buf  bytes.Buffer
+}
+
+// Open syncs with the relay at baseURL (including the broadcast token, e.g.
+// http://localhost:8080/s123t456) and fetches the start and keyframe
+// fragments. Reads fail with ctx's error once it's cancelled.
+func Open(ctx context.Context, baseURL string, opts Options) (*Reader, error) {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = 30 * time.Second
+	}
+	r := &Reader{ctx: ctx, base: strings.TrimSuffix(baseURL, "/"), opts: opts}
+
+	data, err := r.get(r.base+"/sync", maxSyncSize)
+	if errors.Is(err, errNotReady) {
+		return nil, errors.New("broadcast has not started yet")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.sync); err != nil {
+		return nil, fmt.Errorf("decoding sync: %w", err)
+	}
+	if r.sync.TokenRedirect != "" {
+		if r.base, err = url.JoinPath(r.base, r.sync.TokenRedirect); err != nil {
+			return nil, fmt.Errorf("following token redirect: %w", err)
+		}
+	}
+
+	if err := r.fetch(r.sync.SignupFragment, "start"); err != nil {
+		return nil, err
+	}
+	if err := r.fetch(r.sync.Fragment, "full"); err != nil {
+		return nil, err
+	}
+	r.next = r.sync.Fragment
+	return r, nil
+}
+
+// SetContext replaces the context later reads are made under, so that a
+// reader opened under a short-lived context, such as a request's, can
+// outlive it.
+func (r *Reader) SetContext(ctx context.Context) {
+	r.ctx = ctx
+}
+
+// Sync returns what the relay reported when the reader connected.
+func (r *Reader) Sync() Sync {
+	return r.sync
+}
+
+// Read reads the stream, waiting for the relay to receive more fragments
+// as needed. It returns io.EOF once no new fragment arrived within the
+// timeout, which is how a finished broadcast looks.
+func (r *Reader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 {
+		if err := r.waitForDelta(); err != nil {
+			return 0, err
+		}
+	}
+	return r.buf.Read(p)
+}
+
+func (r *Reader) waitForDelta() error {
+	deadline := time.Now().Add(r.opts.Timeout)
+	poll := minPoll
+	for {
+		err := r.fetch(r.next, "delta")
+		if err == nil {
+			r.next++
+			return nil
+		}
+		if !errors.Is(err, errNotReady) {
+			return err
+		}
+		if time.Now().Add(poll).After(deadline) {
+			return io.EOF
+		}
+
+		select {
+		case <-time.After(poll):
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		}
+		poll = min(poll*2, maxPoll)
+	}
+}
+
+// fetch appends a fragment's field to the stream.
+func (r *Reader) fetch(number int, field string) error {
+	data, err := r.get(fmt.Sprintf("%s/%d/%s", r.base, number, field), maxFragmentSize)
+	if err != nil {
+		return err
+	}
+	if r.opts.OnFragment != nil {
+		r.opts.OnFragment(number, field, data)
+	}
+	r.buf.Write(data)
+	return nil
+}
+
+// get fetches u, failing if the response is larger than limit bytes.
+func (r *Reader) get(u string, limit int64) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.opts.RequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.opts.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", u, err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		// Relays answer 404 for fragments they haven't received yet, and
+		// 405 from /sync when the latest one isn't complete.
+		return nil, errNotReady
+	default:
+		return nil, fmt.Errorf("fetching %s: %s", u, res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", u, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("reading %s: larger than %d bytes", u, limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,152 @@
+package broadcast
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A recording is a zip holding the relay's sync response (sync.json), each
+// fragment as "<number>/<field>", and manifest.json listing the fragments
+// in the order they arrived with their arrival time in seconds.
+const (
+	syncEntry     = "sync.json"
+	manifestEntry = "manifest.json"
+)
+
+// RecordedFragment is one fragment of a recording.
+type RecordedFragment struct {
+	Number int     `json:"fragment"`
+	Field  string  `json:"field"`
+	At     float64 `json:"at"`
+	Data   []byte  `json:"-"`
+}
+
+// Recording is a broadcast captured by a Recorder.
+type Recording struct {
+	Sync      Sync
+	Fragments []RecordedFragment
+}
+
+// Recorder writes the fragments a Reader fetches to a recording. Its Add
+// method fits Options.OnFragment; Finish completes the recording once the
+// broadcast's sync is known.
+type Recorder struct {
+	mu       sync.Mutex
+	zw       *zip.Writer
+	start    time.Time
+	manifest []RecordedFragment
+	err      error
+}
+
+// NewRecorder starts a recording written to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{zw: zip.NewWriter(w), start: time.Now()}
+}
+
+// Add records a fragment. Errors are kept and returned by Finish.
+func (r *Recorder) Add(number int, field string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	f, err := r.create(fragmentKey(number, field))
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err != nil {
+		r.err = fmt.Errorf("recording fragment %d/%s: %w", number, field, err)
+		return
+	}
+	r.manifest = append(r.manifest, RecordedFragment{
+		Number: number,
+		Field:  field,
+		At:     time.Since(r.start).Seconds(),
+	})
+}
+
+// Finish writes s and the manifest and completes the zip. It doesn't close
+// the underlying writer.
+func (r *Recorder) Finish(s Sync) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+
+	for name, v := range map[string]any{syncEntry: s, manifestEntry: r.manifest} {
+		f, err := r.create(name)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(f).Encode(v); err != nil {
+			return err
+		}
+	}
+	return r.zw.Close()
+}
+
+func (r *Recorder) create(name string) (io.Writer, error) {
+	return r.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+}
+
+// LoadRecording reads a recording written by a Recorder.
+func LoadRecording(path string) (*Recording, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	read := func(name string) ([]byte, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("recording has no %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	var rec Recording
+	data, err := read(syncEntry)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &rec.Sync); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", syncEntry, err)
+	}
+	data, err = read(manifestEntry)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &rec.Fragments); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", manifestEntry, err)
+	}
+
+	for i := range rec.Fragments {
+		frag := &rec.Fragments[i]
+		if frag.Data, err = read(fragmentKey(frag.Number, frag.Field)); err != nil {
+			return nil, err
+		}
+	}
+	return &rec, nil
+}
+
+// fragmentKey identifies a fragment's field, as in the relay's URLs.
+func fragmentKey(number int, field string) string {
+	return strconv.Itoa(number) + "/" + field
+}
@@ -0,0 +1,94 @@
+package broadcast
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Replay serves a recording the way a broadcast relay serves a live match,
+// so live ingest can be tried without a game server. Each fragment becomes
+// available as long after the first /sync request as it arrived after the
+// keyframe the replay starts from, divided by the speed. Any token is
+// accepted.
+type Replay struct {
+	sync  Sync
+	speed float64
+	mux   *http.ServeMux
+
+	fragments map[string]RecordedFragment
+
+	// Arrival time of the first keyframe, which clients start from.
+	offset float64
+
+	startOnce sync.Once
+	start     time.Time
+}
+
+// NewReplay returns a handler replaying rec. A speed of 2 releases
+// fragments twice as fast as they were recorded; 0 means 1.
+func NewReplay(rec *Recording, speed float64) *Replay {
+	if speed <= 0 {
+		speed = 1
+	}
+	rp := &Replay{
+		speed:     speed,
+		mux:       http.NewServeMux(),
+		fragments: make(map[string]RecordedFragment, len(rec.Fragments)),
+	}
+	for _, frag := range rec.Fragments {
+		rp.fragments[fragmentKey(frag.Number, frag.Field)] = frag
+	}
+
+	// Clients are pointed at the start of the recording, rather than at
+	// the live edge like a relay would, so the whole match is replayed.
+	rp.sync = rec.Sync
+	rp.sync.TokenRedirect = ""
+	for _, frag := range rec.Fragments {
+		if frag.Field == "start" {
+			rp.sync.SignupFragment = frag.Number
+		}
+		if frag.Field == "full" {
+			rp.sync.Fragment = frag.Number
+			rp.offset = frag.At
+			break
+		}
+	}
+
+	rp.mux.HandleFunc("GET /{token}/sync", rp.handleSync)
+	rp.mux.HandleFunc("GET /{token}/{fragment}/{field}", rp.handleFragment)
+	return rp
+}
+
+func (rp *Replay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rp.mux.ServeHTTP(w, r)
+}
+
+func (rp *Replay) elapsed() float64 {
+	rp.startOnce.Do(func() { rp.start = time.Now() })
+	return time.Since(rp.start).Seconds() * rp.speed
+}
+
+func (rp *Replay) handleSync(w http.ResponseWriter, r *http.Request) {
+	rp.elapsed()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rp.sync)
+}
+
+func (rp *Replay) handleFragment(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(r.PathValue("fragment"))
+	if err != nil {
+		http.Error(w, "fragment is not a number", http.StatusMethodNotAllowed)
+		return
+	}
+	frag, ok := rp.fragments[fragmentKey(number, r.PathValue("field"))]
+	if !ok || frag.At-rp.offset > rp.elapsed() {
+		http.Error(w, "fragment not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(frag.Data)
+}
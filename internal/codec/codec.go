@@ -0,0 +1,604 @@
+// Package codec implements the compact binary match format.
+//
+// Snapshots make up nearly all of a match, and as JSON they repeat every
+// player's name, team and grenade list several times a second. The binary
+// format stores each distinct string once, refers to players through a
+// static table, and writes each player state as the fields that changed
+// since that player's previous snapshot in the round, with positions and
+// angles quantized to fixed point. Everything else about the match is kept
+// as a JSON header, so new fields on models.Match need no format change.
+//
+// Layout, with integers as (zigzag) varints:
+//
+//	"CS2M" version
+//	strings:  count, then (length, bytes) per string
+//	players:  count, then (steamID, name string) per player
+//	header:   length, then the match as JSON with snapshots removed
+//	rounds:   for each round in the header, its snapshot count and snapshots
+//
+// A snapshot is its tick and time (both deltas from the previous snapshot of
+// the round), the bomb as a change mask plus changed fields, and its player
+// count followed by (player index, change mask, changed fields) per player.
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+const (
+	// ContentType identifies the format in HTTP content negotiation.
+	ContentType = "application/vnd.cs2demo.match"
+
+	// Extension is used for match files in the store.
+	Extension = ".cs2m"
+
+	magic   = "CS2M"
+	version = 1
+
+	// Fixed-point scales: positions and angles to 0.1 units, times to the
+	// millisecond.
+	posScale  = 10
+	timeScale = 1000
+)
+
+// Player state change mask bits, in the order the fields are written.
+const (
+	fieldTeam = 1 << iota
+	fieldX
+	fieldY
+	fieldZ
+	fieldYaw
+	fieldHP
+	fieldArmor
+	fieldFlags
+	fieldWeapon
+	fieldGrenades
+	fieldMoney
+	fieldEquipValue
+	fieldFlash
+	fieldPlace
+)
+
+// Bomb change mask bits. bombPresent is set whenever the snapshot has a bomb.
+const (
+	bombPresent = 1 << iota
+	bombState
+	bombX
+	bombY
+	bombCarrier
+)
+
+const (
+	flagHelmet = 1 << iota
+	flagAlive
+	flagDefuser
+)
+
+var errCorrupt = errors.New("corrupt match data")
+
+// quantized is the fixed-point form of a PlayerState that deltas are taken
+// against.
+type quantized struct {
+	team, weapon, place int
+	x, y, z, yaw        int64
+	hp, armor           int64
+	flags               byte
+	grenades            []int
+	money, equip, flash int64
+}
+
+type quantizedBomb struct {
+	state   int
+	x, y    int64
+	carrier uint64
+}
+
+func quantize(v float64, scale float64) int64 {
+	return int64(math.Round(v * scale))
+}
+
+// Marshal encodes the match in the binary format.
+func Marshal(match *models.Match) ([]byte, error) {
+	e := &encoder{strings: make(map[string]int), players: make(map[models.PlayerInfo]int)}
+	// Index 0 is the empty string so zero-valued fields stay cheap.
+	e.intern("")
+
+	header := *match
+	header.Rounds = make([]models.Round, len(match.Rounds))
+	for i, r := range match.Rounds {
+		r.Snapshots = nil
+		header.Rounds[i] = r
+	}
+	headerJSON, err := json.Marshal(&header)
+	if err != nil {
+		return nil, fmt.Errorf("encoding match header: %w", err)
+	}
+
+	var body bytes.Buffer
+	for i := range match.Rounds {
+		e.writeRound(&body, match.Rounds[i].Snapshots)
+	}
+
+	out := bytes.NewBufferString(magic)
+	putUvarint(out, version)
+	putUvarint(out, uint64(len(e.stringList)))
+	for _, s := range e.stringList {
+		putUvarint(out, uint64(len(s)))
+		out.WriteString(s)
+	}
+	putUvarint(out, uint64(len(e.playerList)))
+	for _, p := range e.playerList {
+		putUvarint(out, p.SteamID)
+		putUvarint(out, uint64(e.intern(p.Name)))
+	}
+	putUvarint(out, uint64(len(headerJSON)))
+	out.Write(headerJSON)
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+type encoder struct {
+	strings    map[string]int
+	stringList []string
+	players    map[models.PlayerInfo]int
+	playerList []models.PlayerInfo
+}
+
+func (e *encoder) intern(s string) int {
+	if i, ok := e.strings[s]; ok {
+		return i
+	}
+	i := len(e.stringList)
+	e.strings[s] = i
+	e.stringList = append(e.stringList, s)
+	return i
+}
+
+func (e *encoder) player(ps *models.PlayerState) int {
+	key := models.PlayerInfo{SteamID: ps.SteamID, Name: ps.Name}
+	if i, ok := e.players[key]; ok {
+		return i
+	}
+	e.intern(ps.Name)
+	i := len(e.playerList)
+	e.players[key] = i
+	e.playerList = append(e.playerList, key)
+	return i
+}
+
+func (e *encoder) writeRound(buf *bytes.Buffer, snapshots []models.Snapshot) {
+	putUvarint(buf, uint64(len(snapshots)))
+
+	prev := make(map[int]quantized)
+	var prevBomb quantizedBomb
+	var prevTick, prevTime int64
+
+	for i := range snapshots {
+		snap := &snapshots[i]
+		tick, t := int64(snap.Tick), quantize(snap.TimeInRound, timeScale)
+		putVarint(buf, tick-prevTick)
+		putVarint(buf, t-prevTime)
+		prevTick, prevTime = tick, t
+
+		if snap.Bomb == nil {
+			buf.WriteByte(0)
+		} else {
+			b := quantizedBomb{
+				state:   e.intern(snap.Bomb.State),
+				x:       quantize(snap.Bomb.X, posScale),
+				y:       quantize(snap.Bomb.Y, posScale),
+				carrier: snap.Bomb.Carrier,
+			}
+			mask := byte(bombPresent)
+			if b.state != prevBomb.state {
+				mask |= bombState
+			}
+			if b.x != prevBomb.x {
+				mask |= bombX
+			}
+			if b.y != prevBomb.y {
+				mask |= bombY
+			}
+			if b.carrier != prevBomb.carrier {
+				mask |= bombCarrier
+			}
+			buf.WriteByte(mask)
+			if mask&bombState != 0 {
+				putUvarint(buf, uint64(b.state))
+			}
+			if mask&bombX != 0 {
+				putVarint(buf, b.x-prevBomb.x)
+			}
+			if mask&bombY != 0 {
+				putVarint(buf, b.y-prevBomb.y)
+			}
+			if mask&bombCarrier != 0 {
+				putUvarint(buf, b.carrier)
+			}
+			prevBomb = b
+		}
+
+		putUvarint(buf, uint64(len(snap.Players)))
+		for j := range snap.Players {
+			ps := &snap.Players[j]
+			idx := e.player(ps)
+			q := e.quantizePlayer(ps)
+			p := prev[idx]
+			prev[idx] = q
+
+			putUvarint(buf, uint64(idx))
+			mask := q.diff(&p)
+			putUvarint(buf, uint64(mask))
+			if mask&fieldTeam != 0 {
+				putUvarint(buf, uint64(q.team))
+			}
+			if mask&fieldX != 0 {
+				putVarint(buf, q.x-p.x)
+			}
+			if mask&fieldY != 0 {
+				putVarint(buf, q.y-p.y)
+			}
+			if mask&fieldZ != 0 {
+				putVarint(buf, q.z-p.z)
+			}
+			if mask&fieldYaw != 0 {
+				putVarint(buf, q.yaw-p.yaw)
+			}
+			if mask&fieldHP != 0 {
+				putVarint(buf, q.hp-p.hp)
+			}
+			if mask&fieldArmor != 0 {
+				putVarint(buf, q.armor-p.armor)
+			}
+			if mask&fieldFlags != 0 {
+				buf.WriteByte(q.flags)
+			}
+			if mask&fieldWeapon != 0 {
+				putUvarint(buf, uint64(q.weapon))
+			}
+			if mask&fieldGrenades != 0 {
+				putUvarint(buf, uint64(len(q.grenades)))
+				for _, g := range q.grenades {
+					putUvarint(buf, uint64(g))
+				}
+			}
+			if mask&fieldMoney != 0 {
+				putVarint(buf, q.money-p.money)
+			}
+			if mask&fieldEquipValue != 0 {
+				putVarint(buf, q.equip-p.equip)
+			}
+			if mask&fieldFlash != 0 {
+				putVarint(buf, q.flash-p.flash)
+			}
+			if mask&fieldPlace != 0 {
+				putUvarint(buf, uint64(q.place))
+			}
+		}
+	}
+}
+
+func (e *encoder) quantizePlayer(ps *models.PlayerState) quantized {
+	q := quantized{
+		team:   e.intern(ps.Team),
+		weapon: e.intern(ps.Weapon),
+		place:  e.intern(ps.Place),
+		x:      quantize(ps.X, posScale),
+		y:      quantize(ps.Y, posScale),
+		z:      quantize(ps.Z, posScale),
+		yaw:    quantize(ps.Yaw, posScale),
+		hp:     int64(ps.HP),
+		armor:  int64(ps.Armor),
+		money:  int64(ps.Money),
+		equip:  int64(ps.EquipValue),
+		flash:  quantize(ps.FlashAlpha, 1),
+	}
+	if ps.HasHelmet {
+		q.flags |= flagHelmet
+	}
+	if ps.IsAlive {
+		q.flags |= flagAlive
+	}
+	if ps.HasDefuser {
+		q.flags |= flagDefuser
+	}
+	for _, g := range ps.Grenades {
+		q.grenades = append(q.grenades, e.intern(g))
+	}
+	return q
+}
+
+func (q *quantized) diff(p *quantized) int {
+	mask := 0
+	set := func(bit int, changed bool) {
+		if changed {
+			mask |= bit
+		}
+	}
+	set(fieldTeam, q.team != p.team)
+	set(fieldX, q.x != p.x)
+	set(fieldY, q.y != p.y)
+	set(fieldZ, q.z != p.z)
+	set(fieldYaw, q.yaw != p.yaw)
+	set(fieldHP, q.hp != p.hp)
+	set(fieldArmor, q.armor != p.armor)
+	set(fieldFlags, q.flags != p.flags)
+	set(fieldWeapon, q.weapon != p.weapon)
+	set(fieldGrenades, !equalInts(q.grenades, p.grenades))
+	set(fieldMoney, q.money != p.money)
+	set(fieldEquipValue, q.equip != p.equip)
+	set(fieldFlash, q.flash != p.flash)
+	set(fieldPlace, q.place != p.place)
+	return mask
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func putUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func putVarint(buf *bytes.Buffer, v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutVarint(tmp[:], v)])
+}
+
+// IsBinary reports whether data starts like an encoded match.
+func IsBinary(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Unmarshal decodes a match written by Marshal.
+func Unmarshal(data []byte) (*models.Match, error) {
+	if !IsBinary(data) {
+		return nil, errCorrupt
+	}
+	d := &decoder{data: data, pos: len(magic)}
+	if v := d.uvarint(); v != version {
+		return nil, fmt.Errorf("unsupported match format version %d", v)
+	}
+
+	strings := make([]string, d.count())
+	for i := range strings {
+		strings[i] = string(d.bytes(d.count()))
+	}
+	d.strings = strings
+
+	players := make([]models.PlayerInfo, d.count())
+	for i := range players {
+		players[i].SteamID = d.uvarint()
+		players[i].Name = d.str()
+	}
+
+	var match models.Match
+	header := d.bytes(d.count())
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := json.Unmarshal(header, &match); err != nil {
+		return nil, fmt.Errorf("decoding match header: %w", err)
+	}
+
+	for i := range match.Rounds {
+		match.Rounds[i].Snapshots = d.round(players)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &match, nil
+}
+
+// decoder reads varints from data, recording the first error and returning
+// zero values after it so callers can check once at the end.
+type decoder struct {
+	data    []byte
+	pos     int
+	err     error
+	strings []string
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errCorrupt
+	}
+	d.pos = len(d.data)
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+// count reads a length, rejecting values that couldn't fit in the
+// remaining data.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)-d.pos) {
+		d.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) byte() byte {
+	if d.pos >= len(d.data) {
+		d.fail()
+		return 0
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *decoder) bytes(n int) []byte {
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) str() string {
+	i := d.uvarint()
+	if i >= uint64(len(d.strings)) {
+		d.fail()
+		return ""
+	}
+	return d.strings[i]
+}
+
+func (d *decoder) round(players []models.PlayerInfo) []models.Snapshot {
+	snapshots := make([]models.Snapshot, d.count())
+
+	prev := make(map[uint64]quantizedState)
+	var bomb models.BombState
+	var bombQX, bombQY int64
+	var tick, t int64
+
+	for i := range snapshots {
+		snap := &snapshots[i]
+		tick += d.varint()
+		t += d.varint()
+		snap.Tick = int(tick)
+		snap.TimeInRound = float64(t) / timeScale
+
+		mask := d.byte()
+		if mask&bombPresent != 0 {
+			if mask&bombState != 0 {
+				bomb.State = d.str()
+			}
+			if mask&bombX != 0 {
+				bombQX += d.varint()
+			}
+			if mask&bombY != 0 {
+				bombQY += d.varint()
+			}
+			if mask&bombCarrier != 0 {
+				bomb.Carrier = d.uvarint()
+			}
+			bomb.X, bomb.Y = float64(bombQX)/posScale, float64(bombQY)/posScale
+			b := bomb
+			snap.Bomb = &b
+		}
+
+		count := d.count()
+		snap.Players = make([]models.PlayerState, count)
+		for j := range snap.Players {
+			idx := d.uvarint()
+			if idx >= uint64(len(players)) {
+				d.fail()
+				return nil
+			}
+			s := prev[idx]
+			s.read(d, int(d.uvarint()))
+			prev[idx] = s
+
+			ps := s.state
+			ps.SteamID, ps.Name = players[idx].SteamID, players[idx].Name
+			if len(s.state.Grenades) > 0 {
+				ps.Grenades = append([]string(nil), s.state.Grenades...)
+			}
+			snap.Players[j] = ps
+		}
+		if d.err != nil {
+			return nil
+		}
+	}
+	return snapshots
+}
+
+// quantizedState is a player's last decoded state along with the
+// fixed-point values the next deltas apply to.
+type quantizedState struct {
+	state            models.PlayerState
+	x, y, z, yaw, fl int64
+}
+
+func (s *quantizedState) read(d *decoder, mask int) {
+	ps := &s.state
+	if mask&fieldTeam != 0 {
+		ps.Team = d.str()
+	}
+	if mask&fieldX != 0 {
+		s.x += d.varint()
+	}
+	if mask&fieldY != 0 {
+		s.y += d.varint()
+	}
+	if mask&fieldZ != 0 {
+		s.z += d.varint()
+	}
+	if mask&fieldYaw != 0 {
+		s.yaw += d.varint()
+	}
+	if mask&fieldHP != 0 {
+		ps.HP += int(d.varint())
+	}
+	if mask&fieldArmor != 0 {
+		ps.Armor += int(d.varint())
+	}
+	if mask&fieldFlags != 0 {
+		flags := d.byte()
+		ps.HasHelmet = flags&flagHelmet != 0
+		ps.IsAlive = flags&flagAlive != 0
+		ps.HasDefuser = flags&flagDefuser != 0
+	}
+	if mask&fieldWeapon != 0 {
+		ps.Weapon = d.str()
+	}
+	if mask&fieldGrenades != 0 {
+		ps.Grenades = make([]string, d.count())
+		for i := range ps.Grenades {
+			ps.Grenades[i] = d.str()
+		}
+		if len(ps.Grenades) == 0 {
+			ps.Grenades = nil
+		}
+	}
+	if mask&fieldMoney != 0 {
+		ps.Money += int(d.varint())
+	}
+	if mask&fieldEquipValue != 0 {
+		ps.EquipValue += int(d.varint())
+	}
+	if mask&fieldFlash != 0 {
+		s.fl += d.varint()
+	}
+	if mask&fieldPlace != 0 {
+		ps.Place = d.str()
+	}
+	ps.X, ps.Y, ps.Z = float64(s.x)/posScale, float64(s.y)/posScale, float64(s.z)/posScale
+	ps.Yaw = float64(s.yaw) / posScale
+	ps.FlashAlpha = float64(s.fl)
+}
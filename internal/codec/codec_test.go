@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func testMatch() *models.Match {
+	var snaps []models.Snapshot
+	for i := 0; i < 50; i++ {
+		snap := models.Snapshot{
+			Tick:        1000 + i*13,
+			TimeInRound: float64(i) * 0.203,
+			Players: []models.PlayerState{
+				{SteamID: 76561198000000001, Name: "alpha", Team: "ct", X: -1234.56 + float64(i), Y: 987.65, Z: 12.3,
+					Yaw: 179.94, HP: 100 - i, Armor: 100, HasHelmet: true, IsAlive: i < 40, Weapon: "AK-47",
+					Grenades: []string{"Smoke Grenade", "Flashbang"}, Money: 4500, EquipValue: 5200, Place: "BombsiteA"},
+				{SteamID: 76561198000000002, Name: "bravo", Team: "t", X: 500, Y: -500, HP: 100,
+					IsAlive: true, HasDefuser: i%2 == 0, FlashAlpha: float64(i), Weapon: "Glock-18"},
+			},
+		}
+		if i >= 10 {
+			snap.Bomb = &models.BombState{X: 10.5, Y: -20.25, State: "carried", Carrier: 76561198000000002}
+		}
+		if i >= 30 {
+			snap.Players = snap.Players[:1]
+		}
+		snaps = append(snaps, snap)
+	}
+
+	return &models.Match{
+		ID:       "abc",
+		Map:      "de_mirage",
+		TickRate: 64,
+		Rounds: []models.Round{
+			{Number: 1, Winner: "ct", Snapshots: snaps, Kills: []models.KillEvent{{Tick: 1200, Attacker: 1, Victim: 2}}},
+			{Number: 2, Winner: "t", Snapshots: []models.Snapshot{}},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	match := testMatch()
+	data, err := Marshal(match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.ID != match.ID || got.Map != match.Map || len(got.Rounds) != 2 || len(got.Rounds[0].Kills) != 1 {
+		t.Fatalf("header mismatch: %+v", got)
+	}
+	want, have := match.Rounds[0].Snapshots, got.Rounds[0].Snapshots
+	if len(have) != len(want) {
+		t.Fatalf("got %d snapshots, want %d", len(have), len(want))
+	}
+	for i := range want {
+		w, h := want[i], have[i]
+		if h.Tick != w.Tick || math.Abs(h.TimeInRound-w.TimeInRound) > 0.001 {
+			t.Errorf("snapshot %d: tick/time %d %v, want %d %v", i, h.Tick, h.TimeInRound, w.Tick, w.TimeInRound)
+		}
+		if (w.Bomb == nil) != (h.Bomb == nil) {
+			t.Fatalf("snapshot %d: bomb %+v, want %+v", i, h.Bomb, w.Bomb)
+		}
+		if w.Bomb != nil && (h.Bomb.State != w.Bomb.State || h.Bomb.Carrier != w.Bomb.Carrier || !near(h.Bomb.X, w.Bomb.X) || !near(h.Bomb.Y, w.Bomb.Y)) {
+			t.Errorf("snapshot %d: bomb %+v, want %+v", i, h.Bomb, w.Bomb)
+		}
+		if len(h.Players) != len(w.Players) {
+			t.Fatalf("snapshot %d: %d players, want %d", i, len(h.Players), len(w.Players))
+		}
+		for j := range w.Players {
+			wp, hp := w.Players[j], h.Players[j]
+			if !near(hp.X, wp.X) || !near(hp.Y, wp.Y) || !near(hp.Z, wp.Z) || !near(hp.Yaw, wp.Yaw) {
+				t.Errorf("snapshot %d player %d: position %v,%v,%v want %v,%v,%v", i, j, hp.X, hp.Y, hp.Yaw, wp.X, wp.Y, wp.Yaw)
+			}
+			hp.X, hp.Y, hp.Z, hp.Yaw = wp.X, wp.Y, wp.Z, wp.Yaw
+			a, _ := json.Marshal(hp)
+			b, _ := json.Marshal(wp)
+			if string(a) != string(b) {
+				t.Errorf("snapshot %d player %d:\n got %s\nwant %s", i, j, a, b)
+			}
+		}
+	}
+
+	full, _ := json.Marshal(match)
+	if len(data)*4 > len(full) {
+		t.Errorf("binary is %d bytes, JSON %d; expected at least 4x smaller", len(data), len(full))
+	}
+}
+
+func TestUnmarshalCorrupt(t *testing.T) {
+	data, err := Marshal(testMatch())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 4, 10, len(data) / 2, len(data) - 1} {
+		if _, err := Unmarshal(data[:n]); err == nil {
+			t.Errorf("truncated to %d bytes: expected error", n)
+		}
+	}
+}
+
+// near reports whether a decoded value is within quantization error.
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 0.5/posScale+1e-9
+}
@@ -0,0 +1,330 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+const (
+	parquetMagic = "PAR1"
+
+	// Rows buffered before a row group is written out.
+	parquetRowGroupSize = 1 << 16
+)
+
+// Parquet enum values used below, from parquet.thrift.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired    = 0
+	parquetUTF8        = 0
+	parquetPlain       = 0
+	parquetRLE         = 3
+	parquetGzip        = 2
+	parquetDataPage    = 0
+	parquetLogicalUTF8 = 1
+)
+
+// ParquetWriter writes rows as a Parquet file with one required column per
+// table column. Values are PLAIN encoded and gzip compressed, which every
+// reader supports without needing dictionary or RLE encoders here.
+//
+// It satisfies RowWriter, where Flush ends the current row group; Close
+// must be called to write the footer.
+type ParquetWriter struct {
+	w       *countingWriter
+	columns []Column
+	values  []*bytes.Buffer
+	bools   [][]bool
+	rows    int
+	total   int64
+	groups  []parquetRowGroup
+}
+
+type parquetRowGroup struct {
+	rows   int
+	size   int64
+	chunks []parquetChunk
+}
+
+type parquetChunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	return &ParquetWriter{w: &countingWriter{w: w}}
+}
+
+func (p *ParquetWriter) WriteHeader(columns []Column) error {
+	p.columns = columns
+	p.values = make([]*bytes.Buffer, len(columns))
+	p.bools = make([][]bool, len(columns))
+	for i := range columns {
+		p.values[i] = new(bytes.Buffer)
+	}
+	_, err := io.WriteString(p.w, parquetMagic)
+	return err
+}
+
+func (p *ParquetWriter) WriteRow(row []any) error {
+	if len(row) != len(p.columns) {
+		return fmt.Errorf("row has %d values, want %d", len(row), len(p.columns))
+	}
+	for i, v := range row {
+		buf := p.values[i]
+		switch p.columns[i].Kind {
+		case KindInt:
+			binary.Write(buf, binary.LittleEndian, v.(int64))
+		case KindFloat:
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(v.(float64)))
+		case KindBool:
+			p.bools[i] = append(p.bools[i], v.(bool))
+		case KindString:
+			s := v.(string)
+			binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+			buf.WriteString(s)
+		}
+	}
+	p.rows++
+	if p.rows >= parquetRowGroupSize {
+		return p.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered rows as a row group.
+func (p *ParquetWriter) Flush() error {
+	if p.rows == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{rows: p.rows}
+	for i, col := range p.columns {
+		raw := p.values[i].Bytes()
+		if col.Kind == KindBool {
+			raw = packBools(p.bools[i])
+			p.bools[i] = p.bools[i][:0]
+		}
+
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write(raw)
+		if err := gz.Close(); err != nil {
+			return err
+		}
+
+		header := newThriftWriter()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(raw)))
+		header.i32(3, int32(compressed.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(p.rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		headerBytes := header.bytes()
+
+		chunk := parquetChunk{
+			offset:           p.w.n,
+			uncompressedSize: int64(len(headerBytes) + len(raw)),
+			compressedSize:   int64(len(headerBytes) + compressed.Len()),
+		}
+		if _, err := p.w.Write(headerBytes); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(compressed.Bytes()); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.uncompressedSize
+		p.values[i].Reset()
+	}
+
+	p.groups = append(p.groups, group)
+	p.total += int64(p.rows)
+	p.rows = 0
+	return nil
+}
+
+// Close flushes any buffered rows and writes the file footer. It does not
+// close the underlying writer.
+func (p *ParquetWriter) Close() error {
+	if err := p.Flush(); err != nil {
+		return err
+	}
+
+	meta := newThriftWriter()
+	meta.i32(1, 1)
+
+	meta.list(2, thriftStruct, len(p.columns)+1)
+	meta.beginListStruct()
+	meta.string(4, "schema")
+	meta.i32(5, int32(len(p.columns)))
+	meta.endStruct()
+	for _, col := range p.columns {
+		meta.beginListStruct()
+		meta.i32(1, parquetType(col.Kind))
+		meta.i32(3, parquetRequired)
+		meta.string(4, col.Name)
+		if col.Kind == KindString {
+			meta.i32(6, parquetUTF8)
+			meta.beginStruct(10)
+			meta.beginStruct(parquetLogicalUTF8)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.endStruct()
+	}
+
+	meta.i64(3, p.total)
+
+	meta.list(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		meta.beginListStruct()
+		meta.list(1, thriftStruct, len(g.chunks))
+		for i, c := range g.chunks {
+			col := p.columns[i]
+			meta.beginListStruct()
+			meta.i64(2, c.offset)
+			meta.beginStruct(3)
+			meta.i32(1, parquetType(col.Kind))
+			meta.listI32(2, parquetPlain, parquetRLE)
+			meta.listString(3, col.Name)
+			meta.i32(4, parquetGzip)
+			meta.i64(5, int64(g.rows))
+			meta.i64(6, c.uncompressedSize)
+			meta.i64(7, c.compressedSize)
+			meta.i64(9, c.offset)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64(2, g.size)
+		meta.i64(3, int64(g.rows))
+		meta.endStruct()
+	}
+
+	meta.string(6, "cs2-demo-parser")
+	footer := meta.bytes()
+
+	if _, err := p.w.Write(footer); err != nil {
+		return err
+	}
+	if err := binary.Write(p.w, binary.LittleEndian, uint32(len(footer))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(p.w, parquetMagic)
+	return err
+}
+
+func parquetType(k ColumnKind) int32 {
+	switch k {
+	case KindInt:
+		return parquetInt64
+	case KindFloat:
+		return parquetDouble
+	case KindBool:
+		return parquetBoolean
+	default:
+		return parquetByteArray
+	}
+}
+
+// packBools bit-packs values least significant bit first, as PLAIN encoding
+// requires for booleans.
+func packBools(values []bool) []byte {
+	out := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteParquetPartitions writes the table's rows for the match as one file
+// per round, laid out as Hive partitions:
+//
+//	<dir>/<table>/match_id=<id>/round=<n>/data.parquet
+//
+// The match_id and round columns are carried by the path rather than the
+// files. Rounds without rows get no file. It returns the number of files
+// written.
+func WriteParquetPartitions(dir string, t *Table, m *models.Match) (int, error) {
+	matchDir := filepath.Join(dir, t.Name, "match_id="+m.ID)
+	if err := os.RemoveAll(matchDir); err != nil {
+		return 0, err
+	}
+
+	ctx := newMatchContext(m)
+	files := 0
+	for i := range m.Rounds {
+		r := &m.Rounds[i]
+		path := filepath.Join(matchDir, "round="+strconv.Itoa(r.Number), "data.parquet")
+
+		var f *os.File
+		var pw *ParquetWriter
+		err := t.rows(ctx, r, func(row []any) error {
+			if pw == nil {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					return err
+				}
+				var err error
+				if f, err = os.Create(path + ".tmp"); err != nil {
+					return err
+				}
+				pw = NewParquetWriter(f)
+				if err := pw.WriteHeader(t.Columns[2:]); err != nil {
+					return err
+				}
+			}
+			return pw.WriteRow(row[2:])
+		})
+		if pw == nil {
+			if err != nil {
+				return files, err
+			}
+			continue
+		}
+		if err == nil {
+			err = pw.Close()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+		if err != nil {
+			os.Remove(path + ".tmp")
+			return files, fmt.Errorf("writing %s: %w", path, err)
+		}
+		files++
+	}
+	return files, nil
+}
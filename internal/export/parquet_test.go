@@ -0,0 +1,245 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParquetWriterLayout(t *testing.T) {
+	// Three rows in two row groups.
+	var buf bytes.Buffer
+	pw := NewParquetWriter(&buf)
+	if err := pw.WriteHeader(killsTable.Columns); err != nil {
+		t.Fatal(err)
+	}
+	match := testMatch()
+	for i := range 3 {
+		if err := killsTable.EachRow(match, pw.WriteRow); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			if err := pw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLen
+	meta, n := decodeThrift(t, data[footerStart:len(data)-8])
+	if n != footerLen {
+		t.Errorf("footer struct is %d bytes, footer length says %d", n, footerLen)
+	}
+
+	// FileMetaData: 1 version, 2 schema, 3 num_rows, 4 row_groups.
+	if meta[1] != int64(1) {
+		t.Errorf("version = %v, want 1", meta[1])
+	}
+	if meta[3] != int64(3) {
+		t.Errorf("num_rows = %v, want 3", meta[3])
+	}
+
+	// SchemaElement: 1 type, 3 repetition_type, 4 name, 5 num_children,
+	// 6 converted_type, 10 logicalType.
+	schema := meta[2].([]any)
+	columns := killsTable.Columns
+	if len(schema) != len(columns)+1 {
+		t.Fatalf("schema has %d elements, want %d", len(schema), len(columns)+1)
+	}
+	if root := schema[0].(map[int16]any); root[4] != "schema" || root[5] != int64(len(columns)) {
+		t.Errorf("unexpected schema root %v", root)
+	}
+	for i, col := range columns {
+		el := schema[i+1].(map[int16]any)
+		if el[4] != col.Name || el[1] != int64(parquetType(col.Kind)) || el[3] != int64(parquetRequired) {
+			t.Errorf("schema element for %s = %v", col.Name, el)
+		}
+		_, isUTF8 := el[10]
+		if isUTF8 != (col.Kind == KindString) {
+			t.Errorf("schema element for %s: logical type %v", col.Name, el[10])
+		}
+	}
+
+	// RowGroup: 1 columns, 3 num_rows. ColumnChunk: 2 file_offset,
+	// 3 meta_data. ColumnMetaData: 1 type, 3 path_in_schema,
+	// 5 num_values, 7 total_compressed_size, 9 data_page_offset.
+	groups := meta[4].([]any)
+	if len(groups) != 2 {
+		t.Fatalf("got %d row groups, want 2", len(groups))
+	}
+	offset := int64(len("PAR1"))
+	for g, wantRows := range []int64{2, 1} {
+		group := groups[g].(map[int16]any)
+		if group[3] != wantRows {
+			t.Errorf("row group %d has %v rows, want %d", g, group[3], wantRows)
+		}
+		chunks := group[1].([]any)
+		if len(chunks) != len(columns) {
+			t.Fatalf("row group %d has %d column chunks, want %d", g, len(chunks), len(columns))
+		}
+		for i, col := range columns {
+			chunk := chunks[i].(map[int16]any)
+			cm := chunk[3].(map[int16]any)
+			if chunk[2] != offset || cm[9] != offset {
+				t.Errorf("row group %d, %s: offsets %v/%v, want %d", g, col.Name, chunk[2], cm[9], offset)
+			}
+			if cm[1] != int64(parquetType(col.Kind)) || cm[5] != wantRows {
+				t.Errorf("row group %d, %s: type %v with %v values", g, col.Name, cm[1], cm[5])
+			}
+			if path := cm[3].([]any); len(path) != 1 || path[0] != col.Name {
+				t.Errorf("row group %d, %s: path %v", g, col.Name, path)
+			}
+
+			// PageHeader: 1 type, 2 uncompressed_page_size,
+			// 3 compressed_page_size, 5 data_page_header (1 num_values).
+			page, headerLen := decodeThrift(t, data[offset:])
+			if page[1] != int64(parquetDataPage) || page[5].(map[int16]any)[1] != wantRows {
+				t.Errorf("row group %d, %s: page header %v", g, col.Name, page)
+			}
+			if size := int64(headerLen) + page[3].(int64); size != cm[7] {
+				t.Errorf("row group %d, %s: page is %d bytes, chunk says %v", g, col.Name, size, cm[7])
+			}
+			offset += cm[7].(int64)
+		}
+	}
+	if offset != int64(footerStart) {
+		t.Errorf("column chunks end at %d, footer starts at %d", offset, footerStart)
+	}
+}
+
+// decodeThrift decodes a struct in the Thrift compact protocol, written
+// independently of thriftWriter to check its output. Fields are keyed by
+// ID; integers decode as int64, binary as string, lists as []any and
+// structs as map[int16]any. It returns the struct and its encoded length.
+func decodeThrift(t *testing.T, data []byte) (map[int16]any, int) {
+	t.Helper()
+	d := &thriftDecoder{data: data}
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("decoding thrift at byte %d: %v", d.pos, r)
+		}
+	}()
+	return d.readStruct(), d.pos
+}
+
+type thriftDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *thriftDecoder) byte() byte {
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *thriftDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		panic("bad varint")
+	}
+	d.pos += n
+	return v
+}
+
+func (d *thriftDecoder) zigzag() int64 {
+	v := d.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (d *thriftDecoder) readStruct() map[int16]any {
+	fields := make(map[int16]any)
+	var id int16
+	for {
+		b := d.byte()
+		if b == 0 {
+			return fields
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(d.zigzag())
+		}
+		switch typ := b & 0x0f; typ {
+		case 1, 2: // booleans carry their value in the type
+			fields[id] = typ == 1
+		default:
+			fields[id] = d.readValue(typ)
+		}
+	}
+}
+
+func (d *thriftDecoder) readValue(typ byte) any {
+	switch typ {
+	case 1, 2: // list elements
+		return d.byte() == 1
+	case 3:
+		return int64(int8(d.byte()))
+	case 4, 5, 6:
+		return d.zigzag()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.data[d.pos:]))
+		d.pos += 8
+		return v
+	case 8:
+		n := int(d.uvarint())
+		s := string(d.data[d.pos : d.pos+n])
+		d.pos += n
+		return s
+	case 9:
+		h := d.byte()
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(d.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = d.readValue(elem)
+		}
+		return list
+	case 12:
+		return d.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported type %d", typ))
+}
+
+func TestWriteParquetPartitions(t *testing.T) {
+	dir := t.TempDir()
+	match := testMatch()
+	match.Rounds = append(match.Rounds, match.Rounds[0])
+	match.Rounds[1].Number = 2
+	match.Rounds[1].Kills = nil
+
+	n, err := WriteParquetPartitions(dir, killsTable, match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("wrote %d files, want 1", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kills", "match_id=m1", "round=1", "data.parquet")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kills", "match_id=m1", "round=2")); !os.IsNotExist(err) {
+		t.Error("round without kills should have no partition")
+	}
+}
+
+func TestPackBools(t *testing.T) {
+	got := packBools([]bool{true, false, true, false, false, false, false, false, true})
+	if !bytes.Equal(got, []byte{0x05, 0x01}) {
+		t.Errorf("got %x", got)
+	}
+}
@@ -0,0 +1,387 @@
+package export
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// ColumnKind is the type of every value in a column. Values are int64,
+// float64, bool or string respectively.
+type ColumnKind int
+
+const (
+	KindInt ColumnKind = iota
+	KindFloat
+	KindBool
+	KindString
+)
+
+func (k ColumnKind) String() string {
+	switch k {
+	case KindInt:
+		return "int"
+	case KindFloat:
+		return "float"
+	case KindBool:
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+func (k ColumnKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+type Column struct {
+	Name string     `json:"name"`
+	Kind ColumnKind `json:"kind"`
+}
+
+// Table flattens one kind of match data into rows. Every table starts with
+// match_id and round so rows from several matches can be concatenated.
+type Table struct {
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+
+	rows func(m *matchContext, r *models.Round, emit func(row []any) error) error
+}
+
+// Tables lists every exportable table.
+var Tables = []*Table{killsTable, grenadesTable, roundsTable, playerRoundsTable, playerStatesTable}
+
+// LookupTable finds a table by name.
+func LookupTable(name string) (*Table, bool) {
+	for _, t := range Tables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Write writes the header and then the rows of every given match, in order.
+func (t *Table) Write(rw RowWriter, matches ...*models.Match) error {
+	if err := rw.WriteHeader(t.Columns); err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := t.EachRow(m, rw.WriteRow); err != nil {
+			return err
+		}
+	}
+	return rw.Flush()
+}
+
+// EachRow calls emit with every row of the match, stopping at the first
+// error.
+func (t *Table) EachRow(m *models.Match, emit func(row []any) error) error {
+	ctx := newMatchContext(m)
+	for i := range m.Rounds {
+		if err := t.rows(ctx, &m.Rounds[i], emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func columns(spec ...any) []Column {
+	cols := make([]Column, 0, len(spec)/2)
+	for i := 0; i < len(spec); i += 2 {
+		cols = append(cols, Column{Name: spec[i].(string), Kind: spec[i+1].(ColumnKind)})
+	}
+	return cols
+}
+
+// matchContext holds per-match lookups shared by the row builders.
+type matchContext struct {
+	*models.Match
+	names map[uint64]string
+}
+
+func newMatchContext(m *models.Match) *matchContext {
+	names := make(map[uint64]string)
+	for _, team := range []models.TeamInfo{m.Teams.CT, m.Teams.T} {
+		for _, p := range team.Players {
+			names[p.SteamID] = p.Name
+		}
+	}
+	return &matchContext{Match: m, names: names}
+}
+
+var killsTable = &Table{
+	Name: "kills",
+	Columns: columns(
+		"match_id", KindString,
+		"round", KindInt,
+		"tick", KindInt,
+		"time_in_round", KindFloat,
+		"attacker", KindInt,
+		"attacker_name", KindString,
+		"attacker_side", KindString,
+		"victim", KindInt,
+		"victim_name", KindString,
+		"victim_side", KindString,
+		"weapon", KindString,
+		"headshot", KindBool,
+		"wallbang", KindBool,
+		"no_scope", KindBool,
+		"through_smoke", KindBool,
+		"attacker_blind", KindBool,
+		"attacker_x", KindFloat,
+		"attacker_y", KindFloat,
+		"attacker_place", KindString,
+		"victim_x", KindFloat,
+		"victim_y", KindFloat,
+		"victim_place", KindString,
+		"traded", KindBool,
+		"trade_time", KindFloat,
+		"refrag", KindBool,
+	),
+	rows: func(m *matchContext, r *models.Round, emit func([]any) error) error {
+		sides := analysis.RoundSides(r)
+		for _, k := range r.Kills {
+			err := emit([]any{
+				m.ID, int64(r.Number), int64(k.Tick), k.TimeInRound,
+				int64(k.Attacker), m.names[k.Attacker], sides[k.Attacker],
+				int64(k.Victim), m.names[k.Victim], sides[k.Victim],
+				k.Weapon, k.Headshot, k.Wallbang, k.NoScope, k.ThroughSmoke, k.AttackerBlind,
+				k.AttackerX, k.AttackerY, k.AttackerPlace,
+				k.VictimX, k.VictimY, k.VictimPlace,
+				k.Traded, k.TradeTime, k.Refrag,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var grenadesTable = &Table{
+	Name: "grenades",
+	Columns: columns(
+		"match_id", KindString,
+		"round", KindInt,
+		"type", KindString,
+		"thrower", KindInt,
+		"thrower_name", KindString,
+		"thrower_side", KindString,
+		"throw_tick", KindInt,
+		"throw_time", KindFloat,
+		"throw_x", KindFloat,
+		"throw_y", KindFloat,
+		"detonate_tick", KindInt,
+		"detonate_time", KindFloat,
+		"detonate_x", KindFloat,
+		"detonate_y", KindFloat,
+		"effect_duration", KindFloat,
+	),
+	rows: func(m *matchContext, r *models.Round, emit func([]any) error) error {
+		sides := analysis.RoundSides(r)
+		for _, g := range r.Grenades {
+			err := emit([]any{
+				m.ID, int64(r.Number), g.Type,
+				int64(g.Thrower), m.names[g.Thrower], sides[g.Thrower],
+				int64(g.ThrowTick), g.ThrowTime, g.ThrowX, g.ThrowY,
+				int64(g.DetonateTick), g.DetonateTime, g.DetonateX, g.DetonateY,
+				g.EffectDuration,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var roundsTable = &Table{
+	Name: "rounds",
+	Columns: columns(
+		"match_id", KindString,
+		"round", KindInt,
+		"map", KindString,
+		"winner", KindString,
+		"win_reason", KindString,
+		"ct_score", KindInt,
+		"t_score", KindInt,
+		"start_tick", KindInt,
+		"end_tick", KindInt,
+		"end_time", KindFloat,
+		"kills", KindInt,
+		"grenades", KindInt,
+		"plant_site", KindString,
+		"plant_time", KindFloat,
+		"planter", KindInt,
+		"defused", KindBool,
+		"defuser", KindInt,
+		"clutch_player", KindInt,
+		"clutch_opponents", KindInt,
+		"clutch_won", KindBool,
+	),
+	rows: func(m *matchContext, r *models.Round, emit func([]any) error) error {
+		var startTick int64
+		if len(r.Snapshots) > 0 {
+			startTick = int64(r.Snapshots[0].Tick)
+		}
+		var plantSite string
+		var plantTime float64
+		var planter, defuser int64
+		if r.Plant != nil {
+			plantSite, plantTime, planter = r.Plant.Site, r.Plant.TimeInRound, int64(r.Plant.Player)
+		}
+		if r.Defuse != nil {
+			defuser = int64(r.Defuse.Player)
+		}
+		var clutchPlayer, clutchOpponents int64
+		var clutchWon bool
+		if r.Clutch != nil {
+			clutchPlayer, clutchOpponents, clutchWon = int64(r.Clutch.Player), int64(r.Clutch.Opponents), r.Clutch.Won
+		}
+
+		return emit([]any{
+			m.ID, int64(r.Number), m.Map, r.Winner, r.WinReason,
+			int64(r.EndCTScore), int64(r.EndTScore),
+			startTick, int64(r.EndTick), r.EndTime,
+			int64(len(r.Kills)), int64(len(r.Grenades)),
+			plantSite, plantTime, planter, r.Defuse != nil, defuser,
+			clutchPlayer, clutchOpponents, clutchWon,
+		})
+	},
+}
+
+var playerRoundsTable = &Table{
+	Name: "player_rounds",
+	Columns: columns(
+		"match_id", KindString,
+		"round", KindInt,
+		"steam_id", KindInt,
+		"name", KindString,
+		"side", KindString,
+		"won", KindBool,
+		"kills", KindInt,
+		"headshots", KindInt,
+		"deaths", KindInt,
+		"survived", KindBool,
+		"traded_death", KindBool,
+		"opening_kill", KindBool,
+		"opening_death", KindBool,
+		"start_money", KindInt,
+		"equip_value", KindInt,
+		"grenades_thrown", KindInt,
+	),
+	rows: func(m *matchContext, r *models.Round, emit func([]any) error) error {
+		sides := analysis.RoundSides(r)
+
+		type line struct {
+			kills, headshots, deaths, thrown       int64
+			money, equip                           int64
+			tradedDeath, openingKill, openingDeath bool
+			seen                                   bool
+		}
+		lines := make(map[uint64]*line, len(sides))
+		for id := range sides {
+			lines[id] = &line{}
+		}
+
+		for _, snap := range r.Snapshots {
+			for _, ps := range snap.Players {
+				if l, ok := lines[ps.SteamID]; ok && !l.seen {
+					l.seen = true
+					l.money, l.equip = int64(ps.Money), int64(ps.EquipValue)
+				}
+			}
+		}
+		for i, k := range r.Kills {
+			if l, ok := lines[k.Attacker]; ok && k.Attacker != k.Victim && sides[k.Attacker] != sides[k.Victim] {
+				l.kills++
+				if k.Headshot {
+					l.headshots++
+				}
+				l.openingKill = l.openingKill || i == 0
+			}
+			if l, ok := lines[k.Victim]; ok {
+				l.deaths++
+				l.tradedDeath = l.tradedDeath || k.Traded
+				l.openingDeath = l.openingDeath || i == 0
+			}
+		}
+		for _, g := range r.Grenades {
+			if l, ok := lines[g.Thrower]; ok {
+				l.thrown++
+			}
+		}
+
+		for _, id := range sortedIDs(sides) {
+			l := lines[id]
+			err := emit([]any{
+				m.ID, int64(r.Number), int64(id), m.names[id], sides[id], r.Winner == sides[id],
+				l.kills, l.headshots, l.deaths, l.deaths == 0, l.tradedDeath,
+				l.openingKill, l.openingDeath,
+				l.money, l.equip, l.thrown,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var playerStatesTable = &Table{
+	Name: "player_states",
+	Columns: columns(
+		"match_id", KindString,
+		"round", KindInt,
+		"tick", KindInt,
+		"time_in_round", KindFloat,
+		"steam_id", KindInt,
+		"name", KindString,
+		"side", KindString,
+		"x", KindFloat,
+		"y", KindFloat,
+		"z", KindFloat,
+		"yaw", KindFloat,
+		"hp", KindInt,
+		"armor", KindInt,
+		"has_helmet", KindBool,
+		"is_alive", KindBool,
+		"weapon", KindString,
+		"grenades", KindString,
+		"has_defuser", KindBool,
+		"money", KindInt,
+		"equip_value", KindInt,
+		"flash_alpha", KindFloat,
+		"place", KindString,
+	),
+	rows: func(m *matchContext, r *models.Round, emit func([]any) error) error {
+		for _, snap := range r.Snapshots {
+			for _, ps := range snap.Players {
+				err := emit([]any{
+					m.ID, int64(r.Number), int64(snap.Tick), snap.TimeInRound,
+					int64(ps.SteamID), ps.Name, ps.Team,
+					ps.X, ps.Y, ps.Z, ps.Yaw,
+					int64(ps.HP), int64(ps.Armor), ps.HasHelmet, ps.IsAlive,
+					ps.Weapon, strings.Join(ps.Grenades, "|"), ps.HasDefuser,
+					int64(ps.Money), int64(ps.EquipValue), ps.FlashAlpha, ps.Place,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func sortedIDs(m map[uint64]string) []uint64 {
+	ids := make([]uint64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func testMatch() *models.Match {
+	snap := models.Snapshot{Tick: 100, Players: []models.PlayerState{
+		{SteamID: 1, Name: "ct player", Team: "ct", IsAlive: true, Grenades: []string{"Smoke", "Flash"}},
+		{SteamID: 2, Name: `t "player"`, Team: "t", IsAlive: true},
+	}}
+	return &models.Match{
+		ID:  "m1",
+		Map: "de_test",
+		Teams: models.Teams{
+			CT: models.TeamInfo{Players: []models.PlayerInfo{{SteamID: 1, Name: "ct player"}}},
+			T:  models.TeamInfo{Players: []models.PlayerInfo{{SteamID: 2, Name: `t "player"`}}},
+		},
+		Rounds: []models.Round{{
+			Number:    1,
+			Winner:    "ct",
+			Snapshots: []models.Snapshot{snap},
+			Kills:     []models.KillEvent{{Tick: 150, Attacker: 1, Victim: 2, Weapon: "AK-47", Headshot: true}},
+			Grenades:  []models.GrenadeEvent{{Type: "smoke", Thrower: 1}},
+		}},
+	}
+}
+
+func TestTableRowsMatchColumns(t *testing.T) {
+	match := testMatch()
+	for _, table := range Tables {
+		rows := 0
+		err := table.EachRow(match, func(row []any) error {
+			rows++
+			if len(row) != len(table.Columns) {
+				t.Fatalf("%s: row has %d values, want %d", table.Name, len(row), len(table.Columns))
+			}
+			for i, v := range row {
+				var ok bool
+				switch table.Columns[i].Kind {
+				case KindInt:
+					_, ok = v.(int64)
+				case KindFloat:
+					_, ok = v.(float64)
+				case KindBool:
+					_, ok = v.(bool)
+				case KindString:
+					_, ok = v.(string)
+				}
+				if !ok {
+					t.Errorf("%s.%s: value %#v does not match kind %s", table.Name, table.Columns[i].Name, v, table.Columns[i].Kind)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows == 0 {
+			t.Errorf("%s: no rows", table.Name)
+		}
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	var sb strings.Builder
+	rw, _ := NewRowWriter("ndjson", &sb)
+	if err := playerRoundsTable.Write(rw, testMatch()); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(sb.String()))
+	var got []map[string]any
+	for sc.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+			t.Fatalf("invalid line %q: %v", sc.Text(), err)
+		}
+		got = append(got, row)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d rows, want 2", len(got))
+	}
+	if got[0]["kills"] != 1.0 || got[0]["opening_kill"] != true || got[1]["name"] != `t "player"` || got[1]["survived"] != false {
+		t.Errorf("unexpected rows: %v", got)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var sb strings.Builder
+	rw, _ := NewRowWriter("csv", &sb)
+	if err := playerStatesTable.Write(rw, testMatch()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "match_id,round,tick,") {
+		t.Fatalf("unexpected output:\n%s", sb.String())
+	}
+	if !strings.Contains(lines[1], "Smoke|Flash") {
+		t.Errorf("grenades not joined: %s", lines[1])
+	}
+}
@@ -0,0 +1,213 @@
+// Package export writes stored matches in formats consumed by other tools.
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// Segment is a stretch of the demo to play back, optionally spectating one
+// player.
+type Segment struct {
+	StartTick int
+	EndTick   int
+	Player    uint64
+	Label     string
+}
+
+// RoundSegments returns one segment per selected round, from the end of
+// freeze time to the end of the round. If player is non-zero the camera is
+// locked to them.
+func RoundSegments(match *models.Match, rounds []int, player uint64) []Segment {
+	want := make(map[int]bool, len(rounds))
+	for _, n := range rounds {
+		want[n] = true
+	}
+
+	var segments []Segment
+	for i := range match.Rounds {
+		r := &match.Rounds[i]
+		if !want[r.Number] || len(r.Snapshots) == 0 {
+			continue
+		}
+		end := r.EndTick
+		if end == 0 {
+			end = r.Snapshots[len(r.Snapshots)-1].Tick
+		}
+		segments = append(segments, Segment{
+			StartTick: r.Snapshots[0].Tick,
+			EndTick:   end,
+			Player:    player,
+			Label:     fmt.Sprintf("Round %d", r.Number),
+		})
+	}
+	return segments
+}
+
+// HighlightSegments returns a segment per highlight, spectating its first
+// player.
+func HighlightSegments(highlights []analysis.Highlight) []Segment {
+	segments := make([]Segment, 0, len(highlights))
+	for _, h := range highlights {
+		seg := Segment{
+			StartTick: h.StartTick,
+			EndTick:   h.EndTick,
+			Label:     fmt.Sprintf("Round %d: %s", h.Round, h.Description),
+		}
+		if len(h.Players) > 0 {
+			seg.Player = h.Players[0]
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+// SelectRanks picks highlights by their 1-based position in the list.
+func SelectRanks(highlights []analysis.Highlight, ranks []int) ([]analysis.Highlight, error) {
+	out := make([]analysis.Highlight, 0, len(ranks))
+	for _, rank := range ranks {
+		if rank < 1 || rank > len(highlights) {
+			return nil, fmt.Errorf("highlight %d out of range (1-%d)", rank, len(highlights))
+		}
+		out = append(out, highlights[rank-1])
+	}
+	return out, nil
+}
+
+// ParseRanges parses a comma-separated list of numbers and inclusive
+// ranges, such as "1-3,7", each between 1 and max. Numbers listed more
+// than once are kept once, in the order first listed.
+func ParseRanges(s string, max int) ([]int, error) {
+	var out []int
+	seen := make(map[int]bool)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(field, "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", field)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(hi); err != nil || to < from {
+				return nil, fmt.Errorf("invalid range %q", field)
+			}
+		}
+		// Checked before expanding, so a huge range can't exhaust memory.
+		if from < 1 || to > max {
+			return nil, fmt.Errorf("%q out of range (1-%d)", field, max)
+		}
+		for n := from; n <= to; n++ {
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty list")
+	}
+	return out, nil
+}
+
+// LastRound returns the number of the match's last round, the upper bound
+// for round lists passed to ParseRanges.
+func LastRound(match *models.Match) int {
+	last := 0
+	for _, r := range match.Rounds {
+		last = max(last, r.Number)
+	}
+	return last
+}
+
+type vdmAction struct {
+	factory   string
+	name      string
+	startTick int
+	key       string
+	value     string
+}
+
+// WriteVDM writes a demo playback script that plays the segments in tick
+// order. Gaps between segments are skipped, overlapping segments are played
+// once, and playback disconnects after the last one. The file must sit next
+// to the demo with the same base name for CS2 to pick it up.
+func WriteVDM(w io.Writer, match *models.Match, segments []Segment) error {
+	slots := make(map[uint64]int)
+	for _, team := range []models.TeamInfo{match.Teams.CT, match.Teams.T} {
+		for _, p := range team.Players {
+			slots[p.SteamID] = p.Slot
+		}
+	}
+
+	sorted := append([]Segment(nil), segments...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartTick < sorted[j].StartTick })
+
+	var actions []vdmAction
+	cursor := 1
+	for _, seg := range sorted {
+		if seg.EndTick <= cursor {
+			continue
+		}
+		start := seg.StartTick
+		if start > cursor {
+			actions = append(actions, vdmAction{
+				factory:   "SkipAhead",
+				name:      seg.Label,
+				startTick: cursor,
+				key:       "skiptotick",
+				value:     strconv.Itoa(start),
+			})
+		} else {
+			start = cursor
+		}
+		if slot := slots[seg.Player]; slot > 0 {
+			actions = append(actions, vdmAction{
+				factory:   "PlayCommands",
+				name:      "spectate " + strconv.FormatUint(seg.Player, 10),
+				startTick: start,
+				key:       "commands",
+				value:     fmt.Sprintf("spec_player %d", slot),
+			})
+		}
+		cursor = seg.EndTick
+	}
+	if len(actions) > 0 {
+		actions = append(actions, vdmAction{
+			factory:   "PlayCommands",
+			name:      "end",
+			startTick: cursor,
+			key:       "commands",
+			value:     "disconnect",
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "demoactions")
+	fmt.Fprintln(bw, "{")
+	for i, a := range actions {
+		fmt.Fprintf(bw, "\t\"%d\"\n\t{\n", i+1)
+		fmt.Fprintf(bw, "\t\tfactory \"%s\"\n", a.factory)
+		fmt.Fprintf(bw, "\t\tname \"%s\"\n", vdmString(a.name))
+		fmt.Fprintf(bw, "\t\tstarttick \"%d\"\n", a.startTick)
+		fmt.Fprintf(bw, "\t\t%s \"%s\"\n", a.key, vdmString(a.value))
+		fmt.Fprintln(bw, "\t}")
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// vdmString strips characters that would end a quoted KeyValues string.
+func vdmString(s string) string {
+	return strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(s)
+}
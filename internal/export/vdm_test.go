@@ -0,0 +1,56 @@
+package export
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestWriteVDM(t *testing.T) {
+	match := &models.Match{Teams: models.Teams{
+		CT: models.TeamInfo{Players: []models.PlayerInfo{{SteamID: 7, Name: "a", Slot: 3}}},
+	}}
+	segments := []Segment{
+		{StartTick: 5000, EndTick: 6000, Player: 7, Label: `second "clip"`},
+		{StartTick: 1000, EndTick: 2000, Label: "first"},
+		{StartTick: 1500, EndTick: 1800, Label: "inside first"},
+	}
+
+	var sb strings.Builder
+	if err := WriteVDM(&sb, match, segments); err != nil {
+		t.Fatal(err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		"skiptotick \"1000\"",
+		"starttick \"2000\"\n\t\tskiptotick \"5000\"",
+		"name \"second 'clip'\"",
+		"starttick \"5000\"\n\t\tcommands \"spec_player 3\"",
+		"starttick \"6000\"\n\t\tcommands \"disconnect\"",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "factory"); n != 4 {
+		t.Errorf("got %d actions, want 4:\n%s", n, out)
+	}
+}
+
+func TestParseRanges(t *testing.T) {
+	got, err := ParseRanges("1-3, 7, 2", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3, 7}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, bad := range []string{"", "x", "5-2", "0", "-1", "0-3", "31", "29-31", "1-2000000000"} {
+		if _, err := ParseRanges(bad, 30); err == nil {
+			t.Errorf("ParseRanges(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RowWriter serializes table rows. Rows must match the columns passed to
+// WriteHeader.
+type RowWriter interface {
+	WriteHeader(columns []Column) error
+	WriteRow(row []any) error
+	Flush() error
+}
+
+// Formats lists the supported row formats by name, with their MIME types.
+var Formats = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// NewRowWriter returns a writer for one of Formats.
+func NewRowWriter(format string, w io.Writer) (RowWriter, error) {
+	switch format {
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case "ndjson":
+		return &ndjsonWriter{w: bufio.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (c *csvWriter) WriteHeader(columns []Column) error {
+	c.record = make([]string, len(columns))
+	for i, col := range columns {
+		c.record[i] = col.Name
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) WriteRow(row []any) error {
+	for i, v := range row {
+		c.record[i] = formatValue(v)
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per row, with keys in column order.
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func (n *ndjsonWriter) WriteHeader(columns []Column) error {
+	n.keys = make([][]byte, len(columns))
+	for i, col := range columns {
+		key, err := json.Marshal(col.Name)
+		if err != nil {
+			return err
+		}
+		n.keys[i] = append(key, ':')
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) WriteRow(row []any) error {
+	n.w.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			n.w.WriteByte(',')
+		}
+		n.w.Write(n.keys[i])
+		if s, ok := v.(string); ok {
+			data, err := json.Marshal(s)
+			if err != nil {
+				return err
+			}
+			n.w.Write(data)
+			continue
+		}
+		n.w.WriteString(formatValue(v))
+	}
+	n.w.WriteByte('}')
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return n.w.Flush()
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
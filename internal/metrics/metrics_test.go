@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests handled.", "route", "code")
+	r.Counter("upload_bytes_total", "Bytes uploaded.")
+	queued := r.Gauge("queued", "Jobs waiting.")
+	r.GaugeFunc("matches", "Stored matches.", func() float64 { return 3 })
+	durations := r.Histogram("duration_seconds", "How long it took.", []float64{0.1, 1}, "route")
+
+	requests.Inc("/b", "200")
+	requests.Inc("/a", "200")
+	requests.Add(2, "/a", "200")
+	requests.Inc(`/"quoted"`, "500")
+	queued.Add(2)
+	queued.Add(-1)
+	durations.Observe(0.05, "/a")
+	durations.Observe(0.1, "/a")
+	durations.Observe(5, "/a")
+
+	var out strings.Builder
+	if err := r.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP requests_total Requests handled.
+# TYPE requests_total counter
+requests_total{route="/\"quoted\"",code="500"} 1
+requests_total{route="/a",code="200"} 3
+requests_total{route="/b",code="200"} 1
+# HELP upload_bytes_total Bytes uploaded.
+# TYPE upload_bytes_total counter
+upload_bytes_total 0
+# HELP queued Jobs waiting.
+# TYPE queued gauge
+queued 1
+# HELP matches Stored matches.
+# TYPE matches gauge
+matches 3
+# HELP duration_seconds How long it took.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/a",le="0.1"} 2
+duration_seconds_bucket{route="/a",le="1"} 2
+duration_seconds_bucket{route="/a",le="+Inf"} 3
+duration_seconds_sum{route="/a"} 5.15
+duration_seconds_count{route="/a"} 3
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	c := NewRegistry().Counter("c", "", "route")
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for a missing label value")
+		}
+	}()
+	c.Inc()
+}
@@ -11,46 +11,205 @@ const (
 )
 
 type ParseJob struct {
-	ID       string    `json:"id"`
-	Status   JobStatus `json:"status"`
-	Error    string    `json:"error,omitempty"`
-	Progress float32   `json:"progress"`
+	ID          string    `json:"id"`
+	Status      JobStatus `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Progress    float32   `json:"progress"`
+	RoundsReady int       `json:"roundsReady"`
+
+	// The workspace the match will belong to, and the client that
+	// started the job.
+	Workspace string `json:"-"`
+	Client    string `json:"-"`
+
+	// The rounds parsed so far, viewable before the job is done.
+	partial *Match
 }
 
-// Simple in-memory store for tracking parse jobs.
+// Done reports whether the job has finished, successfully or not.
+func (j *ParseJob) Done() bool {
+	return j.Status != JobStatusParsing
+}
+
+// Simple in-memory store for tracking parse jobs. Jobs are handed out as
+// copies, so callers can read them while the parse keeps updating.
 type JobStore struct {
 	mu   sync.RWMutex
 	jobs map[string]*ParseJob
+
+	// Subscribers per job, each with room for one pending update.
+	subs map[string]map[chan ParseJob]struct{}
 }
 
 func NewJobStore() *JobStore {
-	return &JobStore{jobs: make(map[string]*ParseJob)}
+	return &JobStore{
+		jobs: make(map[string]*ParseJob),
+		subs: make(map[string]map[chan ParseJob]struct{}),
+	}
 }
 
-func (s *JobStore) Create(id string) *ParseJob {
+func (s *JobStore) Create(id, workspace, client string) ParseJob {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	job := &ParseJob{ID: id, Status: JobStatusParsing}
+	job := &ParseJob{ID: id, Status: JobStatusParsing, Workspace: workspace, Client: client}
 	s.jobs[id] = job
-	return job
+	return *job
+}
+
+// CreateLimited creates a job for each of ids, unless that would leave
+// client with more than limit jobs running; 0 means no limit. The check
+// and the creation happen under one lock, so concurrent requests can't
+// all get under the limit before any of them adds its jobs.
+func (s *JobStore) CreateLimited(limit int, workspace, client string, ids ...string) ([]ParseJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit > 0 && s.runningLocked(client)+len(ids) > limit {
+		return nil, false
+	}
+	jobs := make([]ParseJob, len(ids))
+	for i, id := range ids {
+		job := &ParseJob{ID: id, Status: JobStatusParsing, Workspace: workspace, Client: client}
+		s.jobs[id] = job
+		jobs[i] = *job
+	}
+	return jobs, true
 }
 
-func (s *JobStore) Get(id string) (*ParseJob, bool) {
+// Remove forgets jobs that were created but never started.
+func (s *JobStore) Remove(ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.jobs, id)
+	}
+}
+
+// Running returns how many of client's jobs haven't finished.
+func (s *JobStore) Running(client string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.runningLocked(client)
+}
+
+func (s *JobStore) runningLocked(client string) int {
+	n := 0
+	for _, job := range s.jobs {
+		if job.Client == client && !job.Done() {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *JobStore) Get(id string) (ParseJob, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	job, ok := s.jobs[id]
-	return job, ok
+	if !ok {
+		return ParseJob{}, false
+	}
+	return *job, true
 }
 
+// Subscribe returns a channel that receives the job's state, first as it is
+// now and then after each change. Updates a slow reader hasn't received yet
+// are replaced by newer ones. The channel is closed after the job finishes
+// or when the returned function is called.
+func (s *JobStore) Subscribe(id string) (<-chan ParseJob, func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, nil, false
+	}
+
+	ch := make(chan ParseJob, 1)
+	ch <- *job
+	if job.Done() {
+		close(ch)
+		return ch, func() {}, true
+	}
+
+	if s.subs[id] == nil {
+		s.subs[id] = make(map[chan ParseJob]struct{})
+	}
+	s.subs[id][ch] = struct{}{}
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[id][ch]; ok {
+			delete(s.subs[id], ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, true
+}
+
+// notifyLocked sends the job's state to its subscribers, closing their
+// channels once it's done.
+func (s *JobStore) notifyLocked(job *ParseJob) {
+	for ch := range s.subs[job.ID] {
+		// Only the store sends, and only under the lock, so after
+		// dropping a stale update there is always room for this one.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- *job
+
+		if job.Done() {
+			close(ch)
+		}
+	}
+	if job.Done() {
+		delete(s.subs, job.ID)
+	}
+}
+
+// SetProgress records parse progress. Subscribers hear about it in whole
+// percent steps rather than on every frame.
 func (s *JobStore) SetProgress(id string, progress float32) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if job, ok := s.jobs[id]; ok {
+		changed := int(progress*100) != int(job.Progress*100)
 		job.Progress = progress
+		if changed {
+			s.notifyLocked(job)
+		}
+	}
+}
+
+// SetPartial publishes the match parsed so far. The store keeps match as
+// is, so it must not be modified afterwards.
+func (s *JobStore) SetPartial(id string, match *Match) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok && !job.Done() {
+		job.partial = match
+		job.RoundsReady = len(match.Rounds)
+		s.notifyLocked(job)
+	}
+}
+
+// Partial returns the rounds published for a job that is still parsing,
+// or false if there are none.
+func (s *JobStore) Partial(id string) (*Match, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.Done() || job.partial == nil {
+		return nil, false
 	}
+	return job.partial, true
 }
 
 func (s *JobStore) Complete(id string) {
@@ -60,6 +219,8 @@ func (s *JobStore) Complete(id string) {
 	if job, ok := s.jobs[id]; ok {
 		job.Status = JobStatusReady
 		job.Progress = 1.0
+		job.partial = nil
+		s.notifyLocked(job)
 	}
 }
 
@@ -70,5 +231,7 @@ func (s *JobStore) Fail(id string, err error) {
 	if job, ok := s.jobs[id]; ok {
 		job.Status = JobStatusError
 		job.Error = err.Error()
+		job.partial = nil
+		s.notifyLocked(job)
 	}
 }
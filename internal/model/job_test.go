@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestJobSubscribe(t *testing.T) {
+	jobs := NewJobStore()
+	jobs.Create("a", "", "")
+
+	updates, unsubscribe, ok := jobs.Subscribe("a")
+	if !ok {
+		t.Fatal("Subscribe failed for existing job")
+	}
+	defer unsubscribe()
+
+	if job := <-updates; job.Status != JobStatusParsing {
+		t.Fatalf("initial status = %q", job.Status)
+	}
+
+	// Updates the reader hasn't picked up are coalesced into the latest.
+	jobs.SetProgress("a", 0.2)
+	jobs.SetProgress("a", 0.5)
+	jobs.SetProgress("a", 0.505) // same percent, no update
+	if job := <-updates; job.Progress != 0.5 {
+		t.Fatalf("progress = %v, want 0.5", job.Progress)
+	}
+
+	jobs.SetPartial("a", &Match{Rounds: make([]Round, 3)})
+	if partial, ok := jobs.Partial("a"); !ok || len(partial.Rounds) != 3 {
+		t.Fatalf("Partial = %v, %v", partial, ok)
+	}
+	jobs.Fail("a", errors.New("boom"))
+	job, ok := <-updates
+	if !ok || job.Status != JobStatusError || job.Error != "boom" || job.RoundsReady != 3 {
+		t.Fatalf("final update = %+v, %v", job, ok)
+	}
+	if _, ok := <-updates; ok {
+		t.Fatal("channel not closed after the job finished")
+	}
+	if _, ok := jobs.Partial("a"); ok {
+		t.Error("partial match kept after the job finished")
+	}
+
+	if _, _, ok := jobs.Subscribe("missing"); ok {
+		t.Error("Subscribe succeeded for unknown job")
+	}
+}
+
+func TestSubscribeToFinishedJob(t *testing.T) {
+	jobs := NewJobStore()
+	jobs.Create("a", "", "")
+	jobs.Complete("a")
+
+	updates, _, _ := jobs.Subscribe("a")
+	if job := <-updates; job.Status != JobStatusReady {
+		t.Fatalf("status = %q", job.Status)
+	}
+	if _, ok := <-updates; ok {
+		t.Fatal("channel for a finished job should be closed")
+	}
+}
+
+func TestRunning(t *testing.T) {
+	jobs := NewJobStore()
+	jobs.Create("a", "", "alice")
+	jobs.Create("b", "", "alice")
+	jobs.Create("c", "", "bob")
+
+	if n := jobs.Running("alice"); n != 2 {
+		t.Fatalf("running = %d, want 2", n)
+	}
+	jobs.Complete("a")
+	if n := jobs.Running("alice"); n != 1 {
+		t.Fatalf("running = %d after one finished, want 1", n)
+	}
+}
+
+func TestJobCreateLimited(t *testing.T) {
+	jobs := NewJobStore()
+	if _, ok := jobs.CreateLimited(2, "", "c", "a", "b", "c"); ok {
+		t.Error("created three jobs under a limit of two")
+	}
+	if created, ok := jobs.CreateLimited(2, "w", "c", "a", "b"); !ok || len(created) != 2 || created[1].Workspace != "w" {
+		t.Fatalf("CreateLimited = %+v, %v", created, ok)
+	}
+
+	// Only one of many concurrent requests gets the slot freed up.
+	jobs.Complete("a")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	admitted := 0
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := jobs.CreateLimited(2, "", "c", fmt.Sprint("job", i)); ok {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if admitted != 1 || jobs.Running("c") != 2 {
+		t.Errorf("admitted %d jobs with %d running, want 1 and 2", admitted, jobs.Running("c"))
+	}
+
+	jobs.Remove("b")
+	if _, ok := jobs.Get("b"); ok || jobs.Running("c") != 1 {
+		t.Error("removed job still there")
+	}
+	if _, ok := jobs.CreateLimited(0, "", "c", "x", "y", "z"); !ok {
+		t.Error("no limit refused jobs")
+	}
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Match struct {
 	ID        string     `json:"id"`
 	Map       string     `json:"map"`
@@ -8,6 +10,15 @@ type Match struct {
 	Teams     Teams      `json:"teams"`
 	Rounds    []Round    `json:"rounds"`
 	MapConfig *MapConfig `json:"mapConfig,omitempty"`
+	ParsedAt  time.Time  `json:"parsedAt"`
+
+	// The workspace that uploaded the match; empty for matches uploaded
+	// without authentication.
+	Workspace string `json:"workspace,omitempty"`
+
+	// Nil for matches parsed before the options existed, which used the
+	// defaults.
+	Options *ParseOptions `json:"options,omitempty"`
 }
 
 type Teams struct {
@@ -23,6 +34,10 @@ type TeamInfo struct {
 type PlayerInfo struct {
 	SteamID uint64 `json:"steamId"`
 	Name    string `json:"name"`
+
+	// Entity index of the player's controller, which is what spec_player
+	// takes in demo playback.
+	Slot int `json:"slot,omitempty"`
 }
 
 type Round struct {
@@ -31,9 +46,37 @@ type Round struct {
 	WinReason  string         `json:"winReason"`
 	EndTScore  int            `json:"endTScore"`
 	EndCTScore int            `json:"endCTScore"`
+	EndTick    int            `json:"endTick,omitempty"`
+	EndTime    float64        `json:"endTime,omitempty"`
+	Plant      *BombEvent     `json:"plant,omitempty"`
+	Defuse     *BombEvent     `json:"defuse,omitempty"`
+	Clutch     *Clutch        `json:"clutch,omitempty"`
 	Snapshots  []Snapshot     `json:"snapshots"`
 	Kills      []KillEvent    `json:"kills"`
 	Grenades   []GrenadeEvent `json:"grenades"`
+	WinProb    []WinProbPoint `json:"winProb,omitempty"`
+}
+
+// Clutch is a 1vX situation: the last player alive on their side against
+// Opponents enemies, from StartTime until they died or the round ended.
+type Clutch struct {
+	Player    uint64  `json:"player"`
+	Side      string  `json:"side"`
+	Opponents int     `json:"opponents"`
+	Won       bool    `json:"won"`
+	Survived  bool    `json:"survived"`
+	Kills     int     `json:"kills"`
+	StartTick int     `json:"startTick"`
+	StartTime float64 `json:"startTime"`
+	EndTime   float64 `json:"endTime"`
+}
+
+// WinProbPoint is the estimated probability that CT wins the round, as
+// evaluated at one snapshot.
+type WinProbPoint struct {
+	Tick        int     `json:"tick"`
+	TimeInRound float64 `json:"timeInRound"`
+	CT          float64 `json:"ct"`
 }
 
 type Snapshot struct {
@@ -50,6 +93,16 @@ type BombState struct {
 	Carrier uint64  `json:"carrier,omitempty"`
 }
 
+// BombEvent records where and by whom the bomb was planted or defused.
+type BombEvent struct {
+	Tick        int     `json:"tick"`
+	TimeInRound float64 `json:"timeInRound"`
+	Player      uint64  `json:"player"`
+	Site        string  `json:"site"`
+	X           float64 `json:"x"`
+	Y           float64 `json:"y"`
+}
+
 type PlayerState struct {
 	SteamID    uint64   `json:"steamId"`
 	Name       string   `json:"name"`
@@ -66,21 +119,36 @@ type PlayerState struct {
 	Grenades   []string `json:"grenades"`
 	HasDefuser bool     `json:"hasDefuser"`
 	Money      int      `json:"money"`
+	EquipValue int      `json:"equipValue"`
 	FlashAlpha float64  `json:"flashAlpha"`
+	Place      string   `json:"place,omitempty"`
 }
 
 type KillEvent struct {
-	Tick        int     `json:"tick"`
-	TimeInRound float64 `json:"timeInRound"`
-	Attacker    uint64  `json:"attacker"`
-	Victim      uint64  `json:"victim"`
-	Weapon      string  `json:"weapon"`
-	Headshot    bool    `json:"headshot"`
-	Wallbang    bool    `json:"wallbang"`
-	AttackerX   float64 `json:"attackerX"`
-	AttackerY   float64 `json:"attackerY"`
-	VictimX     float64 `json:"victimX"`
-	VictimY     float64 `json:"victimY"`
+	Tick          int     `json:"tick"`
+	TimeInRound   float64 `json:"timeInRound"`
+	Attacker      uint64  `json:"attacker"`
+	Victim        uint64  `json:"victim"`
+	Weapon        string  `json:"weapon"`
+	Headshot      bool    `json:"headshot"`
+	Wallbang      bool    `json:"wallbang"`
+	NoScope       bool    `json:"noScope"`
+	ThroughSmoke  bool    `json:"throughSmoke"`
+	AttackerBlind bool    `json:"attackerBlind"`
+	AttackerX     float64 `json:"attackerX"`
+	AttackerY     float64 `json:"attackerY"`
+	VictimX       float64 `json:"victimX"`
+	VictimY       float64 `json:"victimY"`
+
+	AttackerPlace string `json:"attackerPlace,omitempty"`
+	VictimPlace   string `json:"victimPlace,omitempty"`
+
+	// Traded is set when the attacker was killed by a teammate of the victim
+	// shortly after, TradeTime seconds later. Refrag marks the kill that
+	// did the trading.
+	Traded    bool    `json:"traded"`
+	TradeTime float64 `json:"tradeTime,omitempty"`
+	Refrag    bool    `json:"refrag"`
 }
 
 type GrenadeEvent struct {
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Event families that can be left out of a parse with ParseOptions.Events.
+const (
+	EventsKills    = "kills"
+	EventsGrenades = "grenades"
+	EventsBomb     = "bomb"
+)
+
+var EventFamilies = []string{EventsKills, EventsGrenades, EventsBomb}
+
+// ParseOptions controls how much of a demo the parser records. The options
+// a match was parsed with are stored on it.
+type ParseOptions struct {
+	// Snapshots recorded per second of game time. 0, or any rate at or
+	// above the demo's tick rate, records every tick.
+	SnapshotRate float64 `json:"snapshotRate"`
+
+	// Seconds to keep recording after a round is decided.
+	PostRoundSeconds float64 `json:"postRoundSeconds"`
+
+	// Maximum waypoints kept per grenade trajectory; 0 keeps every point,
+	// which is one per snapshot.
+	TrajectoryPoints int `json:"trajectoryPoints"`
+
+	// Event families to collect; empty collects all of them.
+	Events []string `json:"events,omitempty"`
+}
+
+// DefaultParseOptions returns the options used when none are given.
+func DefaultParseOptions() ParseOptions {
+	return ParseOptions{
+		SnapshotRate:     5,
+		PostRoundSeconds: 3,
+		TrajectoryPoints: 10,
+	}
+}
+
+// Validate reports the first option that is out of range.
+func (o *ParseOptions) Validate() error {
+	if o.SnapshotRate < 0 || !finite(o.SnapshotRate) {
+		return fmt.Errorf("snapshot rate must be a non-negative number")
+	}
+	if !(o.PostRoundSeconds >= 0 && o.PostRoundSeconds <= 30) {
+		return fmt.Errorf("post-round buffer must be between 0 and 30 seconds")
+	}
+	if o.TrajectoryPoints < 0 || o.TrajectoryPoints == 1 {
+		return fmt.Errorf("trajectory points must be 0 or at least 2")
+	}
+	for _, e := range o.Events {
+		if !slices.Contains(EventFamilies, e) {
+			return fmt.Errorf("unknown event family %q", e)
+		}
+	}
+	return nil
+}
+
+// Collects reports whether the given event family is recorded.
+func (o *ParseOptions) Collects(family string) bool {
+	return len(o.Events) == 0 || slices.Contains(o.Events, family)
+}
+
+// ParseSnapshotRate reads a snapshot rate as given in the upload form or on
+// the command line: a number of snapshots per second, or "tick" for every
+// tick.
+func ParseSnapshotRate(s string) (float64, error) {
+	if s == "tick" {
+		return 0, nil
+	}
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || rate <= 0 || !finite(rate) {
+		return 0, fmt.Errorf("invalid snapshot rate %q", s)
+	}
+	return rate, nil
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
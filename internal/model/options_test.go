@@ -0,0 +1,54 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseOptionsValidate(t *testing.T) {
+	defaults := DefaultParseOptions()
+	if err := defaults.Validate(); err != nil {
+		t.Fatalf("defaults invalid: %v", err)
+	}
+
+	invalid := []ParseOptions{
+		{SnapshotRate: -1},
+		{SnapshotRate: math.NaN()},
+		{SnapshotRate: math.Inf(1)},
+		{PostRoundSeconds: 60},
+		{PostRoundSeconds: math.NaN()},
+		{PostRoundSeconds: math.Inf(-1)},
+		{TrajectoryPoints: 1},
+		{Events: []string{"damage"}},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", opts)
+		}
+	}
+}
+
+func TestParseSnapshotRate(t *testing.T) {
+	if rate, err := ParseSnapshotRate("tick"); err != nil || rate != 0 {
+		t.Errorf(`ParseSnapshotRate("tick") = %v, %v`, rate, err)
+	}
+	if rate, err := ParseSnapshotRate("16"); err != nil || rate != 16 {
+		t.Errorf(`ParseSnapshotRate("16") = %v, %v`, rate, err)
+	}
+	for _, s := range []string{"0", "-5", "fast", "NaN", "nan", "Inf", "+Inf", "infinity"} {
+		if _, err := ParseSnapshotRate(s); err == nil {
+			t.Errorf("ParseSnapshotRate(%q) = nil error", s)
+		}
+	}
+}
+
+func TestCollects(t *testing.T) {
+	all := ParseOptions{}
+	if !all.Collects(EventsGrenades) {
+		t.Error("empty Events should collect everything")
+	}
+	killsOnly := ParseOptions{Events: []string{EventsKills}}
+	if !killsOnly.Collects(EventsKills) || killsOnly.Collects(EventsBomb) {
+		t.Error("Events filter not applied")
+	}
+}
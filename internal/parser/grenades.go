@@ -11,10 +11,6 @@ import (
 )
 
 const (
-	// Max trajectory waypoints per grenade. Beyond this we downsample to
-	// keep the JSON lean — the frontend lerps between points anyway.
-	maxTrajectoryPoints = 10
-
 	smokeDuration      = 18.0
 	molotovMaxDuration = 7.0
 	decoyDuration      = 15.0
@@ -95,10 +91,10 @@ func (c *roundCollector) finalizeGrenade(id int, x, y float64, p demoinfocs.Pars
 		X:           x,
 		Y:           y,
 	})
-	ig.event.Trajectory = downsampleTrajectory(ig.trajectory, maxTrajectoryPoints)
+	ig.event.Trajectory = downsampleTrajectory(ig.trajectory, c.opts.TrajectoryPoints)
 
 	idx := len(c.grenades)
-	c.grenades = append(c.grenades, ig.event)
+	c.addGrenade(ig.event)
 	delete(c.inflight, id)
 	return idx
 }
@@ -243,12 +239,19 @@ func (c *roundCollector) findGrenadeByTypeAndPos(typ string, x, y float64) int {
 // finalizeInflightGrenades commits any grenades still mid-air at round end.
 func (c *roundCollector) finalizeInflightGrenades() {
 	for id, ig := range c.inflight {
-		ig.event.Trajectory = downsampleTrajectory(ig.trajectory, maxTrajectoryPoints)
-		c.grenades = append(c.grenades, ig.event)
+		ig.event.Trajectory = downsampleTrajectory(ig.trajectory, c.opts.TrajectoryPoints)
+		c.addGrenade(ig.event)
 		delete(c.inflight, id)
 	}
 }
 
+func (c *roundCollector) addGrenade(g models.GrenadeEvent) {
+	c.grenades = append(c.grenades, g)
+	if c.hooks.Grenade != nil {
+		c.hooks.Grenade(c.current.Number, g)
+	}
+}
+
 // sampleGrenadePositions records the current position of each in-flight
 // grenade. Called at the snapshot sampling interval, not every tick.
 func (c *roundCollector) sampleGrenadePositions(gs demoinfocs.GameState, p demoinfocs.Parser) {
@@ -301,8 +304,10 @@ func quantizePos(x, y float64) [2]int {
 
 // downsampleTrajectory reduces a trajectory to at most maxPoints using
 // largest-triangle-three-buckets, preserving the first and last points.
+// The frontend lerps between points, so a handful keeps the match lean
+// without visible loss. A maxPoints of 0 keeps every point.
 func downsampleTrajectory(pts []models.TrajectoryPoint, maxPoints int) []models.TrajectoryPoint {
-	if len(pts) <= maxPoints {
+	if maxPoints == 0 || len(pts) <= maxPoints {
 		return pts
 	}
 
@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	demoinfocs "github.com/markus-wa/demoinfocs-golang/v5/pkg/demoinfocs"
@@ -13,26 +15,81 @@ import (
 	models "github.com/allending313/cs2-demo-parser/internal/model"
 )
 
-const (
-	snapshotsPerSecond     = 5
-	postRoundBufferSeconds = 3.0
+var (
+	// ErrRead wraps errors reading the demo, e.g. an upload that broke
+	// off.
+	ErrRead = errors.New("reading demo")
+
+	// ErrInvalidDemo wraps errors from demo data the parser can't make
+	// sense of.
+	ErrInvalidDemo = errors.New("parsing demo")
 )
 
 // ProgressFunc is called periodically with a value between 0 and 1.
 type ProgressFunc func(progress float32)
 
-func ParseDemo(filePath, matchID string, onProgress ProgressFunc) (*models.Match, error) {
+// Hooks report on a parse while it runs. Any of them may be nil. For
+// progress, wrap the input in a ProgressReader.
+type Hooks struct {
+	// Round is called each time a round is complete, with the match parsed
+	// so far; the new round is the last one. Parsing keeps writing to the
+	// match afterwards, so anything kept must be copied.
+	Round func(match *models.Match)
+
+	// Snapshot, Kill and Grenade are called as each is recorded, with the
+	// number of the round it belongs to, for following a match live.
+	// Grenades are reported once they detonate, or at the end of the round.
+	Snapshot func(round int, snapshot models.Snapshot)
+	Kill     func(round int, kill models.KillEvent)
+	Grenade  func(round int, grenade models.GrenadeEvent)
+}
+
+// ParseDemo parses the demo file at filePath.
+func ParseDemo(filePath, matchID string, opts models.ParseOptions, hooks Hooks) (*models.Match, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("opening demo: %w", err)
 	}
 	defer f.Close()
 
-	p := demoinfocs.NewParser(f)
+	return ParseReader(context.Background(), f, matchID, opts, hooks)
+}
+
+// ParseReader parses a demo as it is read from r, so parsing can keep pace
+// with an upload. It stops early with ctx's error if ctx is cancelled.
+func ParseReader(ctx context.Context, r io.Reader, matchID string, opts models.ParseOptions, hooks Hooks) (*models.Match, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return parse(ctx, r, demoinfocs.DefaultParserConfig, matchID, opts, hooks, false)
+}
+
+// ParseBroadcast parses a live broadcast read from r, a broadcast.Reader,
+// as its fragments arrive. It returns the match once the broadcast ends.
+// Cancelling ctx or losing the connection to the relay ends it early,
+// keeping what was parsed up to then.
+func ParseBroadcast(ctx context.Context, r io.Reader, matchID string, opts models.ParseOptions, hooks Hooks) (*models.Match, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	cfg := demoinfocs.DefaultParserConfig
+	cfg.Format = demoinfocs.DemoFormatCSTVBroadcast
+	return parse(ctx, r, cfg, matchID, opts, hooks, true)
+}
+
+// parse parses r to the end. If ctx is cancelled or r fails, it returns the
+// error, unless live is set: then it returns the match parsed so far.
+func parse(ctx context.Context, r io.Reader, cfg demoinfocs.ParserConfig, matchID string, opts models.ParseOptions, hooks Hooks, live bool) (*models.Match, error) {
+	src := &eofReader{r: r}
+	p := demoinfocs.NewParserWithConfig(src, cfg)
 	defer p.Close()
 
-	match := &models.Match{ID: matchID}
-	collector := newRoundCollector(match)
+	stop := context.AfterFunc(ctx, p.Cancel)
+	defer stop()
+
+	match := &models.Match{ID: matchID, Options: &opts}
+	collector := newRoundCollector(match, opts)
+	collector.hooks = hooks
 
 	p.RegisterNetMessageHandler(func(srvInfo *msg.CSVCMsg_ServerInfo) {
 		match.Map = srvInfo.GetMapName()
@@ -58,12 +115,11 @@ func ParseDemo(filePath, matchID string, onProgress ProgressFunc) (*models.Match
 	})
 
 	p.RegisterEventHandler(func(e events.BombPlanted) {
-		collector.bombState = "planted"
-		collector.bombCarrier = 0
+		collector.onBombPlanted(e, p)
 	})
 
 	p.RegisterEventHandler(func(e events.BombDefused) {
-		collector.bombState = "defused"
+		collector.onBombDefused(e, p)
 	})
 
 	p.RegisterEventHandler(func(e events.BombExplode) {
@@ -84,47 +140,55 @@ func ParseDemo(filePath, matchID string, onProgress ProgressFunc) (*models.Match
 
 	p.RegisterEventHandler(func(e events.FrameDone) {
 		collector.onFrame(p)
-
-		if onProgress != nil {
-			onProgress(p.Progress())
-		}
 	})
 
-	p.RegisterEventHandler(func(e events.GrenadeProjectileThrow) {
-		collector.onGrenadeThrow(e, p)
-	})
+	if opts.Collects(models.EventsGrenades) {
+		p.RegisterEventHandler(func(e events.GrenadeProjectileThrow) {
+			collector.onGrenadeThrow(e, p)
+		})
 
-	p.RegisterEventHandler(func(e events.GrenadeProjectileDestroy) {
-		collector.onGrenadeDestroy(e, p)
-	})
+		p.RegisterEventHandler(func(e events.GrenadeProjectileDestroy) {
+			collector.onGrenadeDestroy(e, p)
+		})
 
-	p.RegisterEventHandler(func(e events.HeExplode) {
-		collector.onHeExplode(e, p)
-	})
+		p.RegisterEventHandler(func(e events.HeExplode) {
+			collector.onHeExplode(e, p)
+		})
 
-	p.RegisterEventHandler(func(e events.SmokeStart) {
-		collector.onSmokeStart(e, p)
-	})
+		p.RegisterEventHandler(func(e events.SmokeStart) {
+			collector.onSmokeStart(e, p)
+		})
 
-	p.RegisterEventHandler(func(e events.SmokeExpired) {
-		collector.onSmokeExpired(e, p)
-	})
+		p.RegisterEventHandler(func(e events.SmokeExpired) {
+			collector.onSmokeExpired(e, p)
+		})
 
-	p.RegisterEventHandler(func(e events.InfernoStart) {
-		collector.onInfernoStart(e, p)
-	})
+		p.RegisterEventHandler(func(e events.InfernoStart) {
+			collector.onInfernoStart(e, p)
+		})
 
-	p.RegisterEventHandler(func(e events.InfernoExpired) {
-		collector.onInfernoExpired(e, p)
-	})
+		p.RegisterEventHandler(func(e events.InfernoExpired) {
+			collector.onInfernoExpired(e, p)
+		})
 
-	p.RegisterEventHandler(func(e events.DecoyStart) {
-		collector.onDecoyStart(e, p)
-	})
+		p.RegisterEventHandler(func(e events.DecoyStart) {
+			collector.onDecoyStart(e, p)
+		})
+	}
 
 	if err := p.ParseToEnd(); err != nil {
-		if !errors.Is(err, demoinfocs.ErrUnexpectedEndOfDemo) {
-			return nil, fmt.Errorf("parsing demo: %w", err)
+		// Cancelling can also surface as a failed read, so ctx comes first.
+		switch {
+		case live && (ctx.Err() != nil || src.err != nil):
+			// The broadcast ended early; keep what was parsed.
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case src.err != nil:
+			return nil, fmt.Errorf("%w: %w", ErrRead, src.err)
+		case errors.Is(err, demoinfocs.ErrUnexpectedEndOfDemo):
+			// Truncated demos are parsed as far as they go.
+		default:
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDemo, err)
 		}
 	}
 
@@ -133,41 +197,58 @@ func ParseDemo(filePath, matchID string, onProgress ProgressFunc) (*models.Match
 
 	match.TickRate = p.TickRate()
 	match.Duration = p.CurrentTime().Seconds()
-	match.Teams = buildTeams(match.Rounds)
+	match.Teams = collector.roster.teams(collector.slots)
+	match.Teams.CT.Name = p.GameState().TeamCounterTerrorists().ClanName()
+	match.Teams.T.Name = p.GameState().TeamTerrorists().ClanName()
 
 	return match, nil
 }
 
-// buildTeams constructs team rosters from snapshot data across all rounds.
-func buildTeams(rounds []models.Round) models.Teams {
-	type playerRecord struct {
-		steamID uint64
-		name    string
-		team    string
+// eofReader ends the stream at the first read error, which demoinfocs
+// would otherwise re-panic, and keeps the error to report it.
+type eofReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF {
+		e.err = err
+		err = io.EOF
 	}
+	return n, err
+}
 
-	seen := make(map[uint64]*playerRecord)
-
-	for i := range rounds {
-		for _, snap := range rounds[i].Snapshots {
-			for _, ps := range snap.Players {
-				if ps.SteamID == 0 || ps.Team == "" {
-					continue
-				}
-				seen[ps.SteamID] = &playerRecord{
-					steamID: ps.SteamID,
-					name:    ps.Name,
-					team:    ps.Team,
-				}
+// roster keeps the latest name and side of every player seen in the
+// snapshots of committed rounds.
+type roster map[uint64]rosterEntry
+
+type rosterEntry struct {
+	name string
+	team string
+}
+
+// add records the players in a round's snapshots.
+func (r roster) add(round *models.Round) {
+	for _, snap := range round.Snapshots {
+		for _, ps := range snap.Players {
+			if ps.SteamID == 0 || ps.Team == "" {
+				continue
 			}
+			r[ps.SteamID] = rosterEntry{name: ps.Name, team: ps.Team}
 		}
 	}
+}
 
+// teams splits the roster into the two sides.
+func (r roster) teams(slots map[uint64]int) models.Teams {
 	var teams models.Teams
-	for _, rec := range seen {
+	for id, rec := range r {
 		info := models.PlayerInfo{
-			SteamID: rec.steamID,
+			SteamID: id,
 			Name:    rec.name,
+			Slot:    slots[id],
 		}
 		switch rec.team {
 		case "ct":
@@ -191,6 +272,17 @@ func teamToString(team common.Team) string {
 	}
 }
 
+func bombsiteToString(site events.Bombsite) string {
+	switch site {
+	case events.BombsiteA:
+		return "A"
+	case events.BombsiteB:
+		return "B"
+	default:
+		return ""
+	}
+}
+
 func roundEndReasonToString(reason events.RoundEndReason) string {
 	switch reason {
 	case events.RoundEndReasonTerroristsWin, events.RoundEndReasonCTWin,
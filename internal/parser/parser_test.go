@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestRosterKeepsLatestSide(t *testing.T) {
+	r := make(roster)
+	r.add(&models.Round{Snapshots: []models.Snapshot{{Players: []models.PlayerState{
+		{SteamID: 1, Name: "one", Team: "ct"},
+		{SteamID: 2, Name: "two", Team: "t"},
+		{SteamID: 3, Name: "spectator"},
+	}}}})
+	// Sides swap at half time and player 1 renames.
+	r.add(&models.Round{Snapshots: []models.Snapshot{{Players: []models.PlayerState{
+		{SteamID: 1, Name: "uno", Team: "t"},
+		{SteamID: 2, Name: "two", Team: "ct"},
+	}}}})
+
+	teams := r.teams(map[uint64]int{1: 4})
+	if len(teams.CT.Players) != 1 || teams.CT.Players[0] != (models.PlayerInfo{SteamID: 2, Name: "two"}) {
+		t.Errorf("CT = %+v, want only player 2", teams.CT.Players)
+	}
+	if len(teams.T.Players) != 1 || teams.T.Players[0] != (models.PlayerInfo{SteamID: 1, Name: "uno", Slot: 4}) {
+		t.Errorf("T = %+v, want only player 1 as uno in slot 4", teams.T.Players)
+	}
+}
@@ -0,0 +1,36 @@
+package parser
+
+import "io"
+
+// ProgressReader reports how much of a demo has been read, as a fraction
+// of its size. The parser can't tell how far through a CS2 demo it is:
+// the demo's length is only known once its end is reached. Wrap the raw
+// input, before any decompression, so that what's read adds up to size.
+type ProgressReader struct {
+	r        io.Reader
+	size     int64
+	read     int64
+	percent  int
+	progress ProgressFunc
+}
+
+// NewProgressReader returns a reader calling progress each time another
+// whole percent of size bytes has been read from r. Without a known size
+// (size <= 0) it never calls progress.
+func NewProgressReader(r io.Reader, size int64, progress ProgressFunc) *ProgressReader {
+	return &ProgressReader{r: r, size: size, progress: progress}
+}
+
+func (p *ProgressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.size > 0 && n > 0 {
+		// Anything past size (e.g. multipart trailers) counts as done.
+		percent := int(min(p.read*100/p.size, 100))
+		if percent != p.percent {
+			p.percent = percent
+			p.progress(float32(percent) / 100)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestProgressReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+
+	// Read in uneven pieces, as from an upload.
+	var reports []float32
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < len(data); i += 37 {
+			pw.Write(data[i:min(i+37, len(data))])
+		}
+		pw.Close()
+	}()
+	r := NewProgressReader(iotest.HalfReader(pr), int64(len(data)), func(p float32) {
+		reports = append(reports, p)
+	})
+	n, err := io.Copy(io.Discard, r)
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("read %d bytes, %v", n, err)
+	}
+
+	if len(reports) < 10 {
+		t.Fatalf("got %d progress reports, want one per percent reached: %v", len(reports), reports)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i] <= reports[i-1] {
+			t.Fatalf("progress went from %v to %v", reports[i-1], reports[i])
+		}
+	}
+	if last := reports[len(reports)-1]; last != 1 {
+		t.Errorf("final progress %v, want 1", last)
+	}
+}
+
+func TestProgressReaderBeyondSize(t *testing.T) {
+	var reports []float32
+	r := NewProgressReader(bytes.NewReader(make([]byte, 150)), 100, func(p float32) {
+		reports = append(reports, p)
+	})
+	io.Copy(io.Discard, r)
+	if len(reports) != 1 || reports[0] != 1 {
+		t.Errorf("reports %v, want a single 1", reports)
+	}
+}
+
+func TestProgressReaderUnknownSize(t *testing.T) {
+	r := NewProgressReader(bytes.NewReader(make([]byte, 100)), 0, func(float32) {
+		t.Error("progress reported without a size")
+	})
+	io.Copy(io.Discard, r)
+}
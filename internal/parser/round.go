@@ -12,11 +12,20 @@ import (
 
 type roundCollector struct {
 	match *models.Match
+	opts  models.ParseOptions
+
+	// Reported to as rounds and their events are recorded.
+	hooks Hooks
+
+	// Clan names, for rosters built before parsing ends.
+	ctName, tName string
 
 	current          *models.Round
 	snapshots        []models.Snapshot
 	kills            []models.KillEvent
 	grenades         []models.GrenadeEvent
+	plant            *models.BombEvent
+	defuse           *models.BombEvent
 	roundStartTick   int
 	lastSnapshotTick int
 	sampleInterval   int
@@ -34,6 +43,12 @@ type roundCollector struct {
 	bombState   string
 	bombCarrier uint64
 
+	// Last seen entity index per player, for spectating them in playback.
+	slots map[uint64]int
+
+	// Players seen in the rounds committed so far.
+	roster roster
+
 	// In-flight grenades keyed by entity ID. Populated on throw, finalized
 	// on the corresponding detonation/destroy event.
 	inflight map[int]*inflightGrenade
@@ -46,9 +61,12 @@ type roundCollector struct {
 	infernoByUID map[int64]int
 }
 
-func newRoundCollector(match *models.Match) *roundCollector {
+func newRoundCollector(match *models.Match, opts models.ParseOptions) *roundCollector {
 	return &roundCollector{
 		match:        match,
+		opts:         opts,
+		slots:        make(map[uint64]int),
+		roster:       make(roster),
 		inflight:     make(map[int]*inflightGrenade),
 		smokeByPos:   make(map[[2]int]int),
 		infernoByUID: make(map[int64]int),
@@ -65,18 +83,25 @@ func (c *roundCollector) onFreezetimeEnd(p demoinfocs.Parser) {
 	c.snapshots = nil
 	c.kills = nil
 	c.grenades = nil
+	c.plant = nil
+	c.defuse = nil
 	c.pendingEnd = false
 	c.roundStartTick = gs.IngameTick()
 	c.lastSnapshotTick = 0
 
+	c.ctName = gs.TeamCounterTerrorists().ClanName()
+	c.tName = gs.TeamTerrorists().ClanName()
+
 	tickRate := p.TickRate()
-	if tickRate > 0 {
-		c.sampleInterval = int(math.Round(tickRate / float64(snapshotsPerSecond)))
-		c.postRoundTicks = int(tickRate * postRoundBufferSeconds)
+	c.match.TickRate = tickRate
+	if tickRate <= 0 {
+		tickRate = 64
 	}
-	if c.sampleInterval < 1 {
-		c.sampleInterval = 13
+	c.sampleInterval = 1
+	if c.opts.SnapshotRate > 0 {
+		c.sampleInterval = max(1, int(math.Round(tickRate/c.opts.SnapshotRate)))
 	}
+	c.postRoundTicks = int(tickRate * c.opts.PostRoundSeconds)
 
 	c.bombState = ""
 	c.bombCarrier = 0
@@ -107,6 +132,8 @@ func (c *roundCollector) onRoundEnd(e events.RoundEnd, p demoinfocs.Parser) {
 	// Don't finalize yet — continue capturing frames for the post-round
 	c.pendingEnd = true
 	c.roundEndTick = p.GameState().IngameTick()
+	c.current.EndTick = c.roundEndTick
+	c.current.EndTime = c.ticksToSeconds(c.roundEndTick, p)
 }
 
 // finalizePendingRound commits a round whose post-round buffer has expired.
@@ -119,23 +146,40 @@ func (c *roundCollector) finalizePendingRound() {
 	c.current.Snapshots = c.snapshots
 	c.current.Kills = c.kills
 	c.current.Grenades = c.grenades
+	c.current.Plant = c.plant
+	c.current.Defuse = c.defuse
+	c.commitRound()
+	c.pendingEnd = false
+}
+
+func (c *roundCollector) commitRound() {
 	c.match.Rounds = append(c.match.Rounds, *c.current)
+	c.roster.add(c.current)
 	c.current = nil
-	c.pendingEnd = false
+
+	if c.hooks.Round != nil {
+		c.match.Teams = c.roster.teams(c.slots)
+		c.match.Teams.CT.Name = c.ctName
+		c.match.Teams.T.Name = c.tName
+		c.hooks.Round(c.match)
+	}
 }
 
 func (c *roundCollector) onKill(e events.Kill, p demoinfocs.Parser) {
-	if c.current == nil {
+	if c.current == nil || !c.opts.Collects(models.EventsKills) {
 		return
 	}
 
 	gs := p.GameState()
 	tick := gs.IngameTick()
 	kill := models.KillEvent{
-		Tick:        tick,
-		TimeInRound: c.ticksToSeconds(tick, p),
-		Headshot:    e.IsHeadshot,
-		Wallbang:    e.PenetratedObjects > 0,
+		Tick:          tick,
+		TimeInRound:   c.ticksToSeconds(tick, p),
+		Headshot:      e.IsHeadshot,
+		Wallbang:      e.PenetratedObjects > 0,
+		NoScope:       e.NoScope,
+		ThroughSmoke:  e.ThroughSmoke,
+		AttackerBlind: e.AttackerBlind,
 	}
 
 	if e.Weapon != nil {
@@ -147,6 +191,7 @@ func (c *roundCollector) onKill(e events.Kill, p demoinfocs.Parser) {
 		pos := e.Killer.Position()
 		kill.AttackerX = pos.X
 		kill.AttackerY = pos.Y
+		kill.AttackerPlace = e.Killer.LastPlaceName()
 	}
 
 	if e.Victim != nil {
@@ -154,9 +199,50 @@ func (c *roundCollector) onKill(e events.Kill, p demoinfocs.Parser) {
 		pos := e.Victim.Position()
 		kill.VictimX = pos.X
 		kill.VictimY = pos.Y
+		kill.VictimPlace = e.Victim.LastPlaceName()
 	}
 
 	c.kills = append(c.kills, kill)
+	if c.hooks.Kill != nil {
+		c.hooks.Kill(c.current.Number, kill)
+	}
+}
+
+func (c *roundCollector) onBombPlanted(e events.BombPlanted, p demoinfocs.Parser) {
+	c.bombState = "planted"
+	c.bombCarrier = 0
+
+	if c.current == nil || !c.opts.Collects(models.EventsBomb) {
+		return
+	}
+
+	c.plant = c.newBombEvent(e.BombEvent, p)
+}
+
+func (c *roundCollector) onBombDefused(e events.BombDefused, p demoinfocs.Parser) {
+	c.bombState = "defused"
+
+	if c.current == nil || !c.opts.Collects(models.EventsBomb) {
+		return
+	}
+	c.defuse = c.newBombEvent(e.BombEvent, p)
+}
+
+func (c *roundCollector) newBombEvent(e events.BombEvent, p demoinfocs.Parser) *models.BombEvent {
+	tick := p.GameState().IngameTick()
+	be := &models.BombEvent{
+		Tick:        tick,
+		TimeInRound: c.ticksToSeconds(tick, p),
+		Site:        bombsiteToString(e.Site),
+	}
+
+	if e.Player != nil {
+		pos := e.Player.Position()
+		be.Player = e.Player.SteamID64
+		be.X = pos.X
+		be.Y = pos.Y
+	}
+	return be
 }
 
 func (c *roundCollector) onFrame(p demoinfocs.Parser) {
@@ -190,6 +276,8 @@ func (c *roundCollector) onFrame(p demoinfocs.Parser) {
 			continue
 		}
 
+		c.slots[player.SteamID64] = player.EntityID
+
 		pos := player.Position()
 		ps := models.PlayerState{
 			SteamID:    player.SteamID64,
@@ -205,6 +293,8 @@ func (c *roundCollector) onFrame(p demoinfocs.Parser) {
 			IsAlive:    player.IsAlive(),
 			HasDefuser: player.HasDefuseKit(),
 			Money:      player.Money(),
+			EquipValue: player.EquipmentValueCurrent(),
+			Place:      player.LastPlaceName(),
 		}
 
 		if w := player.ActiveWeapon(); w != nil {
@@ -226,6 +316,9 @@ func (c *roundCollector) onFrame(p demoinfocs.Parser) {
 	}
 
 	c.snapshots = append(c.snapshots, snapshot)
+	if c.hooks.Snapshot != nil {
+		c.hooks.Snapshot(c.current.Number, snapshot)
+	}
 	c.sampleGrenadePositions(gs, p)
 }
 
@@ -260,8 +353,9 @@ func (c *roundCollector) flush() {
 
 	c.current.Snapshots = c.snapshots
 	c.current.Kills = c.kills
-	c.match.Rounds = append(c.match.Rounds, *c.current)
-	c.current = nil
+	c.current.Plant = c.plant
+	c.current.Defuse = c.defuse
+	c.commitRound()
 }
 
 func (c *roundCollector) ticksToSeconds(tick int, p demoinfocs.Parser) float64 {
@@ -0,0 +1,110 @@
+// Package ratelimit limits how often each client may do something, with a
+// token bucket per client.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter allows each key a number of events per period. A key that has
+// been idle may use the whole allowance at once, then has to wait for it
+// to refill. A nil Limiter allows everything.
+type Limiter struct {
+	// Tokens added per second, and the most a bucket holds.
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New returns a limiter allowing limit events per period for each key, or
+// nil, allowing everything, if limit isn't positive.
+func New(limit int, per time.Duration) *Limiter {
+	if limit <= 0 || per <= 0 {
+		return nil
+	}
+	return &Limiter{
+		rate:    float64(limit) / per.Seconds(),
+		burst:   float64(limit),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow records an event for key and reports whether it's within the
+// limit. If not, it also returns how long until the next one would be.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.allowAt(key, time.Now())
+}
+
+func (l *Limiter) allowAt(key string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration(math.Ceil((1 - b.tokens) / l.rate * float64(time.Second)))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	return min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+}
+
+// Expire forgets keys whose buckets have refilled by now, which behave
+// the same as keys never seen, and returns how many it removed.
+func (l *Limiter) Expire(now time.Time) int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	removed := 0
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// ExpireEvery runs Expire at the given interval until stop is closed.
+func (l *Limiter) ExpireEvery(interval time.Duration, stop <-chan struct{}) {
+	if l == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			l.Expire(now)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowRefills(t *testing.T) {
+	l := New(3, time.Minute)
+	start := time.Now()
+
+	for i := range 3 {
+		if ok, _ := l.allowAt("a", start); !ok {
+			t.Fatalf("event %d refused within the burst", i+1)
+		}
+	}
+	ok, wait := l.allowAt("a", start)
+	if ok {
+		t.Fatal("fourth event allowed")
+	}
+	if wait != 20*time.Second {
+		t.Fatalf("wait = %v, want 20s", wait)
+	}
+
+	if ok, _ := l.allowAt("b", start); !ok {
+		t.Fatal("another key shares the first key's bucket")
+	}
+	if ok, _ := l.allowAt("a", start.Add(20*time.Second)); !ok {
+		t.Fatal("refused after waiting as told")
+	}
+	if ok, _ := l.allowAt("a", start.Add(20*time.Second)); ok {
+		t.Fatal("allowed more than had refilled")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	l := New(2, time.Minute)
+	start := time.Now()
+	l.allowAt("a", start)
+	l.allowAt("b", start.Add(30*time.Second))
+
+	if n := l.Expire(start.Add(31 * time.Second)); n != 1 {
+		t.Fatalf("expired %d keys, want 1", n)
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Fatal("expired a key that hadn't refilled")
+	}
+}
+
+func TestNilAllowsEverything(t *testing.T) {
+	l := New(0, time.Minute)
+	if l != nil {
+		t.Fatal("New(0) returned a limiter")
+	}
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("nil limiter refused an event")
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+const defaultSwingLimit = 10
+
+type roundWinProb struct {
+	Number  int                   `json:"number"`
+	WinProb []models.WinProbPoint `json:"winProb"`
+}
+
+type roundKills struct {
+	Number int                `json:"number"`
+	Kills  []models.KillEvent `json:"kills"`
+}
+
+type tradesResponse struct {
+	Window  float64                     `json:"window"`
+	Players []analysis.PlayerTradeStats `json:"players"`
+	Rounds  []roundKills                `json:"rounds"`
+}
+
+type clutchesResponse struct {
+	Clutches   []analysis.RoundClutch       `json:"clutches"`
+	Players    []analysis.PlayerClutchStats `json:"players"`
+	Advantages []analysis.Advantage         `json:"advantages"`
+	Conversion []analysis.AdvantageSummary  `json:"conversion"`
+}
+
+type openingsResponse struct {
+	Duels   []analysis.OpeningDuel    `json:"duels"`
+	Players []analysis.PlayerOpenings `json:"players"`
+	Sites   []analysis.SiteOpenings   `json:"sites"`
+}
+
+type winProbResponse struct {
+	Rounds []roundWinProb   `json:"rounds"`
+	Swings []analysis.Swing `json:"swings"`
+}
+
+func (s *Server) handleWinProb(w http.ResponseWriter, r *http.Request) {
+	match, ok := s.loadMatch(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	limit, ok := queryLimit(w, r, defaultSwingLimit)
+	if !ok {
+		return
+	}
+
+	rounds := make([]roundWinProb, len(match.Rounds))
+	for i := range match.Rounds {
+		rd := &match.Rounds[i]
+		series := rd.WinProb
+		if len(series) != len(rd.Snapshots) {
+			series = s.winProb.RoundWinProb(rd)
+			rd.WinProb = series
+		}
+		rounds[i] = roundWinProb{Number: rd.Number, WinProb: series}
+	}
+
+	swings := s.winProb.Swings(match)
+	if len(swings) > limit {
+		swings = swings[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, winProbResponse{Rounds: rounds, Swings: swings})
+}
+
+func (s *Server) handleTrades(w http.ResponseWriter, r *http.Request) {
+	match, ok := s.loadMatch(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	window := analysis.DefaultTradeWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(f > 0 && f <= 60) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "window must be between 0 and 60 seconds"})
+			return
+		}
+		window = f
+	}
+
+	analysis.MarkTrades(match, window)
+
+	resp := tradesResponse{
+		Window:  window,
+		Players: analysis.TradeStats(match, window),
+		Rounds:  make([]roundKills, len(match.Rounds)),
+	}
+	for i, rd := range match.Rounds {
+		resp.Rounds[i] = roundKills{Number: rd.Number, Kills: rd.Kills}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleClutches(w http.ResponseWriter, r *http.Request) {
+	match, ok := s.loadMatch(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	clutches := analysis.Clutches(match)
+	advantages, conversion := analysis.Advantages(match)
+
+	writeJSON(w, http.StatusOK, clutchesResponse{
+		Clutches:   clutches,
+		Players:    analysis.ClutchStats(clutches),
+		Advantages: advantages,
+		Conversion: conversion,
+	})
+}
+
+func (s *Server) handleOpenings(w http.ResponseWriter, r *http.Request) {
+	match, ok := s.loadMatch(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	duels := analysis.OpeningDuels(match)
+	players, sites := analysis.OpeningStats(duels)
+
+	writeJSON(w, http.StatusOK, openingsResponse{Duels: duels, Players: players, Sites: sites})
+}
+
+func (s *Server) handleHighlights(w http.ResponseWriter, r *http.Request) {
+	match, ok := s.loadMatch(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	limit, ok := queryLimit(w, r, 0)
+	if !ok {
+		return
+	}
+
+	highlights := analysis.FilterHighlights(analysis.Highlights(match), r.URL.Query().Get("kind"), limit)
+
+	writeJSON(w, http.StatusOK, highlights)
+}
+
+// queryLimit reads the optional "limit" query parameter, writing an error
+// and returning false if it is malformed.
+func queryLimit(w http.ResponseWriter, r *http.Request, fallback int) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/allending313/cs2-demo-parser/internal/annotation"
+	"github.com/allending313/cs2-demo-parser/internal/auth"
+)
+
+// Largest annotation body accepted; enough for an area with every point
+// and the longest text.
+const maxAnnotationSize = 64 << 10
+
+// handleListAnnotations returns a match's annotations in playback order,
+// optionally only those in one "round", with a "tag" or by an "author".
+// With format=markdown (or Accept: text/markdown) they're rendered as a
+// review document instead.
+func (s *Server) handleListAnnotations(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.checkMatchExists(w, id) {
+		return
+	}
+
+	q := r.URL.Query()
+	round := 0
+	if v := q.Get("round"); v != "" {
+		var err error
+		if round, err = strconv.Atoi(v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid round"})
+			return
+		}
+	}
+
+	list, err := s.annotations.List(id)
+	if !s.checkAnnotationError(w, id, err) {
+		return
+	}
+	tag, author := strings.ToLower(q.Get("tag")), q.Get("author")
+	list = slices.DeleteFunc(list, func(a annotation.Annotation) bool {
+		return (round != 0 && a.Round != round) ||
+			(tag != "" && !slices.Contains(a.Tags, tag)) ||
+			(author != "" && a.Author != author)
+	})
+
+	if q.Get("format") == "markdown" || strings.Contains(r.Header.Get("Accept"), "text/markdown") {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-review.md"`, id))
+		w.Write([]byte(annotation.Markdown(s.reviewTitle(id), list)))
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleCreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !s.checkMatchExists(w, id) {
+		return
+	}
+	a, ok := decodeAnnotation(w, r)
+	if !ok {
+		return
+	}
+	// Named tokens are credited with their own annotations; the body's
+	// author is only used without one, e.g. with authentication off.
+	if name := auth.FromContext(r.Context()).Name; name != "" {
+		a.Author = name
+	}
+	a, err := s.annotations.Create(id, a)
+	if !s.checkAnnotationError(w, id, err) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, a)
+}
+
+func (s *Server) handleGetAnnotation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	a, err := s.annotations.Get(id, r.PathValue("annotationId"))
+	if !s.checkAnnotationError(w, id, err) {
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// handleUpdateAnnotation replaces an annotation with the one in the body.
+// The author stays as stored unless the body names one, which only
+// unnamed tokens or an open API may do.
+func (s *Server) handleUpdateAnnotation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	a, ok := decodeAnnotation(w, r)
+	if !ok {
+		return
+	}
+	if auth.FromContext(r.Context()).Name != "" {
+		a.Author = ""
+	}
+	a, err := s.annotations.Update(id, r.PathValue("annotationId"), a)
+	if !s.checkAnnotationError(w, id, err) {
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+func (s *Server) handleDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	err := s.annotations.Delete(id, r.PathValue("annotationId"))
+	if !s.checkAnnotationError(w, id, err) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeAnnotation(w http.ResponseWriter, r *http.Request) (annotation.Annotation, bool) {
+	var a annotation.Annotation
+	r.Body = http.MaxBytesReader(w, r.Body, maxAnnotationSize)
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid annotation JSON"})
+		return a, false
+	}
+	return a, true
+}
+
+func (s *Server) checkAnnotationError(w http.ResponseWriter, matchID string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, annotation.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, annotation.ErrInvalid):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		s.logger.Error("annotation store failed", "match", matchID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+	}
+	return false
+}
+
+// checkMatchExists writes a 404 unless id is a stored match or one being
+// parsed.
+func (s *Server) checkMatchExists(w http.ResponseWriter, id string) bool {
+	if _, ok := s.jobs.Get(id); ok {
+		return true
+	}
+	f, err := s.matches.Open(id)
+	if !s.checkLoadError(w, id, err) {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// reviewTitle names a match for the heading of its review document.
+func (s *Server) reviewTitle(id string) string {
+	title := "Review: match " + id
+	e, ok := s.matches.Entry(id)
+	if !ok {
+		return title
+	}
+	if e.Teams.CT.Name != "" && e.Teams.T.Name != "" {
+		title = fmt.Sprintf("Review: %s vs %s", e.Teams.CT.Name, e.Teams.T.Name)
+	}
+	if e.Map != "" {
+		title += " on " + e.Map
+	}
+	return title
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/allending313/cs2-demo-parser/internal/annotation"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestAnnotationAuthor(t *testing.T) {
+	send := func(s *Server, method, target, token, body string) annotation.Annotation {
+		t.Helper()
+		w := do(s, method, target, token, strings.NewReader(body))
+		if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+			t.Fatalf("%s %s: %d %s", method, target, w.Code, w.Body)
+		}
+		var a annotation.Annotation
+		json.NewDecoder(w.Body).Decode(&a)
+		return a
+	}
+
+	t.Run("named token", func(t *testing.T) {
+		s := newTestServer(t, true)
+		s.matches.Save(&models.Match{ID: "m1", Workspace: "a"})
+
+		// alice can't post as someone else, or take the author over later.
+		a := send(s, "POST", "/api/match/m1/annotations", tokenA,
+			`{"round":1,"kind":"note","text":"rotate","author":"coach"}`)
+		if a.Author != "alice" {
+			t.Errorf("created by %q, want alice", a.Author)
+		}
+		a = send(s, "PUT", "/api/match/m1/annotations/"+a.ID, tokenA,
+			`{"round":1,"kind":"note","text":"rotate earlier","author":"coach"}`)
+		if a.Author != "alice" || a.Text != "rotate earlier" {
+			t.Errorf("updated: %+v, want alice's", a)
+		}
+	})
+
+	t.Run("open API", func(t *testing.T) {
+		s := newTestServer(t, false)
+		s.matches.Save(&models.Match{ID: "m1"})
+
+		a := send(s, "POST", "/api/match/m1/annotations", "",
+			`{"round":1,"kind":"note","text":"rotate","author":"coach"}`)
+		if a.Author != "coach" {
+			t.Errorf("created by %q, want coach", a.Author)
+		}
+		// Leaving the author out of an update keeps it.
+		a = send(s, "PUT", "/api/match/m1/annotations/"+a.ID, "",
+			`{"round":1,"kind":"note","text":"rotate earlier"}`)
+		if a.Author != "coach" {
+			t.Errorf("updated by %q, want coach", a.Author)
+		}
+	})
+}
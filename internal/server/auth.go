@@ -0,0 +1,180 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/allending313/cs2-demo-parser/internal/auth"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+)
+
+// access is what a route requires of the caller while authentication is
+// enabled. Without it every route is open.
+type access int
+
+const (
+	// Anyone, e.g. the health check and radar images. Rate limited by
+	// address.
+	public access = iota
+	// Any valid token, share tokens included. The handler checks access to
+	// the match concerned.
+	authenticated
+	// A workspace token. Handlers only return the caller's workspace's
+	// matches.
+	member
+	// Reading the match {id}: its workspace, or a share of it.
+	readMatch
+	// Changing the match {id}: its workspace only.
+	writeMatch
+)
+
+// handle registers a route behind an access check, recording metrics for
+// it.
+func (s *Server) handle(pattern string, level access, h http.HandlerFunc) {
+	s.mux.Handle(pattern, s.instrument(pattern, s.authorize(level, h)))
+}
+
+func (s *Server) authorize(level access, next http.HandlerFunc) http.HandlerFunc {
+	if level == public {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if s.allowAnonymous(w, r) {
+				next(w, r)
+			}
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.auth.Enabled() {
+			if s.allowRequest(w, r) {
+				next(w, r)
+			}
+			return
+		}
+
+		id, ok := s.auth.Authenticate(auth.RequestToken(r))
+		if !ok {
+			// Failed attempts count against the address, so tokens can't
+			// be guessed at full speed.
+			if !s.allowAnonymous(w, r) {
+				return
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid token"})
+			return
+		}
+		if !s.allowRequest(w, r) {
+			return
+		}
+
+		switch level {
+		case member:
+			if id.Share != nil {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "a share link can only view its match"})
+				return
+			}
+		case readMatch, writeMatch:
+			matchID := r.PathValue("id")
+			owner, exists := s.matchOwner(matchID)
+			// Other workspaces' matches are reported as missing rather than
+			// forbidden, so their IDs can't be probed.
+			if !exists || !id.CanRead(matchID, owner) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "match not found"})
+				return
+			}
+			if level == writeMatch && !id.CanWrite(owner) {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "a share link is read-only"})
+				return
+			}
+		}
+
+		next(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+	}
+}
+
+// matchOwner returns the workspace of a stored match or one being parsed.
+func (s *Server) matchOwner(id string) (string, bool) {
+	if job, ok := s.jobs.Get(id); ok {
+		return s.auth.Owner(job.Workspace), true
+	}
+	if e, ok := s.matches.Entry(id); ok {
+		return s.auth.Owner(e.Workspace), true
+	}
+	return "", false
+}
+
+// canRead reports whether the caller may read a match, for routes whose
+// path doesn't name it.
+func (s *Server) canRead(r *http.Request, matchID string) bool {
+	if !s.auth.Enabled() {
+		return true
+	}
+	owner, ok := s.matchOwner(matchID)
+	return ok && auth.FromContext(r.Context()).CanRead(matchID, owner)
+}
+
+// visible returns a filter for the index entries the caller may see.
+func (s *Server) visible(r *http.Request) func(*store.IndexEntry) bool {
+	if !s.auth.Enabled() {
+		return func(*store.IndexEntry) bool { return true }
+	}
+	workspace := auth.FromContext(r.Context()).Workspace
+	return func(e *store.IndexEntry) bool { return s.auth.Owner(e.Workspace) == workspace }
+}
+
+// workspace returns the workspace that matches uploaded by r belong to.
+func workspace(r *http.Request) string {
+	return auth.FromContext(r.Context()).Workspace
+}
+
+// handleCreateShare creates a read-only link to a match, valid for the
+// optional form field "expiresIn" (e.g. "72h").
+func (s *Server) handleCreateShare(w http.ResponseWriter, r *http.Request) {
+	if !s.auth.Enabled() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "share links need authentication to be enabled"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFieldSize)
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+		return
+	}
+	var expiresAt time.Time
+	if v := r.PostForm.Get("expiresIn"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid expiresIn"})
+			return
+		}
+		expiresAt = time.Now().Add(d).UTC()
+	}
+
+	id := auth.FromContext(r.Context())
+	matchID := r.PathValue("id")
+	sh, err := s.shares.Create(matchID, id.Workspace, id.Name, expiresAt)
+	if err != nil {
+		s.logger.Error("failed to create share", "match", matchID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	s.logger.Info("match shared", "match", matchID, "by", id.Name)
+	writeJSON(w, http.StatusCreated, sh)
+}
+
+func (s *Server) handleListShares(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.shares.List(r.PathValue("id")))
+}
+
+func (s *Server) handleRevokeShare(w http.ResponseWriter, r *http.Request) {
+	err := s.shares.Revoke(r.PathValue("id"), r.PathValue("token"))
+	if errors.Is(err, auth.ErrShareNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.logger.Error("failed to revoke share", "match", r.PathValue("id"), "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/allending313/cs2-demo-parser/internal/auth"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+	"github.com/allending313/cs2-demo-parser/internal/ratelimit"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+)
+
+// newAuthTestServer returns a server with matches "ma" in workspace a, "mb"
+// in workspace b and "legacy" from before workspaces, which belongs to the
+// default workspace a, and a share token for "ma".
+func newAuthTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	s := newTestServer(t, true)
+	for _, m := range []*models.Match{{ID: "ma", Workspace: "a"}, {ID: "mb", Workspace: "b"}, {ID: "legacy"}} {
+		if err := s.matches.Save(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sh, err := s.shares.Create("ma", "a", "alice", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, sh.Token
+}
+
+func TestAuthorize(t *testing.T) {
+	s, share := newAuthTestServer(t)
+
+	tests := []struct {
+		name         string
+		method, path string
+		token        string
+		want         int
+	}{
+		{"public route without token", "GET", "/api/health", "", http.StatusOK},
+		{"missing token", "GET", "/api/match/ma/annotations", "", http.StatusUnauthorized},
+		{"invalid token", "GET", "/api/match/ma/annotations", "not-a-real-token-000000", http.StatusUnauthorized},
+		{"own match", "GET", "/api/match/ma/annotations", tokenA, http.StatusOK},
+		{"other workspace's match", "GET", "/api/match/ma/annotations", tokenB, http.StatusNotFound},
+		{"other workspace's match, writing", "GET", "/api/match/mb/shares", tokenA, http.StatusNotFound},
+		{"unknown match", "GET", "/api/match/nope/annotations", tokenA, http.StatusNotFound},
+		{"legacy match, default workspace", "GET", "/api/match/legacy/annotations", tokenA, http.StatusOK},
+		{"legacy match, other workspace", "GET", "/api/match/legacy/annotations", tokenB, http.StatusNotFound},
+		{"share on its match", "GET", "/api/match/ma/annotations", share, http.StatusOK},
+		{"share on another match", "GET", "/api/match/legacy/annotations", share, http.StatusNotFound},
+		{"share on another workspace's match", "GET", "/api/match/mb/annotations", share, http.StatusNotFound},
+		{"share on a writeMatch route", "GET", "/api/match/ma/shares", share, http.StatusForbidden},
+		{"share on a member route", "GET", "/api/live", share, http.StatusForbidden},
+		{"member route", "GET", "/api/live", tokenB, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(s, tt.method, tt.path, tt.token, nil)
+			if w.Code != tt.want {
+				t.Errorf("%s %s: got %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("401 without a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestMatchOwner(t *testing.T) {
+	s, _ := newAuthTestServer(t)
+	s.jobs.Create("parsing", "b", "")
+	s.jobs.Create("parsing-legacy", "", "")
+
+	tests := []struct {
+		id     string
+		want   string
+		exists bool
+	}{
+		{"ma", "a", true},
+		{"mb", "b", true},
+		{"legacy", "a", true},
+		{"parsing", "b", true},
+		{"parsing-legacy", "a", true},
+		{"nope", "", false},
+	}
+	for _, tt := range tests {
+		if owner, ok := s.matchOwner(tt.id); owner != tt.want || ok != tt.exists {
+			t.Errorf("matchOwner(%q) = %q, %v; want %q, %v", tt.id, owner, ok, tt.want, tt.exists)
+		}
+	}
+}
+
+func TestVisible(t *testing.T) {
+	s, _ := newAuthTestServer(t)
+
+	tests := []struct {
+		name      string
+		identity  auth.Identity
+		workspace string
+		want      bool
+	}{
+		{"own workspace", auth.Identity{Workspace: "a", Name: "alice"}, "a", true},
+		{"other workspace", auth.Identity{Workspace: "b", Name: "bob"}, "a", false},
+		{"legacy match in the default workspace", auth.Identity{Workspace: "a", Name: "alice"}, "", true},
+		{"legacy match elsewhere", auth.Identity{Workspace: "b", Name: "bob"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r = r.WithContext(auth.WithIdentity(r.Context(), tt.identity))
+			if got := s.visible(r)(&store.IndexEntry{Workspace: tt.workspace}); got != tt.want {
+				t.Errorf("visible = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Without authentication everything is visible.
+	open := newTestServer(t, false)
+	if !open.visible(httptest.NewRequest("GET", "/", nil))(&store.IndexEntry{Workspace: "b"}) {
+		t.Error("entry hidden with authentication off")
+	}
+}
+
+func TestAnonymousRequestsRateLimited(t *testing.T) {
+	s := newTestServer(t, true)
+	s.requestLimiter = ratelimit.New(2, time.Minute)
+
+	// Public routes and failed logins from one address share its budget,
+	// while a valid token has its own.
+	for i, tt := range []struct {
+		path, token string
+		want        int
+	}{
+		{"/api/health", "", http.StatusOK},
+		{"/api/live", "not-a-real-token-000000", http.StatusUnauthorized},
+		{"/api/health", "", http.StatusTooManyRequests},
+		{"/api/live", "", http.StatusTooManyRequests},
+		{"/some/page", "", http.StatusTooManyRequests},
+		{"/api/live", tokenA, http.StatusOK},
+	} {
+		if w := do(s, "GET", tt.path, tt.token, nil); w.Code != tt.want {
+			t.Errorf("request %d to %s: got %d, want %d", i, tt.path, w.Code, tt.want)
+		}
+	}
+}
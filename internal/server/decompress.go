@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Upload formats, told apart by their leading bytes rather than the file
+// name, since platforms don't agree on extensions.
+const (
+	formatUnknown = iota
+	formatDemo
+	formatGzip
+	formatBzip2
+	formatZstd
+	formatZip
+)
+
+var uploadMagic = []struct {
+	format int
+	magic  []byte
+}{
+	{formatDemo, []byte("PBDEMS2\x00")},
+	{formatDemo, []byte("HL2DEMO\x00")},
+	{formatGzip, []byte{0x1f, 0x8b}},
+	{formatBzip2, []byte("BZh")},
+	{formatZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{formatZip, []byte("PK\x03\x04")},
+}
+
+// Extensions used for uploads kept on disk, by format.
+var uploadExtensions = map[int]string{
+	formatDemo:  ".dem",
+	formatGzip:  ".dem.gz",
+	formatBzip2: ".dem.bz2",
+	formatZstd:  ".dem.zst",
+	formatZip:   ".zip",
+}
+
+// detectFormat peeks at the start of br to identify the upload format.
+func detectFormat(br *bufio.Reader) int {
+	head, _ := br.Peek(8)
+	for _, m := range uploadMagic {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.format
+		}
+	}
+	return formatUnknown
+}
+
+// decompressDemo returns a reader of the demo in r, undoing any gzip, bzip2
+// or zstd compression. Uncompressed data is passed through. The returned
+// close function releases the decompressor.
+func decompressDemo(r io.Reader) (io.Reader, func(), error) {
+	br := bufio.NewReader(r)
+	switch detectFormat(br) {
+	case formatGzip:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, func() { gz.Close() }, nil
+	case formatBzip2:
+		return bzip2.NewReader(br), func() {}, nil
+	case formatZstd:
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	default:
+		return br, func() {}, nil
+	}
+}
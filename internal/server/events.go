@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// Interval between comments sent to keep idle event streams open through
+// proxies.
+const eventKeepAlive = 15 * time.Second
+
+// handleJobEvents streams a parse job's state as Server-Sent Events. Each
+// event carries the whole job as JSON and is named after what changed:
+//
+//	progress  the parse advanced by at least a percent
+//	rounds    another round finished parsing (see roundsReady)
+//	status    the job finished; "ready" or "error"
+//	error     the job failed, with the error message in "error"
+//
+// The first event is "status" with the job as it is when the stream opens.
+// The stream ends once the job finishes.
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	updates, unsubscribe, ok := s.jobs.Subscribe(id)
+	if !ok {
+		// Matches parsed before a restart have no job, but are ready.
+		f, err := s.matches.Open(id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "match not found"})
+			return
+		}
+		f.Close()
+		done := make(chan models.ParseJob, 1)
+		done <- models.ParseJob{ID: id, Status: models.JobStatusReady, Progress: 1}
+		close(done)
+		updates, unsubscribe = done, func() {}
+	}
+	defer unsubscribe()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	var last *models.ParseJob
+	for {
+		select {
+		case job, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := writeJobEvents(w, last, job); err != nil {
+				return
+			}
+			last = &job
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeJobEvents writes an event for each way job differs from the last
+// state sent.
+func writeJobEvents(w http.ResponseWriter, last *models.ParseJob, job models.ParseJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	switch {
+	case last == nil:
+		names = append(names, "status")
+	default:
+		if job.Progress != last.Progress {
+			names = append(names, "progress")
+		}
+		if job.RoundsReady != last.RoundsReady {
+			names = append(names, "rounds")
+		}
+		if job.Status != last.Status {
+			names = append(names, "status")
+		}
+	}
+	if job.Status == models.JobStatusError {
+		names = append(names, "error")
+	}
+
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	"github.com/allending313/cs2-demo-parser/internal/export"
+)
+
+// handleVDM returns a CS2 playback script for either a list of rounds
+// (?rounds=3-5,9, optionally with &player=<steamId> to spectate) or for
+// highlights (?highlights=all or a list of 1-based ranks, narrowed by the
+// same kind and limit parameters as the highlights endpoint).
+func (s *Server) handleVDM(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	match, ok := s.loadMatch(w, id)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	var segments []export.Segment
+
+	switch {
+	case q.Get("rounds") != "":
+		rounds, err := export.ParseRanges(q.Get("rounds"), export.LastRound(match))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid rounds: " + err.Error()})
+			return
+		}
+		var player uint64
+		if v := q.Get("player"); v != "" {
+			if player, err = strconv.ParseUint(v, 10, 64); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid player"})
+				return
+			}
+		}
+		segments = export.RoundSegments(match, rounds, player)
+
+	case q.Get("highlights") != "":
+		limit, ok := queryLimit(w, r, 0)
+		if !ok {
+			return
+		}
+		highlights := analysis.FilterHighlights(analysis.Highlights(match), q.Get("kind"), limit)
+		if v := q.Get("highlights"); v != "all" {
+			ranks, err := export.ParseRanges(v, len(highlights))
+			if err == nil {
+				highlights, err = export.SelectRanks(highlights, ranks)
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid highlights: " + err.Error()})
+				return
+			}
+		}
+		segments = export.HighlightSegments(highlights)
+
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "rounds or highlights is required"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".vdm"))
+	if err := export.WriteVDM(w, match, segments); err != nil {
+		s.logger.Error("failed to write vdm", "id", id, "error", err)
+	}
+}
+
+// handleExportTables lists the exportable tables and their columns.
+func (s *Server) handleExportTables(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, export.Tables)
+}
+
+// handleExportTable streams one table of the match as CSV (the default) or
+// NDJSON, chosen with ?format=.
+func (s *Server) handleExportTable(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	table, ok := export.LookupTable(r.PathValue("table"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown table"})
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := export.Formats[format]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid format"})
+		return
+	}
+
+	match, ok := s.loadMatch(w, id)
+	if !ok {
+		return
+	}
+
+	rw, err := export.NewRowWriter(format, w)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+"-"+table.Name+"."+format))
+	if err := table.Write(rw, match); err != nil {
+		s.logger.Error("failed to export table", "id", id, "table", table.Name, "error", err)
+	}
+}
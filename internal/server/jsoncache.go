@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"sync"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// jsonCacheSize bounds the gzipped JSON kept by matchJSONCache.
+const jsonCacheSize = 64 << 20
+
+// matchJSONCache keeps the gzipped JSON encoding of recently requested
+// matches, so that clients asking for JSON instead of the binary format
+// don't cost a full decode and re-encode each time. Entries are keyed by
+// the match file's ETag, so a reparsed match is simply a miss; the oldest
+// entries go first once the cache is full.
+type matchJSONCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string
+	size    int
+}
+
+func newMatchJSONCache() *matchJSONCache {
+	return &matchJSONCache{entries: make(map[string][]byte)}
+}
+
+// get returns the gzipped JSON for key, encoding it with load on a miss.
+func (c *matchJSONCache) get(key string, load func() (*models.Match, error)) ([]byte, error) {
+	c.mu.Lock()
+	data, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	match, err := load()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(match); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	data = buf.Bytes()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok || len(data) > jsonCacheSize {
+		return data, nil
+	}
+	for c.size+len(data) > jsonCacheSize {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= len(c.entries[oldest])
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = data
+	c.order = append(c.order, key)
+	c.size += len(data)
+	return data, nil
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allending313/cs2-demo-parser/internal/auth"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+)
+
+// Limits bound what a single client, i.e. an API token or, without
+// authentication, an IP address, can ask of the server, so that one
+// runaway script can't starve everyone else or fill the disk. Zero values
+// mean unlimited.
+type Limits struct {
+	// API requests per minute, and uploads per hour: demos, archives,
+	// resumable uploads and live broadcasts.
+	RequestsPerMinute int
+	UploadsPerHour    int
+
+	// Parse jobs a client may have running at once. Further uploads are
+	// refused until one finishes.
+	JobsPerClient int
+
+	// Bytes each workspace may store: its matches plus the declared size
+	// of its unfinished resumable uploads.
+	WorkspaceQuota int64
+
+	// Take the client's address from X-Forwarded-For, for a server behind
+	// a reverse proxy. Without a proxy clients could set it themselves.
+	TrustProxy bool
+}
+
+// client identifies who made a request, for the per-client limits.
+func (s *Server) client(r *http.Request) string {
+	if s.auth.Enabled() {
+		// Only authenticated requests get here, so the token is valid.
+		sum := sha256.Sum256([]byte(auth.RequestToken(r)))
+		return "token:" + hex.EncodeToString(sum[:8])
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+func (s *Server) clientIP(r *http.Request) string {
+	if s.limits.TrustProxy {
+		// The proxy appends the address it saw, so the last one is the
+		// only one the client can't forge.
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(fwd[strings.LastIndex(fwd, ",")+1:])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// allowRequest applies the request rate limit, responding if r is over it.
+func (s *Server) allowRequest(w http.ResponseWriter, r *http.Request) bool {
+	return s.allowRequestFrom(w, s.client(r))
+}
+
+// allowAnonymous applies the request rate limit by IP address, for
+// requests without a valid token: public routes, and failed attempts at
+// authenticating.
+func (s *Server) allowAnonymous(w http.ResponseWriter, r *http.Request) bool {
+	return s.allowRequestFrom(w, "ip:"+s.clientIP(r))
+}
+
+func (s *Server) allowRequestFrom(w http.ResponseWriter, client string) bool {
+	ok, wait := s.requestLimiter.Allow(client)
+	if !ok {
+		tooManyRequests(w, wait, "too many requests, slow down")
+	}
+	return ok
+}
+
+// admitUpload checks a new upload against the client's and its
+// workspace's limits, responding if it's refused. size is how much the
+// upload will keep on disk until it's parsed, or 0.
+func (s *Server) admitUpload(w http.ResponseWriter, r *http.Request, size int64) bool {
+	if !s.admitJob(w, r) {
+		return false
+	}
+
+	if quota := s.limits.WorkspaceQuota; quota > 0 {
+		if used := s.usage(workspace(r)); used >= quota || used+size > quota {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+				"error": fmt.Sprintf("workspace storage quota exceeded: %s of %s used", formatBytes(used), formatBytes(quota)),
+			})
+			return false
+		}
+	}
+
+	ok, wait := s.uploadLimiter.Allow(s.client(r))
+	if !ok {
+		tooManyRequests(w, wait, "too many uploads, try again later")
+	}
+	return ok
+}
+
+// admitJob checks that the client may start another parse job.
+func (s *Server) admitJob(w http.ResponseWriter, r *http.Request) bool {
+	limit := s.limits.JobsPerClient
+	if limit > 0 && s.jobs.Running(s.client(r)) >= limit {
+		// There's no telling when a parse will finish; a few seconds is
+		// a reasonable time to check again.
+		tooManyRequests(w, 5*time.Second, fmt.Sprintf("%d demos already parsing, wait for one to finish", limit))
+		return false
+	}
+	return true
+}
+
+// createJobs creates the parse jobs for pending, all started by client
+// for workspace, and registers them with startJobs. Unlike admitJob, which
+// refuses early before an upload is read, this counts every job and checks
+// the limit as they're created. It responds and returns false if the jobs
+// can't start.
+func (s *Server) createJobs(w http.ResponseWriter, workspace, client string, pending ...*pendingJob) ([]models.ParseJob, bool) {
+	ids := make([]string, len(pending))
+	for i, p := range pending {
+		ids[i] = p.ID
+	}
+	limit := s.limits.JobsPerClient
+	jobs, ok := s.jobs.CreateLimited(limit, workspace, client, ids...)
+	if !ok {
+		msg := fmt.Sprintf("%d demos already parsing, wait for one to finish", limit)
+		if len(ids) > limit {
+			msg = fmt.Sprintf("%d demos at once is more than the %d a client may parse", len(ids), limit)
+		}
+		tooManyRequests(w, 5*time.Second, msg)
+		return nil, false
+	}
+	if !s.startJobs(pending...) {
+		s.jobs.Remove(ids...)
+		shuttingDown(w)
+		return nil, false
+	}
+	return jobs, true
+}
+
+// usage returns the bytes stored for workspace.
+func (s *Server) usage(workspace string) int64 {
+	owner := s.auth.Owner(workspace)
+	entries := s.matches.EntriesWhere(func(e *store.IndexEntry) bool {
+		return s.auth.Owner(e.Workspace) == owner
+	})
+
+	total := s.uploads.Reserved(workspace)
+	for _, e := range entries {
+		total += e.Size
+	}
+	return total
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": msg})
+}
+
+func formatBytes(n int64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
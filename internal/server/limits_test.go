@@ -0,0 +1,41 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestArchiveCountsEveryDemoAgainstJobLimit(t *testing.T) {
+	s := newTestServer(t, false)
+	s.limits.JobsPerClient = 2
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for i := range 3 {
+		f, _ := zw.Create(fmt.Sprintf("map%d.dem", i))
+		f.Write([]byte("PBDEMS2\x00"))
+	}
+	zw.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("demo", "series.zip")
+	part.Write(archive.Bytes())
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/api/parse", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("three demos under a limit of two: got %d %s, want 429", w.Code, w.Body)
+	}
+	if n := s.jobs.Running(s.client(r)); n != 0 {
+		t.Errorf("%d jobs left running after refusing the archive", n)
+	}
+}
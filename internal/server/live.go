@@ -0,0 +1,381 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/allending313/cs2-demo-parser/internal/broadcast"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+	"github.com/allending313/cs2-demo-parser/internal/parser"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+	"github.com/allending313/cs2-demo-parser/internal/websocket"
+)
+
+// Messages queued for a live viewer before it's dropped as too slow.
+const liveViewerBuffer = 512
+
+// How long connecting to a broadcast relay may take.
+const liveConnectTimeout = 30 * time.Second
+
+// Kill and grenade messages kept to catch up viewers who join mid-round;
+// past this the oldest are dropped, for feeds that never finish a round.
+const liveBacklogSize = 1024
+
+// liveMessage is sent to live viewers as JSON. Types are:
+//
+//	match     the match so far, with every completed round; sent first
+//	snapshot  a snapshot of the round in progress
+//	kill      a kill in the round in progress
+//	grenade   a grenade in the round in progress, once it detonated
+//	round     a round completed, annotated like stored rounds
+//	end       the broadcast is over; data is the parse job, and once it's
+//	          "ready" the match can be loaded from /api/match/{id}
+type liveMessage struct {
+	Type  string `json:"type"`
+	Round int    `json:"round,omitempty"`
+	Data  any    `json:"data"`
+}
+
+// liveSession is a broadcast being parsed as it's played. It is also a
+// parse job under the same ID, so the match is stored when it ends.
+type liveSession struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Map       string    `json:"map"`
+	StartedAt time.Time `json:"startedAt"`
+
+	workspace string
+	client    string
+	cancel    context.CancelFunc
+
+	mu sync.Mutex
+	// The match with its completed rounds, then the kills and grenades
+	// sent since the last one and the latest snapshot, which together
+	// catch up viewers who join late.
+	match    *models.Match
+	backlog  [][]byte
+	snapshot []byte
+	viewers  map[chan []byte]struct{}
+	ended    bool
+}
+
+// publish sends msg to every viewer, dropping those that fell too far
+// behind.
+func (l *liveSession) publish(msg liveMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case msg.Type == "snapshot":
+		l.snapshot = data
+	case len(l.backlog) >= liveBacklogSize:
+		l.backlog = append(l.backlog[1:], data)
+	default:
+		l.backlog = append(l.backlog, data)
+	}
+	l.sendLocked(data)
+}
+
+// completeRound records match, whose last round just completed, and sends
+// that round to viewers.
+func (l *liveSession) completeRound(match *models.Match) {
+	round := match.Rounds[len(match.Rounds)-1]
+	data, err := json.Marshal(liveMessage{Type: "round", Round: round.Number, Data: round})
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.match = match
+	l.backlog = nil
+	l.snapshot = nil
+	l.sendLocked(data)
+}
+
+func (l *liveSession) sendLocked(data []byte) {
+	for ch := range l.viewers {
+		select {
+		case ch <- data:
+		default:
+			delete(l.viewers, ch)
+			close(ch)
+		}
+	}
+}
+
+// end sends the final message and disconnects every viewer.
+func (l *liveSession) end(job models.ParseJob) {
+	data, _ := json.Marshal(liveMessage{Type: "end", Data: job})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.viewers {
+		select {
+		case ch <- data:
+		default:
+		}
+		close(ch)
+	}
+	l.viewers = nil
+	l.ended = true
+}
+
+// join returns the messages for a new viewer, starting with the match so
+// far. The channel is closed when the session ends, when the viewer falls
+// behind, or when leave is called.
+func (l *liveSession) join() (messages <-chan []byte, leave func(), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ended {
+		return nil, nil, false
+	}
+
+	first, err := json.Marshal(liveMessage{Type: "match", Data: l.match})
+	if err != nil {
+		return nil, nil, false
+	}
+
+	ch := make(chan []byte, liveViewerBuffer+len(l.backlog)+2)
+	ch <- first
+	for _, data := range l.backlog {
+		ch <- data
+	}
+	if l.snapshot != nil {
+		ch <- l.snapshot
+	}
+	l.viewers[ch] = struct{}{}
+
+	leave = func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.viewers[ch]; ok {
+			delete(l.viewers, ch)
+			close(ch)
+		}
+	}
+	return ch, leave, true
+}
+
+// liveSessions are the broadcasts being ingested, by ID.
+type liveSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*liveSession
+}
+
+func (ls *liveSessions) add(l *liveSession) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.sessions == nil {
+		ls.sessions = make(map[string]*liveSession)
+	}
+	ls.sessions[l.ID] = l
+}
+
+func (ls *liveSessions) get(id string) (*liveSession, bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, ok := ls.sessions[id]
+	return l, ok
+}
+
+func (ls *liveSessions) remove(id string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	delete(ls.sessions, id)
+}
+
+func (ls *liveSessions) list() []*liveSession {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	list := make([]*liveSession, 0, len(ls.sessions))
+	for _, l := range ls.sessions {
+		list = append(list, l)
+	}
+	slices.SortFunc(list, func(a, b *liveSession) int { return a.StartedAt.Compare(b.StartedAt) })
+	return list
+}
+
+// handleStartLive starts ingesting the broadcast at the form field "url",
+// e.g. http://localhost:8080/s123t456. The other fields are the parse
+// options /api/parse takes.
+func (s *Server) handleStartLive(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFieldSize*8)
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+		return
+	}
+
+	u, err := url.Parse(r.PostForm.Get("url"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url must be an http(s) broadcast URL"})
+		return
+	}
+	if !s.relays.allowsHost(u) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "relay host not allowed"})
+		return
+	}
+	opts, err := parseOptionsFromForm(r.PostForm)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if !s.admitUpload(w, r, 0) {
+		return
+	}
+
+	// Connecting is bounded by the request; only once it succeeds does the
+	// reader move to a context that lasts as long as the session.
+	openCtx, cancelOpen := context.WithTimeout(r.Context(), liveConnectTimeout)
+	reader, err := broadcast.Open(openCtx, u.String(), broadcast.Options{Client: s.relayClient})
+	cancelOpen()
+	if errors.Is(err, errRelayAddress) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "relay address not allowed"})
+		return
+	}
+	if err != nil {
+		s.logger.Warn("failed to connect to broadcast", "url", u.String(), "error", err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "connecting to broadcast: " + err.Error()})
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	reader.SetContext(ctx)
+
+	l := &liveSession{
+		ID:        util.GenerateID(),
+		URL:       u.String(),
+		Map:       reader.Sync().Map,
+		StartedAt: time.Now(),
+		workspace: workspace(r),
+		client:    s.client(r),
+		cancel:    cancel,
+		viewers:   make(map[chan []byte]struct{}),
+	}
+	l.match = &models.Match{
+		ID:        l.ID,
+		Map:       l.Map,
+		Rounds:    []models.Round{},
+		MapConfig: s.mapConfigs[l.Map],
+	}
+	if _, ok := s.createJobs(w, l.workspace, l.client, &pendingJob{ID: l.ID, Workspace: l.workspace, Options: opts}); !ok {
+		cancel()
+		return
+	}
+	s.live.add(l)
+	go s.runLive(ctx, l, reader, opts)
+
+	s.logger.Info("live ingest started", "id", l.ID, "url", l.URL, "map", l.Map)
+	writeJSON(w, http.StatusAccepted, l)
+}
+
+// runLive parses a broadcast until it ends or is stopped, then stores the
+// match like any other parse.
+func (s *Server) runLive(ctx context.Context, l *liveSession, r *broadcast.Reader, opts models.ParseOptions) {
+	defer l.cancel()
+	defer s.live.remove(l.ID)
+
+	s.startParse(l.ID)
+	publishRound := s.publishRounds(l.ID)
+	match, err := parser.ParseBroadcast(ctx, r, l.ID, opts, parser.Hooks{
+		Round: func(match *models.Match) {
+			publishRound(match)
+			if partial, ok := s.jobs.Partial(l.ID); ok {
+				l.completeRound(partial)
+			}
+		},
+		Snapshot: func(round int, snapshot models.Snapshot) {
+			l.publish(liveMessage{Type: "snapshot", Round: round, Data: snapshot})
+		},
+		Kill: func(round int, kill models.KillEvent) {
+			l.publish(liveMessage{Type: "kill", Round: round, Data: kill})
+		},
+		Grenade: func(round int, grenade models.GrenadeEvent) {
+			l.publish(liveMessage{Type: "grenade", Round: round, Data: grenade})
+		},
+	})
+	if match != nil && match.Map == "" {
+		match.Map = l.Map
+	}
+	s.finishJob(l.ID, match, err)
+
+	job, _ := s.jobs.Get(l.ID)
+	l.end(job)
+	s.logger.Info("live ingest ended", "id", l.ID, "status", job.Status)
+}
+
+func (s *Server) handleListLive(w http.ResponseWriter, r *http.Request) {
+	list := s.live.list()
+	if s.auth.Enabled() {
+		list = slices.DeleteFunc(list, func(l *liveSession) bool { return l.workspace != workspace(r) })
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleStopLive stops ingesting a broadcast. What was parsed so far is
+// stored as the match.
+func (s *Server) handleStopLive(w http.ResponseWriter, r *http.Request) {
+	l, ok := s.live.get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "live session not found"})
+		return
+	}
+	l.cancel()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLiveSocket streams a live session's messages to a viewer over
+// WebSocket. Viewers don't send anything; reading only notices when they
+// leave.
+func (s *Server) handleLiveSocket(w http.ResponseWriter, r *http.Request) {
+	l, ok := s.live.get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "live session not found"})
+		return
+	}
+	messages, leave, ok := l.join()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "live session has ended"})
+		return
+	}
+	defer leave()
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case data, ok := <-messages:
+			if !ok {
+				return
+			}
+			if err := conn.WriteText(data); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestLiveBacklogBounded(t *testing.T) {
+	l := &liveSession{match: &models.Match{}, viewers: make(map[chan []byte]struct{})}
+	for i := 0; i < liveBacklogSize+5; i++ {
+		l.publish(liveMessage{Type: "kill", Data: i})
+		l.publish(liveMessage{Type: "snapshot", Data: i})
+	}
+
+	messages, leave, ok := l.join()
+	if !ok {
+		t.Fatal("can't join")
+	}
+	defer leave()
+
+	// The match, the newest kills and then only the latest snapshot.
+	var got []liveMessage
+	for len(messages) > 0 {
+		var msg liveMessage
+		json.Unmarshal(<-messages, &msg)
+		got = append(got, msg)
+	}
+	if len(got) != liveBacklogSize+2 {
+		t.Fatalf("caught up with %d messages, want %d", len(got), liveBacklogSize+2)
+	}
+	if got[0].Type != "match" {
+		t.Errorf("first message %q, want match", got[0].Type)
+	}
+	if first := got[1]; first.Type != "kill" || first.Data != float64(5) {
+		t.Errorf("oldest kill kept = %+v, want kill 5", first)
+	}
+	if last := got[len(got)-1]; last.Type != "snapshot" || last.Data != float64(liveBacklogSize+4) {
+		t.Errorf("last message = %+v, want the latest snapshot", last)
+	}
+
+	l.completeRound(&models.Match{Rounds: []models.Round{{Number: 1}}})
+	if len(l.backlog) != 0 || l.snapshot != nil {
+		t.Error("backlog kept after the round completed")
+	}
+}
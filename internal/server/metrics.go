@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allending313/cs2-demo-parser/internal/auth"
+	"github.com/allending313/cs2-demo-parser/internal/metrics"
+	"github.com/allending313/cs2-demo-parser/internal/parser"
+)
+
+var (
+	errUploadInterrupted = errors.New("upload interrupted")
+	errDecompress        = errors.New("decompressing demo")
+	errStorage           = errors.New("writing match data")
+)
+
+// serverMetrics are the metrics served on GET /metrics.
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	requests        *metrics.Counter
+	requestDuration *metrics.Histogram
+	uploadBytes     *metrics.Counter
+	parseDuration   *metrics.Histogram
+	parseFailures   *metrics.Counter
+	parseTicks      *metrics.Counter
+	jobsQueued      *metrics.Gauge
+	jobsActive      *metrics.Gauge
+}
+
+func (s *Server) newMetrics() *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: r,
+		requests: r.Counter("cs2demo_http_requests_total",
+			"API requests handled, by route and status code.", "method", "route", "code"),
+		requestDuration: r.Histogram("cs2demo_http_request_duration_seconds",
+			"Time to handle API requests, by route. WebSocket and event stream requests last as long as the connection.",
+			[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}, "method", "route"),
+		uploadBytes: r.Counter("cs2demo_upload_bytes_total",
+			"Bytes of demo uploads received."),
+		parseDuration: r.Histogram("cs2demo_parse_duration_seconds",
+			"Time to parse a demo, successfully or not.",
+			[]float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300, 600}),
+		parseFailures: r.Counter("cs2demo_parse_failures_total",
+			"Parse jobs that failed, by error class.", "class"),
+		parseTicks: r.Counter("cs2demo_parse_ticks_total",
+			"Demo ticks in parsed matches. Its rate is the ticks parsed per second."),
+		jobsQueued: r.Gauge("cs2demo_jobs_queued",
+			"Parse jobs accepted but not parsing yet, such as demos waiting for an earlier one in the same archive."),
+		jobsActive: r.Gauge("cs2demo_jobs_active",
+			"Demos being parsed."),
+	}
+
+	r.GaugeFunc("cs2demo_store_matches", "Matches in the match store.", func() float64 {
+		return float64(len(s.matches.Entries()))
+	})
+	r.GaugeFunc("cs2demo_store_bytes", "Size of the match store's files.", func() float64 {
+		var total int64
+		for _, e := range s.matches.Entries() {
+			total += e.Size
+		}
+		return float64(total)
+	})
+	r.GaugeFunc("cs2demo_live_sessions", "Live broadcasts being ingested.", func() float64 {
+		return float64(len(s.live.list()))
+	})
+	return m
+}
+
+// handleMetrics reports the metrics to anyone with the metrics token, or
+// to anyone at all if none is set.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.metricsToken != "" && subtle.ConstantTimeCompare([]byte(auth.RequestToken(r)), []byte(s.metricsToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid token"})
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err := s.metrics.registry.Write(w); err != nil {
+		s.logger.Warn("failed to write metrics", "error", err)
+	}
+}
+
+// instrument counts and times the requests to a route.
+func (s *Server) instrument(pattern string, next http.Handler) http.Handler {
+	method, route, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, route = "", pattern
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		s.metrics.requests.Inc(method, route, strconv.Itoa(rec.code()))
+		s.metrics.requestDuration.Observe(time.Since(start).Seconds(), method, route)
+	})
+}
+
+// statusRecorder remembers the status code of a response. Hijack and
+// Unwrap keep WebSockets and event streams working through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) code() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// countUpload counts the bytes read from an upload's body.
+func (s *Server) countUpload(body io.ReadCloser) io.ReadCloser {
+	return &countingReader{ReadCloser: body, counter: s.metrics.uploadBytes}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	counter *metrics.Counter
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	if n > 0 {
+		c.counter.Add(float64(n))
+	}
+	return n, err
+}
+
+// failureClass sorts the error of a failed parse job into a metric label.
+func failureClass(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, errUploadInterrupted):
+		return "upload"
+	case errors.Is(err, errDecompress):
+		return "decompress"
+	case errors.Is(err, parser.ErrRead):
+		return "read"
+	case errors.Is(err, parser.ErrInvalidDemo):
+		return "invalid_demo"
+	case errors.Is(err, errStorage):
+		return "storage"
+	}
+	return "other"
+}
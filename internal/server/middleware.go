@@ -1,12 +1,37 @@
 package server
 
-import "net/http"
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
 
-func CORSMiddleware(next http.Handler) http.Handler {
+// CORSMiddleware lets the origins in allowedOrigins call the API from a
+// browser. With none given, any origin may.
+//
+// WebSocket upgrades aren't covered by CORS, so browsers connect from any
+// page; with allowed origins configured, upgrades from other origins are
+// refused here.
+func CORSMiddleware(next http.Handler, allowedOrigins []string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		origin := r.Header.Get("Origin")
+		h := w.Header()
+		switch {
+		case len(allowedOrigins) == 0:
+			h.Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && (slices.Contains(allowedOrigins, origin) || sameOrigin(r, origin)):
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Add("Vary", "Origin")
+		default:
+			h.Add("Vary", "Origin")
+			if origin != "" && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "origin not allowed"})
+				return
+			}
+		}
+		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Upload-Offset")
+		h.Set("Access-Control-Expose-Headers", "Upload-Offset, Match-Status, Retry-After")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -16,3 +41,10 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// sameOrigin reports whether origin is the server itself, e.g. the viewer
+// it serves.
+func sameOrigin(r *http.Request, origin string) bool {
+	_, host, ok := strings.Cut(origin, "://")
+	return ok && strings.EqualFold(host, r.Host)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+)
+
+func (s *Server) handlePlayerProfile(w http.ResponseWriter, r *http.Request) {
+	steamID, err := strconv.ParseUint(r.PathValue("steamId"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid steam id"})
+		return
+	}
+
+	visible := s.visible(r)
+	entries := s.matches.EntriesWhere(func(e *store.IndexEntry) bool {
+		return e.HasPlayer(steamID) && visible(e)
+	})
+	if len(entries) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "player not found"})
+		return
+	}
+
+	matches := make([]*models.Match, 0, len(entries))
+	for _, e := range entries {
+		match, err := s.matches.Load(e.ID)
+		if err != nil {
+			s.logger.Warn("skipping unreadable match", "id", e.ID, "error", err)
+			continue
+		}
+		if match.ParsedAt.IsZero() {
+			match.ParsedAt = e.ParsedAt
+		}
+		matches = append(matches, match)
+	}
+
+	writeJSON(w, http.StatusOK, analysis.BuildPlayerProfile(steamID, matches))
+}
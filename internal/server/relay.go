@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// errRelayAddress means a broadcast relay URL points somewhere live ingest
+// won't connect to.
+var errRelayAddress = errors.New("relay address not allowed")
+
+// relayPolicy decides which broadcast relays live ingest may connect to,
+// since the URL comes from the client and is fetched by the server. With
+// no hosts listed, any relay on a public address is allowed; loopback,
+// private, link-local (which includes cloud metadata endpoints) and
+// unspecified addresses are refused. Listing hosts allows those and
+// nothing else, whatever their address.
+type relayPolicy struct {
+	hosts []string
+}
+
+// allowsHost reports whether a URL's host, with or without its port, may
+// be connected to.
+func (p relayPolicy) allowsHost(u *url.URL) bool {
+	if len(p.hosts) == 0 {
+		return true
+	}
+	return slices.Contains(p.hosts, u.Host) || slices.Contains(p.hosts, u.Hostname())
+}
+
+// allowsAddr reports whether an address being dialled may be connected to.
+func (p relayPolicy) allowsAddr(addr netip.Addr) bool {
+	if len(p.hosts) > 0 {
+		return true
+	}
+	addr = addr.Unmap()
+	return !(addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsMulticast())
+}
+
+// client returns an HTTP client that enforces the policy on every
+// connection and redirect, so a public name resolving to a private address
+// or a relay redirecting elsewhere doesn't get around it.
+func (p relayPolicy) client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !p.allowsAddr(ap.Addr()) {
+				return fmt.Errorf("%w: %s", errRelayAddress, ap.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	transport.ResponseHeaderTimeout = 15 * time.Second
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if !p.allowsHost(req.URL) {
+				return fmt.Errorf("%w: %s", errRelayAddress, req.URL.Host)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelayPolicyAddresses(t *testing.T) {
+	var open relayPolicy
+	for addr, want := range map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		"10.1.2.3":         false,
+		"192.168.0.10":     false,
+		"fd00::1":          false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"0.0.0.0":          false,
+		"224.0.0.1":        false,
+	} {
+		if got := open.allowsAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("allowsAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	listed := relayPolicy{hosts: []string{"relay.example.com", "localhost:8080"}}
+	for raw, want := range map[string]bool{
+		"http://relay.example.com/s1t2":      true,
+		"https://relay.example.com:8443/s1":  true,
+		"http://localhost:8080/s1":           true,
+		"http://localhost:9090/s1":           false,
+		"http://169.254.169.254/latest/meta": false,
+	} {
+		u, _ := url.Parse(raw)
+		if got := listed.allowsHost(u); got != want {
+			t.Errorf("allowsHost(%s) = %v, want %v", raw, got, want)
+		}
+	}
+	if !listed.allowsAddr(netip.MustParseAddr("127.0.0.1")) {
+		t.Error("listed hosts should be allowed whatever their address")
+	}
+}
+
+func TestRelayClientRefusesLoopback(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer relay.Close()
+
+	if _, err := (relayPolicy{}).client().Get(relay.URL); !errors.Is(err, errRelayAddress) {
+		t.Errorf("connecting to %s: got %v, want errRelayAddress", relay.URL, err)
+	}
+
+	u, _ := url.Parse(relay.URL)
+	res, err := relayPolicy{hosts: []string{u.Host}}.client().Get(relay.URL)
+	if err != nil {
+		t.Fatalf("connecting to a listed relay: %v", err)
+	}
+	res.Body.Close()
+
+	s := newTestServer(t, false)
+	rec := do(s, "POST", "/api/live", "", strings.NewReader("url="+url.QueryEscape(relay.URL+"/s1t2")))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "relay address not allowed") {
+		t.Errorf("starting live ingest from %s: got %d %s, want 400", relay.URL, rec.Code, rec.Body)
+	}
+}
+
+func TestStartLiveGivesUpWithRequest(t *testing.T) {
+	stall := make(chan struct{})
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stall:
+		case <-r.Context().Done():
+		}
+	}))
+	defer relay.Close()
+	defer close(stall)
+
+	s := newTestServer(t, false)
+	u, _ := url.Parse(relay.URL)
+	s.relays = relayPolicy{hosts: []string{u.Host}}
+	s.relayClient = s.relays.client()
+
+	// A relay that never answers is given up on when the request ends.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest("POST", "/api/live", strings.NewReader("url="+url.QueryEscape(relay.URL+"/s1t2"))).WithContext(ctx)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		s.ServeHTTP(w, r)
+		close(done)
+	}()
+	select {
+	case <-done:
+		if w.Code != http.StatusBadGateway {
+			t.Errorf("got %d %s, want 502", w.Code, w.Body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("starting live ingest still waiting on the relay")
+	}
+}
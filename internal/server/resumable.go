@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/allending313/cs2-demo-parser/internal/upload"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+)
+
+// Resumable uploads, for demos too large to upload reliably in one request:
+//
+//	POST   /api/uploads              size=<bytes> plus the upload form's options
+//	PUT    /api/uploads/{id}         a chunk, starting at the Upload-Offset header
+//	GET    /api/uploads/{id}         the offset to resume from
+//	POST   /api/uploads/{id}/finish  start parsing once every byte has arrived
+//	DELETE /api/uploads/{id}         abandon the upload
+//
+// Every response carries the upload's current state, including its offset.
+// A chunk that breaks off midway keeps the bytes that made it.
+
+const uploadOffsetHeader = "Upload-Offset"
+
+func (s *Server) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+		return
+	}
+
+	size, err := strconv.ParseInt(r.Form.Get("size"), 10, 64)
+	if err != nil || size <= 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "size must be a positive number of bytes"})
+		return
+	}
+	if size > maxUploadSize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "file too large"})
+		return
+	}
+	if !s.admitUpload(w, r, size) {
+		return
+	}
+
+	opts, err := parseOptionsFromForm(r.Form)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	u, err := s.uploads.Create(size, opts, workspace(r))
+	if err != nil {
+		s.logger.Error("failed to create upload", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	writeUpload(w, http.StatusCreated, u)
+}
+
+func (s *Server) handleGetUpload(w http.ResponseWriter, r *http.Request) {
+	if u, ok := s.ownUpload(w, r); ok {
+		writeUpload(w, http.StatusOK, u)
+	}
+}
+
+func (s *Server) handleAppendUpload(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.ownUpload(w, r); !ok {
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing or invalid Upload-Offset header"})
+		return
+	}
+
+	u, err := s.uploads.Append(r.PathValue("id"), offset, s.countUpload(r.Body))
+	if err != nil {
+		s.writeUploadError(w, u, err)
+		return
+	}
+	writeUpload(w, http.StatusOK, u)
+}
+
+func (s *Server) handleFinishUpload(w http.ResponseWriter, r *http.Request) {
+	owned, ok := s.ownUpload(w, r)
+	if !ok {
+		return
+	}
+	// The match belongs to the upload's workspace, whoever finishes it.
+	ws := owned.Workspace
+
+	// The upload is kept, so the client can finish it once a job is done,
+	// or once the server is back.
+	if !s.admitJob(w, r) {
+		return
+	}
+	if s.isDraining() {
+		shuttingDown(w)
+		return
+	}
+	u, path, err := s.uploads.Finish(r.PathValue("id"))
+	if err != nil {
+		s.writeUploadError(w, u, err)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		s.logger.Error("failed to open finished upload", "upload", u.ID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	src := bufio.NewReader(f)
+
+	switch detectFormat(src) {
+	case formatUnknown:
+		f.Close()
+		os.Remove(path)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file must be a .dem demo, or a .gz, .bz2, .zst or .zip of demos"})
+	case formatZip:
+		f.Close()
+		s.startArchiveJobs(w, path, u.Options, ws, s.client(r))
+	default:
+		id := util.GenerateID()
+		jobs, ok := s.createJobs(w, ws, s.client(r), &pendingJob{ID: id, Workspace: ws, Options: u.Options, Source: path})
+		if !ok {
+			f.Close()
+			os.Remove(path)
+			return
+		}
+		job := jobs[0]
+		go func() {
+			match, err := s.parseCompressed(s.jobCtx, id, src, u.Size, u.Options)
+			f.Close()
+			if !s.finishJob(id, match, err) {
+				os.Remove(path)
+			}
+		}()
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+func (s *Server) handleDeleteUpload(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.ownUpload(w, r); !ok {
+		return
+	}
+	if err := s.uploads.Delete(r.PathValue("id")); err != nil {
+		s.writeUploadError(w, upload.Upload{}, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownUpload returns the upload {id} if it belongs to the caller's
+// workspace. Other workspaces' uploads are reported as missing, like their
+// matches.
+func (s *Server) ownUpload(w http.ResponseWriter, r *http.Request) (upload.Upload, bool) {
+	u, err := s.uploads.Get(r.PathValue("id"))
+	if err == nil && s.auth.Enabled() && s.auth.Owner(u.Workspace) != workspace(r) {
+		err = upload.ErrNotFound
+	}
+	if err != nil {
+		s.writeUploadError(w, upload.Upload{}, err)
+		return upload.Upload{}, false
+	}
+	return u, true
+}
+
+func writeUpload(w http.ResponseWriter, status int, u upload.Upload) {
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, u)
+}
+
+// writeUploadError maps upload store errors to responses. Conflicts
+// include the upload so the client can pick up from the right offset.
+func (s *Server) writeUploadError(w http.ResponseWriter, u upload.Upload, err error) {
+	switch {
+	case errors.Is(err, upload.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "upload not found or expired"})
+	case errors.Is(err, upload.ErrOffsetMismatch), errors.Is(err, upload.ErrBusy), errors.Is(err, upload.ErrIncomplete):
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.Offset, 10))
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "upload": u})
+	case errors.Is(err, upload.ErrTooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]any{"error": err.Error(), "upload": u})
+	default:
+		// The connection dropped mid-chunk; whatever arrived was kept.
+		s.logger.Warn("upload chunk interrupted", "upload", u.ID, "offset", u.Offset, "error", err)
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(u.Offset, 10))
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "chunk interrupted", "upload": u})
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUploadBelongsToWorkspace(t *testing.T) {
+	s := newTestServer(t, true)
+
+	w := do(s, "POST", "/api/uploads", tokenA, strings.NewReader("size=10"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	var u struct{ ID string }
+	json.NewDecoder(w.Body).Decode(&u)
+	path := "/api/uploads/" + u.ID
+
+	// Another workspace can't see or touch it.
+	for _, req := range []struct{ method, target string }{
+		{"GET", path},
+		{"PUT", path},
+		{"POST", path + "/finish"},
+		{"DELETE", path},
+	} {
+		r := do(s, req.method, req.target, tokenB, strings.NewReader("0123456789"))
+		if r.Code != http.StatusNotFound {
+			t.Errorf("%s %s from another workspace: %d, want 404", req.method, req.target, r.Code)
+		}
+	}
+
+	if w := do(s, "GET", path, tokenA, nil); w.Code != http.StatusOK {
+		t.Errorf("owner can't get upload: %d", w.Code)
+	}
+	if w := do(s, "DELETE", path, tokenA, nil); w.Code != http.StatusNoContent {
+		t.Errorf("owner can't delete upload: %d", w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+)
+
+// handleScouting builds a scouting report for the team given by the "team"
+// (clan name) and/or "players" (comma-separated SteamIDs) query parameters.
+// An optional "matches" parameter restricts the report to those match IDs;
+// otherwise every stored match the team played in is used.
+func (s *Server) handleScouting(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	team := analysis.TeamSelector{Name: q.Get("team")}
+	for _, field := range util.SplitList(q.Get("players")) {
+		id, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid steam id in players"})
+			return
+		}
+		team.Players = append(team.Players, id)
+	}
+	if team.Name == "" && len(team.Players) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "team or players is required"})
+		return
+	}
+
+	visible := s.visible(r)
+	ids := util.SplitList(q.Get("matches"))
+	if len(ids) == 0 {
+		for _, e := range s.matches.EntriesWhere(func(e *store.IndexEntry) bool { return team.PlayedIn(e.Teams) && visible(e) }) {
+			ids = append(ids, e.ID)
+		}
+	}
+	for _, id := range ids {
+		if e, ok := s.matches.Entry(id); !ok || !visible(&e) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "match not found: " + id})
+			return
+		}
+	}
+	if len(ids) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no matches found for team"})
+		return
+	}
+
+	matches, err := s.matches.LoadAll(ids)
+	if !s.checkLoadError(w, strings.Join(ids, ","), err) {
+		return
+	}
+
+	report := analysis.BuildScoutingReport(team, matches)
+
+	if q.Get("format") == "markdown" || strings.Contains(r.Header.Get("Accept"), "text/markdown") {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(report.Markdown()))
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -10,22 +13,62 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	"github.com/allending313/cs2-demo-parser/internal/annotation"
+	"github.com/allending313/cs2-demo-parser/internal/auth"
+	"github.com/allending313/cs2-demo-parser/internal/codec"
 	models "github.com/allending313/cs2-demo-parser/internal/model"
-	"github.com/allending313/cs2-demo-parser/internal/parser"
-	"github.com/allending313/cs2-demo-parser/internal/util"
+	"github.com/allending313/cs2-demo-parser/internal/ratelimit"
+	"github.com/allending313/cs2-demo-parser/internal/session"
+	"github.com/allending313/cs2-demo-parser/internal/store"
+	"github.com/allending313/cs2-demo-parser/internal/upload"
 )
 
 type Server struct {
-	mux        *http.ServeMux
-	uploadDir  string
-	matchDir   string
-	webFS      fs.FS
-	mapsFS     fs.FS
-	mapConfigs map[string]*models.MapConfig
-	jobs       *models.JobStore
-	logger     *slog.Logger
+	mux         *http.ServeMux
+	uploadDir   string
+	matches     *store.MatchStore
+	webFS       fs.FS
+	mapsFS      fs.FS
+	mapConfigs  map[string]*models.MapConfig
+	jobs        *models.JobStore
+	uploads     *upload.Store
+	live        liveSessions
+	sessions    *session.Store
+	annotations *annotation.Store
+	auth        *auth.Authenticator
+	shares      *auth.Shares
+	limits      Limits
+	metrics     *serverMetrics
+	winProb     *analysis.WinProbModel
+	matchJSON   *matchJSONCache
+	relays      relayPolicy
+	relayClient *http.Client
+	logger      *slog.Logger
+
+	requestLimiter *ratelimit.Limiter
+	uploadLimiter  *ratelimit.Limiter
+
+	// Token /metrics requires, if any.
+	metricsToken string
+
+	// Parse jobs run under jobCtx, which Shutdown cancels if they don't
+	// finish in time. pending holds the jobs that haven't finished, and
+	// running counts them.
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+	pendingMu  sync.Mutex
+	pending    map[string]*pendingJob
+	draining   bool
+	running    sync.WaitGroup
+
+	// Closed by Shutdown to stop background expiry.
+	stop chan struct{}
 }
 
 type Config struct {
@@ -33,30 +76,123 @@ type Config struct {
 	MatchDir  string
 	WebFS     fs.FS
 	MapsFS    fs.FS
+
+	// Optional path to win probability weights fitted with
+	// `cs2demo winprob-fit`. The built-in defaults are used if empty.
+	WinProbModelPath string
+
+	// How long a resumable upload is kept without receiving data.
+	// Defaults to 24 hours.
+	UploadExpiry time.Duration
+
+	// Workspaces and their API tokens. If nil or without tokens, the API
+	// is open to anyone who can reach it.
+	Auth *auth.Config
+
+	// Per-client rate limits and per-workspace quotas. The zero value
+	// leaves everything unlimited.
+	Limits Limits
+
+	// Broadcast relay hosts (host or host:port) live ingest may connect
+	// to. If empty, any relay on a public address is allowed.
+	LiveRelayHosts []string
+
+	// Bearer token required by /metrics. If empty, the endpoint is open.
+	MetricsToken string
 }
 
 func New(cfg Config, logger *slog.Logger) (*Server, error) {
-	for _, dir := range []string{cfg.UploadDir, cfg.MatchDir} {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return nil, fmt.Errorf("creating directory %s: %w", dir, err)
-		}
+	if err := os.MkdirAll(cfg.UploadDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating directory %s: %w", cfg.UploadDir, err)
 	}
 
+	matches, err := store.NewMatchStore(cfg.MatchDir)
+	if err != nil {
+		return nil, fmt.Errorf("opening match store: %w", err)
+	}
+
+	// Annotations sit next to the matches they belong to; the match store
+	// ignores subdirectories.
+	annotations, err := annotation.NewStore(filepath.Join(cfg.MatchDir, "annotations"))
+	if err != nil {
+		return nil, fmt.Errorf("opening annotation store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(cfg.MatchDir, "auth"), 0700); err != nil {
+		return nil, fmt.Errorf("creating auth dir: %w", err)
+	}
+	shares, err := auth.OpenShares(filepath.Join(cfg.MatchDir, "auth", "shares.json"))
+	if err != nil {
+		return nil, err
+	}
+	authenticator, err := auth.New(cfg.Auth, shares)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.UploadExpiry <= 0 {
+		cfg.UploadExpiry = 24 * time.Hour
+	}
+	uploads, err := upload.NewStore(filepath.Join(cfg.UploadDir, "partial"), cfg.UploadExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("opening upload store: %w", err)
+	}
+	stop := make(chan struct{})
+	go uploads.ExpireEvery(10*time.Minute, stop)
+
+	sessions := session.NewStore(time.Hour)
+	go sessions.ExpireEvery(10*time.Minute, stop)
+
+	requestLimiter := ratelimit.New(cfg.Limits.RequestsPerMinute, time.Minute)
+	go requestLimiter.ExpireEvery(10*time.Minute, stop)
+	uploadLimiter := ratelimit.New(cfg.Limits.UploadsPerHour, time.Hour)
+	go uploadLimiter.ExpireEvery(10*time.Minute, stop)
+
 	mapConfigs, err := models.LoadMapConfigs(cfg.MapsFS, "configs")
 	if err != nil {
 		logger.Warn("failed to load map configs, continuing without them", "error", err)
 		mapConfigs = make(map[string]*models.MapConfig)
 	}
 
+	winProb := &analysis.DefaultWinProbModel
+	if cfg.WinProbModelPath != "" {
+		if winProb, err = analysis.LoadWinProbModel(cfg.WinProbModelPath); err != nil {
+			return nil, err
+		}
+	}
+
 	s := &Server{
-		mux:        http.NewServeMux(),
-		uploadDir:  cfg.UploadDir,
-		matchDir:   cfg.MatchDir,
-		webFS:      cfg.WebFS,
-		mapsFS:     cfg.MapsFS,
-		mapConfigs: mapConfigs,
-		jobs:       models.NewJobStore(),
-		logger:     logger,
+		mux:         http.NewServeMux(),
+		uploadDir:   cfg.UploadDir,
+		matches:     matches,
+		webFS:       cfg.WebFS,
+		mapsFS:      cfg.MapsFS,
+		mapConfigs:  mapConfigs,
+		jobs:        models.NewJobStore(),
+		uploads:     uploads,
+		sessions:    sessions,
+		annotations: annotations,
+		auth:        authenticator,
+		shares:      shares,
+		limits:      cfg.Limits,
+		winProb:     winProb,
+		matchJSON:   newMatchJSONCache(),
+		relays:      relayPolicy{hosts: cfg.LiveRelayHosts},
+		logger:      logger,
+
+		requestLimiter: requestLimiter,
+		uploadLimiter:  uploadLimiter,
+		metricsToken:   cfg.MetricsToken,
+
+		pending: make(map[string]*pendingJob),
+		stop:    stop,
+	}
+	s.relayClient = s.relays.client()
+	s.jobCtx, s.cancelJobs = context.WithCancel(context.Background())
+	s.metrics = s.newMetrics()
+
+	if err := s.resumeInterrupted(); err != nil {
+		return nil, err
 	}
 
 	s.routes()
@@ -68,16 +204,48 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) routes() {
-	s.mux.HandleFunc("POST /api/parse", s.handleParse)
-	s.mux.HandleFunc("GET /api/match/{id}/status", s.handleMatchStatus)
-	s.mux.HandleFunc("GET /api/match/{id}", s.handleGetMatch)
-	s.mux.HandleFunc("GET /api/maps/{name}/radar.png", s.handleMapRadar)
-	s.mux.HandleFunc("GET /api/maps", s.handleListMaps)
-	s.mux.HandleFunc("GET /api/health", s.handleHealth)
+	s.handle("POST /api/parse", member, s.handleParse)
+	s.handle("POST /api/uploads", member, s.handleCreateUpload)
+	s.handle("GET /api/uploads/{id}", member, s.handleGetUpload)
+	s.handle("PUT /api/uploads/{id}", member, s.handleAppendUpload)
+	s.handle("POST /api/uploads/{id}/finish", member, s.handleFinishUpload)
+	s.handle("DELETE /api/uploads/{id}", member, s.handleDeleteUpload)
+	s.handle("POST /api/live", member, s.handleStartLive)
+	s.handle("GET /api/live", member, s.handleListLive)
+	s.handle("DELETE /api/live/{id}", writeMatch, s.handleStopLive)
+	s.handle("GET /api/live/{id}/ws", readMatch, s.handleLiveSocket)
+	s.handle("POST /api/sessions", member, s.handleCreateSession)
+	s.handle("GET /api/sessions/{id}", authenticated, s.handleGetSession)
+	s.handle("GET /api/sessions/{id}/ws", authenticated, s.handleSessionSocket)
+	s.handle("GET /api/match/{id}/status", readMatch, s.handleMatchStatus)
+	s.handle("GET /api/match/{id}/events", readMatch, s.handleJobEvents)
+	s.handle("GET /api/match/{id}", readMatch, s.handleGetMatch)
+	s.handle("GET /api/match/{id}/winprob", readMatch, s.handleWinProb)
+	s.handle("GET /api/match/{id}/trades", readMatch, s.handleTrades)
+	s.handle("GET /api/match/{id}/clutches", readMatch, s.handleClutches)
+	s.handle("GET /api/match/{id}/openings", readMatch, s.handleOpenings)
+	s.handle("GET /api/match/{id}/highlights", readMatch, s.handleHighlights)
+	s.handle("GET /api/match/{id}/annotations", readMatch, s.handleListAnnotations)
+	s.handle("POST /api/match/{id}/annotations", writeMatch, s.handleCreateAnnotation)
+	s.handle("GET /api/match/{id}/annotations/{annotationId}", readMatch, s.handleGetAnnotation)
+	s.handle("PUT /api/match/{id}/annotations/{annotationId}", writeMatch, s.handleUpdateAnnotation)
+	s.handle("DELETE /api/match/{id}/annotations/{annotationId}", writeMatch, s.handleDeleteAnnotation)
+	s.handle("POST /api/match/{id}/shares", writeMatch, s.handleCreateShare)
+	s.handle("GET /api/match/{id}/shares", writeMatch, s.handleListShares)
+	s.handle("DELETE /api/match/{id}/shares/{token}", writeMatch, s.handleRevokeShare)
+	s.handle("GET /api/match/{id}/vdm", readMatch, s.handleVDM)
+	s.handle("GET /api/match/{id}/export/{table}", readMatch, s.handleExportTable)
+	s.handle("GET /api/export/tables", public, s.handleExportTables)
+	s.handle("GET /api/players/{steamId}", member, s.handlePlayerProfile)
+	s.handle("GET /api/scouting", member, s.handleScouting)
+	s.handle("GET /api/maps/{name}/radar.png", public, s.handleMapRadar)
+	s.handle("GET /api/maps", public, s.handleListMaps)
+	s.handle("GET /api/health", public, s.handleHealth)
+	s.mux.HandleFunc("GET /metrics", s.authorize(public, s.handleMetrics))
 
 	// Serve React SPA from embedded filesystem
 	fileServer := http.FileServer(http.FS(s.webFS))
-	s.mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+	s.mux.HandleFunc("GET /", s.authorize(public, func(w http.ResponseWriter, r *http.Request) {
 		// Serve index.html for paths that don't match a file
 		path := r.URL.Path
 		if path != "/" {
@@ -94,126 +262,191 @@ func (s *Server) routes() {
 			}
 		}
 		fileServer.ServeHTTP(w, r)
-	})
+	}))
 }
 
+// handleHealth also tells clients whether they need a token.
 func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "auth": s.auth.Enabled()})
 }
 
-func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
-	// 800MB limit
-	r.Body = http.MaxBytesReader(w, r.Body, 800<<20)
-
-	if err := r.ParseMultipartForm(800 << 20); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file too large or invalid multipart form"})
+func (s *Server) handleMatchStatus(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	job, ok := s.jobs.Get(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "match not found"})
 		return
 	}
+	writeJSON(w, http.StatusOK, job)
+}
 
-	file, header, err := r.FormFile("demo")
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing 'demo' file field"})
-		return
-	}
-	defer file.Close()
+func (s *Server) handleGetMatch(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
 
-	if !strings.HasSuffix(header.Filename, ".dem") {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file must be a .dem file"})
+	if job, ok := s.jobs.Get(id); ok && job.Status != models.JobStatusReady {
+		w.Header().Set("Cache-Control", "no-store")
+		if partial, ok := s.jobs.Partial(id); ok {
+			s.writePartialMatch(w, r, partial)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
 		return
 	}
 
-	id := util.GenerateID()
-	uploadPath := filepath.Join(s.uploadDir, id+".dem")
-
-	dst, err := os.Create(uploadPath)
-	if err != nil {
-		s.logger.Error("failed to create upload file", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+	f, err := s.matches.Open(id)
+	if !s.checkLoadError(w, id, err) {
 		return
 	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		s.logger.Error("failed to save upload", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+	defer f.Close()
+	info, err := f.Stat()
+	if !s.checkLoadError(w, id, err) {
 		return
 	}
-	dst.Close()
-
-	job := s.jobs.Create(id)
-	go s.parseInBackground(id, uploadPath)
-
-	writeJSON(w, http.StatusAccepted, job)
-}
-
-func (s *Server) parseInBackground(id, demoPath string) {
-	s.logger.Info("starting parse", "id", id)
-
-	match, err := parser.ParseDemo(demoPath, id, func(progress float32) {
-		s.jobs.SetProgress(id, progress)
-	})
 
-	os.Remove(demoPath)
-
-	if err != nil {
-		s.logger.Error("parse failed", "id", id, "error", err)
-		s.jobs.Fail(id, err)
-		return
+	// The viewer asks for the compact binary format; JSON stays the default
+	// for everything else.
+	binary := acceptsBinaryMatch(r)
+	gzipped := acceptsGzip(r)
+
+	// A match only changes when it's reparsed, which rewrites its file.
+	version := fmt.Sprintf("%s-%x-%x", id, info.ModTime().UnixNano(), info.Size())
+	etag := version
+	if binary {
+		etag += "-bin"
+	} else {
+		etag += "-json"
 	}
-
-	if cfg, ok := s.mapConfigs[match.Map]; ok {
-		match.MapConfig = cfg
+	if gzipped {
+		etag += "-gz"
 	}
-
-	matchPath := filepath.Join(s.matchDir, id+".json")
-	if err := writeMatchJSON(matchPath, match); err != nil {
-		s.logger.Error("failed to write match JSON", "id", id, "error", err)
-		s.jobs.Fail(id, fmt.Errorf("writing match data: %w", err))
+	etag = `"` + etag + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "private, max-age=86400")
+	h.Set("Vary", "Accept, Accept-Encoding")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	s.jobs.Complete(id)
-	s.logger.Info("parse complete", "id", id, "map", match.Map, "rounds", len(match.Rounds))
-}
+	switch {
+	case binary && gzipped:
+		// Stored files are already gzip compressed: send them as they are.
+		h.Set("Content-Type", codec.ContentType)
+		h.Set("Content-Encoding", "gzip")
+		h.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		io.Copy(w, f)
+
+	case binary:
+		gz, err := gzip.NewReader(f)
+		if !s.checkLoadError(w, id, err) {
+			return
+		}
+		h.Set("Content-Type", codec.ContentType)
+		io.Copy(w, gz)
 
-func (s *Server) handleMatchStatus(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	job, ok := s.jobs.Get(id)
-	if !ok {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "match not found"})
-		return
+	default:
+		data, err := s.matchJSON.get(version, func() (*models.Match, error) { return s.matches.Load(id) })
+		if !s.checkLoadError(w, id, err) {
+			return
+		}
+		h.Set("Content-Type", "application/json")
+		if gzipped {
+			h.Set("Content-Encoding", "gzip")
+			h.Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data)
+			return
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if !s.checkLoadError(w, id, err) {
+			return
+		}
+		io.Copy(w, gz)
 	}
-	writeJSON(w, http.StatusOK, job)
 }
 
-func (s *Server) handleGetMatch(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-
-	if job, ok := s.jobs.Get(id); ok && job.Status != models.JobStatusReady {
-		writeJSON(w, http.StatusOK, job)
-		return
+// writePartialMatch sends the rounds parsed so far for a match that is still
+// parsing, in whichever format the client asked for. Match-Status tells the
+// viewer more rounds are coming.
+func (s *Server) writePartialMatch(w http.ResponseWriter, r *http.Request, match *models.Match) {
+	contentType := "application/json"
+	marshal := func(m *models.Match) ([]byte, error) { return json.Marshal(m) }
+	if acceptsBinaryMatch(r) {
+		contentType, marshal = codec.ContentType, codec.Marshal
 	}
 
-	matchPath := filepath.Join(s.matchDir, id+".json")
-	data, err := os.ReadFile(matchPath)
+	data, err := marshal(match)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "match not found"})
+		s.logger.Error("failed to encode partial match", "id", match.ID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
 		return
 	}
+	w.Header().Set("Match-Status", string(models.JobStatusParsing))
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+	writeBody(w, contentType, data, acceptsGzip(r))
+}
 
-	w.Header().Set("Content-Type", "application/json")
-
-	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-		w.Header().Set("Content-Encoding", "gzip")
+// writeBody writes data with the given content type, gzip compressed if
+// the client accepts it.
+func writeBody(w http.ResponseWriter, contentType string, data []byte, gzipped bool) {
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	if gzipped {
+		h.Set("Content-Encoding", "gzip")
 		gz := gzip.NewWriter(w)
 		defer gz.Close()
 		gz.Write(data)
 		return
 	}
-
 	w.Write(data)
 }
 
+// etagMatches reports whether an If-None-Match header value lists etag,
+// using the weak comparison RFC 9110 specifies for it.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows a gzip
+// response, either by name or through "*", with a nonzero q-value.
+func acceptsGzip(r *http.Request) bool {
+	accepted := false
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "x-gzip" && name != "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		// An explicit gzip entry overrides "*".
+		if name != "*" {
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// acceptsBinaryMatch reports whether the client asked for the binary match
+// format, through the Accept header or ?format=binary.
+func acceptsBinaryMatch(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "binary" || strings.Contains(r.Header.Get("Accept"), codec.ContentType)
+}
+
 func (s *Server) handleMapRadar(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
 
@@ -243,19 +476,33 @@ func (s *Server) handleListMaps(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, http.StatusOK, maps)
 }
 
-func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(v)
+// loadMatch reads a stored match, writing a JSON error and returning false
+// if it can't be loaded.
+func (s *Server) loadMatch(w http.ResponseWriter, id string) (*models.Match, bool) {
+	match, err := s.matches.Load(id)
+	if !s.checkLoadError(w, id, err) {
+		return nil, false
+	}
+	return match, true
 }
 
-func writeMatchJSON(path string, match *models.Match) error {
-	f, err := os.Create(path)
+// checkLoadError writes the JSON error for a failed store read and returns
+// false, or returns true if err is nil.
+func (s *Server) checkLoadError(w http.ResponseWriter, id string, err error) bool {
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "match not found"})
+		return false
+	}
 	if err != nil {
-		return err
+		s.logger.Error("failed to load match", "id", id, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return false
 	}
-	defer f.Close()
+	return true
+}
 
-	enc := json.NewEncoder(f)
-	return enc.Encode(match)
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
 }
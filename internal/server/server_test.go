@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/allending313/cs2-demo-parser/internal/auth"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"br, GZIP", true},
+		{"x-gzip", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.0, deflate", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"*, gzip;q=0", false},
+		{"gzip;q=0, *", false},
+		{"identity", false},
+		{"gzipped", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsGzip(r); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+const (
+	tokenA = "workspace-a-token-0001"
+	tokenB = "workspace-b-token-0001"
+)
+
+// newTestServer returns a server with workspaces "a" and "b", each with one
+// token, or with authentication off if authOn is false.
+func newTestServer(t *testing.T, authOn bool) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := Config{
+		UploadDir: filepath.Join(dir, "uploads"),
+		MatchDir:  filepath.Join(dir, "matches"),
+		WebFS:     fstest.MapFS{"index.html": {Data: []byte("<html></html>")}},
+		MapsFS:    fstest.MapFS{},
+	}
+	if authOn {
+		cfg.Auth = &auth.Config{
+			DefaultWorkspace: "a",
+			Workspaces: []auth.Workspace{
+				{ID: "a", Tokens: []auth.Token{{Name: "alice", Token: tokenA}}},
+				{ID: "b", Tokens: []auth.Token{{Name: "bob", Token: tokenB}}},
+			},
+		}
+	}
+	s, err := New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s
+}
+
+// do sends a request to s with the given token, if any, and returns the
+// response.
+func do(s *Server, method, target, token string, body io.Reader) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if method == "POST" && body != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestMetricsToken(t *testing.T) {
+	s := newTestServer(t, false)
+	if w := do(s, "GET", "/metrics", "", nil); w.Code != http.StatusOK {
+		t.Errorf("open metrics: got %d, want 200", w.Code)
+	}
+
+	s.metricsToken = "metrics-token-0001"
+	for token, want := range map[string]int{
+		"":                   http.StatusUnauthorized,
+		tokenA:               http.StatusUnauthorized,
+		"metrics-token-0001": http.StatusOK,
+	} {
+		if w := do(s, "GET", "/metrics", token, nil); w.Code != want {
+			t.Errorf("metrics with token %q: got %d, want %d", token, w.Code, want)
+		}
+	}
+}
+
+func TestJobsQueuedMetric(t *testing.T) {
+	s := newTestServer(t, false)
+	queued := func() string {
+		for _, line := range strings.Split(do(s, "GET", "/metrics", "", nil).Body.String(), "\n") {
+			if v, ok := strings.CutPrefix(line, "cs2demo_jobs_queued "); ok {
+				return v
+			}
+		}
+		return ""
+	}
+
+	// Every registered job counts until its parse starts, or it finishes
+	// without one.
+	s.startJobs(&pendingJob{ID: "one"}, &pendingJob{ID: "two"}, &pendingJob{ID: "three"})
+	if got := queued(); got != "3" {
+		t.Errorf("queued = %q, want 3", got)
+	}
+	s.startParse("one")
+	s.startParse("one")
+	s.finishJob("two", nil, errors.New("unreadable"))
+	if got := queued(); got != "1" {
+		t.Errorf("queued = %q, want 1", got)
+	}
+	s.finishJob("one", nil, errors.New("invalid"))
+	s.finishJob("three", nil, errors.New("invalid"))
+	if got := queued(); got != "0" {
+		t.Errorf("queued = %q, want 0", got)
+	}
+}
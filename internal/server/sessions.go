@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/allending313/cs2-demo-parser/internal/session"
+	"github.com/allending313/cs2-demo-parser/internal/websocket"
+)
+
+// sessionResponse is a session as returned by the API. HostToken is only
+// set in the response to the request that created it.
+type sessionResponse struct {
+	*session.Session
+	State     session.State `json:"state"`
+	Viewers   int           `json:"viewers"`
+	HostToken string        `json:"hostToken,omitempty"`
+}
+
+// handleCreateSession starts a watch party for the match in the form field
+// "match". It may be a stored match or one still being parsed.
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFieldSize)
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid form"})
+		return
+	}
+
+	matchID := r.PostForm.Get("match")
+	if matchID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "match is required"})
+		return
+	}
+	if !s.checkMatchExists(w, matchID) {
+		return
+	}
+	if !s.canRead(r, matchID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "match not found"})
+		return
+	}
+
+	sess, token, err := s.sessions.Create(matchID)
+	if err != nil {
+		s.logger.Error("failed to create session", "match", matchID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	s.logger.Info("watch session created", "id", sess.ID, "match", matchID)
+	writeJSON(w, http.StatusCreated, sessionResponse{
+		Session:   sess,
+		State:     sess.State(),
+		HostToken: token,
+	})
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.getSession(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, sessionResponse{
+		Session: sess,
+		State:   sess.State(),
+		Viewers: sess.Viewers(),
+	})
+}
+
+// handleSessionSocket connects a member to a session. Members receive a
+// session.Message for every playback change and whenever someone joins or
+// leaves. The host, who passes its token as the query parameter "host",
+// sends {"type":"state","state":{...}} to change the playback; messages
+// from anyone else are ignored.
+func (s *Server) handleSessionSocket(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.getSession(w, r)
+	if !ok {
+		return
+	}
+	isHost := sess.IsHost(r.URL.Query().Get("host"))
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	messages, leave := sess.Join()
+	defer leave()
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if !isHost {
+				continue
+			}
+			var msg session.Message
+			if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "state" || msg.State == nil {
+				continue
+			}
+			if _, err := sess.SetState(*msg.State); err != nil {
+				conn.WriteJSON(map[string]string{"type": "error", "error": err.Error()})
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// getSession returns the session in the path, if the caller may watch its
+// match.
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) (*session.Session, bool) {
+	sess, err := s.sessions.Get(r.PathValue("id"))
+	if err == nil && !s.canRead(r, sess.MatchID) {
+		err = session.ErrNotFound
+	}
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	return sess, true
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// Jobs still pending when the server shuts down are written here, and
+// picked up again by the next start.
+const interruptedFile = "interrupted.json"
+
+var errInterrupted = errors.New("interrupted by a server shutdown")
+
+// pendingJob is a parse job that hasn't finished, with what's needed to
+// run it again after a restart.
+type pendingJob struct {
+	ID        string              `json:"id"`
+	Workspace string              `json:"workspace,omitempty"`
+	Options   models.ParseOptions `json:"options"`
+
+	// The demo, or the zip archive holding it as Entry. Empty until the
+	// demo has been received in full, as a partial upload can't be
+	// parsed again.
+	Source string `json:"source,omitempty"`
+	Entry  string `json:"entry,omitempty"`
+
+	// Whether parsing has begun; until then the job counts as queued.
+	started bool
+}
+
+// startJobs registers parse jobs so that Shutdown waits for them. Once
+// the server is shutting down it refuses, and the caller must not start
+// them.
+func (s *Server) startJobs(jobs ...*pendingJob) bool {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	if s.draining {
+		return false
+	}
+	for _, p := range jobs {
+		s.pending[p.ID] = p
+	}
+	s.running.Add(len(jobs))
+	s.metrics.jobsQueued.Add(float64(len(jobs)))
+	return true
+}
+
+// startParse records that a job registered with startJobs is no longer
+// queued, as its parse has begun or it finished without one.
+func (s *Server) startParse(id string) {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	if p, ok := s.pending[id]; ok && !p.started {
+		p.started = true
+		s.metrics.jobsQueued.Add(-1)
+	}
+}
+
+// setJobSource records where a job's demo can be read from again, once
+// its upload is complete.
+func (s *Server) setJobSource(id, source string) {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	if p, ok := s.pending[id]; ok {
+		p.Source = source
+	}
+}
+
+// finishJob completes a job registered with startJobs. A job that fails
+// while the server shuts down is assumed to have been cut short by it: it
+// stays pending, to be resumed or reported after the restart, and
+// finishJob reports true. The caller should then keep its demo.
+func (s *Server) finishJob(id string, match *models.Match, err error) bool {
+	defer s.running.Done()
+	s.startParse(id)
+
+	s.pendingMu.Lock()
+	interrupted := err != nil && s.draining
+	if !interrupted {
+		delete(s.pending, id)
+	}
+	s.pendingMu.Unlock()
+
+	if interrupted {
+		s.logger.Warn("parse interrupted by shutdown", "id", id, "error", err)
+		return true
+	}
+	s.completeJob(id, match, err)
+	return false
+}
+
+func (s *Server) isDraining() bool {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	return s.draining
+}
+
+func shuttingDown(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "30")
+	writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "server is shutting down, try again shortly"})
+}
+
+// Shutdown stops the server's background work. Live broadcasts are
+// stopped and stored as far as they got, and parse jobs are given until
+// ctx is done to finish. Jobs still running then are cancelled and saved
+// to be resumed by the next start, or reported as failed if their demo
+// wasn't fully uploaded. No new jobs start once Shutdown is called.
+//
+// Shutdown doesn't stop the HTTP server; do that first, or alongside, so
+// that uploads in flight can finish.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.pendingMu.Lock()
+	s.draining = true
+	s.pendingMu.Unlock()
+
+	close(s.stop)
+	for _, l := range s.live.list() {
+		l.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("parse jobs still running: %w", ctx.Err())
+		s.cancelJobs()
+		// Parsers stop promptly once cancelled.
+		<-done
+	}
+	return errors.Join(err, s.saveInterrupted())
+}
+
+func (s *Server) saveInterrupted() error {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	path := filepath.Join(s.uploadDir, interruptedFile)
+	if len(s.pending) == 0 {
+		os.Remove(path)
+		return nil
+	}
+
+	jobs := make([]*pendingJob, 0, len(s.pending))
+	for _, p := range s.pending {
+		jobs = append(jobs, p)
+	}
+	// Keep each archive's entries together, to resume them in one pass
+	// over it.
+	slices.SortFunc(jobs, func(a, b *pendingJob) int {
+		return strings.Compare(a.Source, b.Source)
+	})
+
+	data, err := json.Marshal(jobs)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("saving interrupted jobs: %w", err)
+	}
+	s.logger.Info("saved interrupted parse jobs", "count", len(jobs))
+	return nil
+}
+
+// resumeInterrupted restarts the jobs a previous shutdown interrupted and
+// fails those whose demo is gone, so clients following them hear why. It
+// also removes uploads a crash left behind.
+func (s *Server) resumeInterrupted() error {
+	path := filepath.Join(s.uploadDir, interruptedFile)
+	var jobs []*pendingJob
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &jobs); err != nil {
+			s.logger.Warn("ignoring unreadable interrupted jobs file", "error", err)
+		}
+		os.Remove(path)
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("reading interrupted jobs: %w", err)
+	}
+
+	sources := make(map[string]bool)
+	var archive []*pendingJob
+	for i, p := range jobs {
+		s.jobs.Create(p.ID, p.Workspace, "")
+		if _, err := os.Stat(p.Source); err != nil {
+			s.jobs.Fail(p.ID, errInterrupted)
+			continue
+		}
+		sources[p.Source] = true
+
+		if p.Entry == "" {
+			s.resumeDemo(p)
+			continue
+		}
+		archive = append(archive, p)
+		if i+1 == len(jobs) || jobs[i+1].Source != p.Source {
+			s.resumeArchive(archive)
+			archive = nil
+		}
+	}
+	if len(jobs) > 0 {
+		s.logger.Info("resuming interrupted parse jobs", "count", len(jobs))
+	}
+
+	s.removeStaleUploads(sources)
+	return nil
+}
+
+func (s *Server) resumeDemo(p *pendingJob) {
+	s.startJobs(p)
+	go func() {
+		f, err := os.Open(p.Source)
+		if err != nil {
+			s.finishJob(p.ID, nil, err)
+			return
+		}
+		var size int64
+		if info, err := f.Stat(); err == nil {
+			size = info.Size()
+		}
+		match, err := s.parseCompressed(s.jobCtx, p.ID, bufio.NewReader(f), size, p.Options)
+		f.Close()
+		if !s.finishJob(p.ID, match, err) {
+			os.Remove(p.Source)
+		}
+	}()
+}
+
+func (s *Server) resumeArchive(jobs []*pendingJob) {
+	archivePath := jobs[0].Source
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		for _, p := range jobs {
+			s.jobs.Fail(p.ID, errInterrupted)
+		}
+		os.Remove(archivePath)
+		return
+	}
+
+	entries := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+	var demos []*zip.File
+	var resumed []*pendingJob
+	for _, p := range jobs {
+		f, ok := entries[p.Entry]
+		if !ok {
+			s.jobs.Fail(p.ID, errInterrupted)
+			continue
+		}
+		demos = append(demos, f)
+		resumed = append(resumed, p)
+	}
+	s.startJobs(resumed...)
+	go s.runArchive(archivePath, zr, demos, resumed)
+}
+
+// removeStaleUploads deletes demos in the upload directory that no job
+// will read, e.g. left by a crash mid-upload.
+func (s *Server) removeStaleUploads(keep map[string]bool) {
+	files, err := os.ReadDir(s.uploadDir)
+	if err != nil {
+		return
+	}
+	for _, f := range files {
+		path := filepath.Join(s.uploadDir, f.Name())
+		if f.IsDir() || keep[path] {
+			continue
+		}
+		for _, ext := range uploadExtensions {
+			if strings.HasSuffix(f.Name(), ext) {
+				s.logger.Info("removing stale upload", "file", f.Name())
+				os.Remove(path)
+				break
+			}
+		}
+	}
+}
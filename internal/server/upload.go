@@ -0,0 +1,426 @@
+package server
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allending313/cs2-demo-parser/internal/analysis"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+	"github.com/allending313/cs2-demo-parser/internal/parser"
+	"github.com/allending313/cs2-demo-parser/internal/util"
+)
+
+const (
+	maxUploadSize = 800 << 20
+
+	// Upper bound on each form field sent before the demo.
+	maxFieldSize = 1 << 10
+)
+
+// handleParse reads a multipart upload as it arrives: form fields first,
+// then the "demo" part, which is written to disk and fed to the parser at
+// the same time. By the time the upload ends, parsing is nearly done.
+//
+// The demo may be gzip, bzip2 or zstd compressed. A zip archive instead
+// gets one job per demo inside it, and the response lists them as "jobs".
+//
+// Form fields after the demo part are ignored, so clients must send the
+// parse options first.
+func (s *Server) handleParse(w http.ResponseWriter, r *http.Request) {
+	// The demo is only kept until it's parsed, so it doesn't count
+	// towards the quota.
+	if !s.admitUpload(w, r, 0) {
+		return
+	}
+	r.Body = s.countUpload(http.MaxBytesReader(w, r.Body, maxUploadSize))
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid multipart form"})
+		return
+	}
+
+	form, part, err := readUntilDemo(mr)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	defer part.Close()
+
+	opts, err := parseOptionsFromForm(form)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	src := bufio.NewReaderSize(part, 64<<10)
+	format := detectFormat(src)
+	switch format {
+	case formatUnknown:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file must be a .dem demo, or a .gz, .bz2, .zst or .zip of demos"})
+		return
+	case formatZip:
+		// Zip entries can only be read once the whole archive is on disk.
+		s.parseArchive(w, src, opts, workspace(r), s.client(r))
+		return
+	}
+
+	id := util.GenerateID()
+	uploadPath := filepath.Join(s.uploadDir, id+uploadExtensions[format])
+
+	dst, err := os.Create(uploadPath)
+	if err != nil {
+		s.logger.Error("failed to create upload file", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error"})
+		return
+	}
+	defer dst.Close()
+
+	jobs, ok := s.createJobs(w, workspace(r), s.client(r), &pendingJob{ID: id, Workspace: workspace(r), Options: opts})
+	if !ok {
+		dst.Close()
+		os.Remove(uploadPath)
+		return
+	}
+	job := jobs[0]
+
+	ctx, cancel := context.WithCancel(s.jobCtx)
+	pr, pw := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		defer cancel()
+		// The request also holds the form fields, but the demo is nearly
+		// all of it.
+		match, err := s.parseCompressed(ctx, id, pr, r.ContentLength, opts)
+		// Unblocks the upload if the parser stopped reading early.
+		pr.Close()
+
+		uploadErr := <-uploaded
+		if uploadErr != nil {
+			err = fmt.Errorf("%w: %w", errUploadInterrupted, uploadErr)
+		}
+		// A complete upload is kept if parsing it is interrupted, to
+		// parse it again after the restart.
+		if !s.finishJob(id, match, err) || uploadErr != nil {
+			os.Remove(uploadPath)
+		}
+	}()
+
+	_, err = io.Copy(io.MultiWriter(dst, &parserSink{w: pw}), src)
+	if err == nil {
+		err = dst.Close()
+	}
+	if err != nil {
+		cancel()
+	} else {
+		s.setJobSource(id, uploadPath)
+	}
+	pw.CloseWithError(err)
+	uploaded <- err
+
+	if err != nil {
+		s.uploadFailed(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// parseArchive saves a zip upload and starts one job per demo in it,
+// parsing them one after another.
+func (s *Server) parseArchive(w http.ResponseWriter, src io.Reader, opts models.ParseOptions, workspace, client string) {
+	archivePath := filepath.Join(s.uploadDir, util.GenerateID()+uploadExtensions[formatZip])
+	if err := saveUpload(archivePath, src); err != nil {
+		os.Remove(archivePath)
+		s.uploadFailed(w, err)
+		return
+	}
+	s.startArchiveJobs(w, archivePath, opts, workspace, client)
+}
+
+// startArchiveJobs starts one job per demo in the zip archive at
+// archivePath, which is removed once they have all been parsed. The
+// matches belong to workspace, and the jobs to client.
+func (s *Server) startArchiveJobs(w http.ResponseWriter, archivePath string, opts models.ParseOptions, workspace, client string) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		os.Remove(archivePath)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid zip archive"})
+		return
+	}
+
+	var demos []*zip.File
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && isDemoEntry(f) {
+			demos = append(demos, f)
+		}
+	}
+	if len(demos) == 0 {
+		zr.Close()
+		os.Remove(archivePath)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "archive contains no demos"})
+		return
+	}
+
+	pending := make([]*pendingJob, len(demos))
+	for i, f := range demos {
+		pending[i] = &pendingJob{ID: util.GenerateID(), Workspace: workspace, Options: opts, Source: archivePath, Entry: f.Name}
+	}
+	jobs, ok := s.createJobs(w, workspace, client, pending...)
+	if !ok {
+		zr.Close()
+		os.Remove(archivePath)
+		return
+	}
+	go s.runArchive(archivePath, zr, demos, pending)
+
+	writeJSON(w, http.StatusAccepted, map[string]any{"jobs": jobs})
+}
+
+// runArchive parses demos from the zip archive at archivePath one after
+// another, for the jobs registered for them. The archive is removed
+// afterwards, unless a shutdown interrupted some of them.
+func (s *Server) runArchive(archivePath string, zr *zip.ReadCloser, demos []*zip.File, jobs []*pendingJob) {
+	keep := false
+	for i, f := range demos {
+		id := jobs[i].ID
+
+		// Demos not started yet are left for after the restart.
+		if s.isDraining() {
+			s.finishJob(id, nil, errInterrupted)
+			keep = true
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			s.finishJob(id, nil, fmt.Errorf("reading %s from archive: %w", f.Name, err))
+			continue
+		}
+		match, err := s.parseCompressed(s.jobCtx, id, rc, int64(f.UncompressedSize64), jobs[i].Options)
+		rc.Close()
+		if s.finishJob(id, match, err) {
+			keep = true
+		}
+	}
+
+	zr.Close()
+	if !keep {
+		os.Remove(archivePath)
+	}
+}
+
+// isDemoEntry reports whether a zip entry holds a demo, possibly compressed.
+func isDemoEntry(f *zip.File) bool {
+	rc, err := f.Open()
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+
+	format := detectFormat(bufio.NewReader(rc))
+	return format != formatUnknown && format != formatZip
+}
+
+func saveUpload(path string, src io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// uploadFailed reports an upload that couldn't be received in full.
+func (s *Server) uploadFailed(w http.ResponseWriter, err error) {
+	s.logger.Error("failed to receive upload", "error", err)
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "file too large"})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": "upload interrupted"})
+}
+
+// readUntilDemo collects form fields up to the "demo" file part and returns
+// them with the part, positioned at the start of the file.
+func readUntilDemo(mr *multipart.Reader) (url.Values, *multipart.Part, error) {
+	form := make(url.Values)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, nil, errors.New("missing 'demo' file field")
+		}
+		if err != nil {
+			return nil, nil, errors.New("invalid multipart form")
+		}
+		if part.FormName() == "demo" {
+			return form, part, nil
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+		part.Close()
+		if err != nil || len(value) > maxFieldSize {
+			return nil, nil, errors.New("invalid multipart form")
+		}
+		form.Add(part.FormName(), string(value))
+	}
+}
+
+// parserSink feeds the parser's pipe until the parser stops reading, after
+// which it discards writes so the rest of the upload still reaches disk.
+type parserSink struct {
+	w    *io.PipeWriter
+	done bool
+}
+
+func (s *parserSink) Write(b []byte) (int, error) {
+	if !s.done {
+		if _, err := s.w.Write(b); err != nil {
+			s.done = true
+		}
+	}
+	return len(b), nil
+}
+
+// parseOptionsFromForm reads the optional parse settings sent alongside an
+// upload, falling back to the defaults for any that are missing.
+func parseOptionsFromForm(form url.Values) (models.ParseOptions, error) {
+	opts := models.DefaultParseOptions()
+
+	if v := form.Get("snapshotRate"); v != "" {
+		rate, err := models.ParseSnapshotRate(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.SnapshotRate = rate
+	}
+	if v := form.Get("postRoundSeconds"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid postRoundSeconds %q", v)
+		}
+		opts.PostRoundSeconds = seconds
+	}
+	if v := form.Get("trajectoryPoints"); v != "" {
+		points, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid trajectoryPoints %q", v)
+		}
+		opts.TrajectoryPoints = points
+	}
+	if v := form.Get("events"); v != "" {
+		for _, family := range strings.Split(v, ",") {
+			opts.Events = append(opts.Events, strings.TrimSpace(family))
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+// parseCompressed parses a demo that may be gzip, bzip2 or zstd compressed.
+// Progress is reported as the share of size bytes read from r; pass 0 if
+// the size isn't known.
+func (s *Server) parseCompressed(ctx context.Context, id string, r io.Reader, size int64, opts models.ParseOptions) (*models.Match, error) {
+	r = parser.NewProgressReader(r, size, func(progress float32) {
+		s.jobs.SetProgress(id, progress)
+	})
+	demo, closeDemo, err := decompressDemo(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errDecompress, err)
+	}
+	defer closeDemo()
+
+	return s.parseJob(ctx, id, demo, opts)
+}
+
+// parseJob parses a demo for the job with the given id, reporting finished
+// rounds on the job.
+func (s *Server) parseJob(ctx context.Context, id string, r io.Reader, opts models.ParseOptions) (*models.Match, error) {
+	s.logger.Info("starting parse", "id", id)
+	s.startParse(id)
+
+	s.metrics.jobsActive.Add(1)
+	defer s.metrics.jobsActive.Add(-1)
+	defer func(start time.Time) {
+		s.metrics.parseDuration.Observe(time.Since(start).Seconds())
+	}(time.Now())
+
+	return parser.ParseReader(ctx, r, id, opts, parser.Hooks{
+		Round: s.publishRounds(id),
+	})
+}
+
+// publishRounds returns a parser round hook that publishes the rounds
+// parsed so far, annotated, as the partial match of the job with the given
+// id.
+func (s *Server) publishRounds(id string) func(*models.Match) {
+	var rounds []models.Round
+	return func(match *models.Match) {
+		rounds = append(rounds, s.annotateRound(match.Rounds[len(match.Rounds)-1]))
+
+		partial := *match
+		partial.Rounds = rounds[:len(rounds):len(rounds)]
+		partial.MapConfig = s.mapConfigs[match.Map]
+		s.jobs.SetPartial(id, &partial)
+	}
+}
+
+// annotateRound runs the stored analyses on a round parsed so far. The
+// parser may still append to the round's kills and grenades, so those are
+// copied first.
+func (s *Server) annotateRound(round models.Round) models.Round {
+	round.Kills = slices.Clone(round.Kills)
+	round.Grenades = slices.Clone(round.Grenades)
+
+	one := models.Match{Rounds: []models.Round{round}}
+	analysis.AnnotateMatch(&one, s.winProb)
+	return one.Rounds[0]
+}
+
+// completeJob runs the stored analyses on a parsed match and saves it, or
+// fails the job if parsing failed.
+func (s *Server) completeJob(id string, match *models.Match, err error) {
+	if err != nil {
+		s.logger.Error("parse failed", "id", id, "error", err)
+		s.metrics.parseFailures.Inc(failureClass(err))
+		s.jobs.Fail(id, err)
+		return
+	}
+
+	if cfg, ok := s.mapConfigs[match.Map]; ok {
+		match.MapConfig = cfg
+	}
+	if job, ok := s.jobs.Get(id); ok {
+		match.Workspace = job.Workspace
+	}
+	analysis.AnnotateMatch(match, s.winProb)
+
+	if err := s.matches.Save(match); err != nil {
+		s.logger.Error("failed to write match JSON", "id", id, "error", err)
+		s.metrics.parseFailures.Inc(failureClass(errStorage))
+		s.jobs.Fail(id, fmt.Errorf("%w: %w", errStorage, err))
+		return
+	}
+	s.metrics.parseTicks.Add(math.Round(match.Duration * match.TickRate))
+
+	s.jobs.Complete(id)
+	s.logger.Info("parse complete", "id", id, "map", match.Map, "rounds", len(match.Rounds))
+}
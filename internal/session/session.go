@@ -0,0 +1,248 @@
+// Package session runs watch parties: a group viewing one match together,
+// with a host whose play, pause, seek, round and speed changes everyone
+// else follows.
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Messages queued for a member before it's dropped as too slow.
+const memberBuffer = 16
+
+// MaxSpeed is the fastest playback speed a host can set.
+const MaxSpeed = 16
+
+var (
+	ErrNotFound     = errors.New("session not found")
+	ErrInvalidState = errors.New("invalid playback state")
+)
+
+// State is the host's playback: which round is shown, how far into it, and
+// whether it's playing. Time is the position at UpdatedAt.
+type State struct {
+	Round     int       `json:"round"`
+	Time      float64   `json:"time"`
+	Playing   bool      `json:"playing"`
+	Speed     float64   `json:"speed"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// At returns the state as of t, moving Time forward if it's playing.
+// Members joining between updates start at the host's current position
+// rather than where the host last seeked to.
+func (st State) At(t time.Time) State {
+	if st.Playing && t.After(st.UpdatedAt) {
+		st.Time += t.Sub(st.UpdatedAt).Seconds() * st.Speed
+	}
+	st.UpdatedAt = t
+	return st
+}
+
+func (st State) validate() error {
+	switch {
+	case st.Round < 0:
+		return fmt.Errorf("%w: round must not be negative", ErrInvalidState)
+	case st.Time < 0:
+		return fmt.Errorf("%w: time must not be negative", ErrInvalidState)
+	case st.Speed <= 0 || st.Speed > MaxSpeed:
+		return fmt.Errorf("%w: speed must be above 0 and at most %d", ErrInvalidState, MaxSpeed)
+	}
+	return nil
+}
+
+// Message is sent to session members. Type is "state" when the host's
+// playback changed, or "viewers" when someone joined or left.
+type Message struct {
+	Type    string `json:"type"`
+	State   *State `json:"state,omitempty"`
+	Viewers int    `json:"viewers,omitempty"`
+}
+
+// Session is a watch party for a match. Anyone with the ID can join; only
+// the holder of the host token can change the playback.
+type Session struct {
+	ID        string    `json:"id"`
+	MatchID   string    `json:"matchId"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	hostToken string
+
+	mu         sync.Mutex
+	state      State
+	members    map[chan Message]struct{}
+	lastActive time.Time
+}
+
+// State returns the current playback.
+func (s *Session) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.At(time.Now())
+}
+
+// Viewers returns how many members are connected.
+func (s *Session) Viewers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.members)
+}
+
+// IsHost reports whether token is the session's host token.
+func (s *Session) IsHost(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.hostToken)) == 1
+}
+
+// SetState replaces the playback and sends it to every member.
+func (s *Session) SetState(st State) (State, error) {
+	if err := st.validate(); err != nil {
+		return State{}, err
+	}
+	st.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = st
+	s.lastActive = st.UpdatedAt
+	s.sendLocked(Message{Type: "state", State: &st})
+	return st, nil
+}
+
+// Join adds a member. Its channel starts with the current state and is
+// closed when leave is called or the member falls too far behind.
+func (s *Session) Join() (messages <-chan Message, leave func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	st := s.state.At(now)
+	ch := make(chan Message, memberBuffer)
+	ch <- Message{Type: "state", State: &st}
+	s.members[ch] = struct{}{}
+	s.lastActive = now
+	s.sendLocked(Message{Type: "viewers", Viewers: len(s.members)})
+
+	leave = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.members[ch]; !ok {
+			return
+		}
+		delete(s.members, ch)
+		close(ch)
+		s.lastActive = time.Now()
+		s.sendLocked(Message{Type: "viewers", Viewers: len(s.members)})
+	}
+	return ch, leave
+}
+
+func (s *Session) sendLocked(msg Message) {
+	for ch := range s.members {
+		select {
+		case ch <- msg:
+		default:
+			delete(s.members, ch)
+			close(ch)
+		}
+	}
+}
+
+// Store holds the sessions in memory. A session expires once it has had no
+// members and no state changes for the TTL.
+type Store struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewStore(ttl time.Duration) *Store {
+	return &Store{ttl: ttl, sessions: make(map[string]*Session)}
+}
+
+// Create starts a session for a match, paused at the start of the first
+// round. The host token is returned only here.
+func (s *Store) Create(matchID string) (*Session, string, error) {
+	id, err := newID(8)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := newID(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	sess := &Session{
+		ID:         id,
+		MatchID:    matchID,
+		CreatedAt:  now,
+		hostToken:  token,
+		state:      State{Speed: 1, UpdatedAt: now},
+		members:    make(map[chan Message]struct{}),
+		lastActive: now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = sess
+	return sess, token, nil
+}
+
+func (s *Store) Get(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return sess, nil
+}
+
+// Expire removes the sessions idle for longer than the TTL and returns how
+// many there were.
+func (s *Store) Expire(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, sess := range s.sessions {
+		sess.mu.Lock()
+		idle := len(sess.members) == 0 && now.Sub(sess.lastActive) >= s.ttl
+		sess.mu.Unlock()
+		if idle {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// ExpireEvery runs Expire at the given interval until stop is closed.
+func (s *Store) ExpireEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.Expire(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// newID returns n random bytes hex encoded.
+func newID(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStateAt(t *testing.T) {
+	start := time.Now()
+	st := State{Time: 10, Playing: true, Speed: 2, UpdatedAt: start}
+
+	if got := st.At(start.Add(3 * time.Second)).Time; got != 16 {
+		t.Errorf("playing: time = %v, want 16", got)
+	}
+	st.Playing = false
+	if got := st.At(start.Add(3 * time.Second)).Time; got != 10 {
+		t.Errorf("paused: time = %v, want 10", got)
+	}
+}
+
+func TestHostControlsFollowers(t *testing.T) {
+	s := NewStore(time.Hour)
+	sess, token, err := s.Create("match1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sess.IsHost(token) || sess.IsHost("") || sess.IsHost("nope") {
+		t.Fatal("IsHost doesn't match only the host token")
+	}
+
+	messages, leave := sess.Join()
+	if msg := <-messages; msg.Type != "state" || msg.State.Playing || msg.State.Speed != 1 {
+		t.Fatalf("first message = %+v, want the paused initial state", msg)
+	}
+	if msg := <-messages; msg.Type != "viewers" || msg.Viewers != 1 {
+		t.Fatalf("second message = %+v, want 1 viewer", msg)
+	}
+
+	if _, err := sess.SetState(State{Round: 3, Time: 42, Playing: true, Speed: 2}); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-messages
+	if msg.Type != "state" || msg.State.Round != 3 || msg.State.Time != 42 || !msg.State.Playing {
+		t.Fatalf("after SetState: %+v", msg)
+	}
+
+	for _, bad := range []State{{Round: -1, Speed: 1}, {Time: -1, Speed: 1}, {Speed: 0}, {Speed: MaxSpeed + 1}} {
+		if _, err := sess.SetState(bad); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("SetState(%+v): err = %v, want ErrInvalidState", bad, err)
+		}
+	}
+
+	leave()
+	if _, ok := <-messages; ok {
+		t.Fatal("channel still open after leave")
+	}
+	if sess.Viewers() != 0 {
+		t.Fatalf("viewers = %d after leave", sess.Viewers())
+	}
+}
+
+func TestExpireKeepsSessionsWithMembers(t *testing.T) {
+	s := NewStore(time.Minute)
+	idle, _, _ := s.Create("a")
+	watched, _, _ := s.Create("b")
+	_, leave := watched.Join()
+	defer leave()
+
+	if n := s.Expire(time.Now().Add(time.Hour)); n != 1 {
+		t.Fatalf("expired %d sessions, want 1", n)
+	}
+	if _, err := s.Get(idle.ID); err != ErrNotFound {
+		t.Errorf("idle session: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get(watched.ID); err != nil {
+		t.Errorf("watched session: err = %v", err)
+	}
+}
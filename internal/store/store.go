@@ -0,0 +1,419 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allending313/cs2-demo-parser/internal/codec"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+const indexFile = "index.json"
+
+// Match files are stored in the binary format, gzip compressed. Files in
+// the older formats are converted when the store is opened.
+var extensions = []string{codec.Extension + ".gz", codec.Extension, ".json"}
+
+// ErrNotFound is returned when a match is not present in the store.
+var ErrNotFound = errors.New("match not found")
+
+// IndexEntry is the lightweight summary kept for every stored match so that
+// cross-match queries don't have to load every match file from disk.
+type IndexEntry struct {
+	ID       string       `json:"id"`
+	Map      string       `json:"map"`
+	ParsedAt time.Time    `json:"parsedAt"`
+	Rounds   int          `json:"rounds"`
+	CTScore  int          `json:"ctScore"`
+	TScore   int          `json:"tScore"`
+	Teams    models.Teams `json:"teams"`
+
+	Workspace string `json:"workspace,omitempty"`
+
+	// Size of the stored file in bytes.
+	Size int64 `json:"size"`
+}
+
+// HasPlayer reports whether the given SteamID appears in the match roster.
+func (e *IndexEntry) HasPlayer(steamID uint64) bool {
+	for _, team := range []models.TeamInfo{e.Teams.CT, e.Teams.T} {
+		for _, p := range team.Players {
+			if p.SteamID == steamID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchStore persists parsed matches as files in a directory and maintains
+// an index of them alongside.
+type MatchStore struct {
+	dir string
+
+	mu    sync.RWMutex
+	index map[string]*IndexEntry
+}
+
+// NewMatchStore opens the store rooted at dir, loading the persisted index
+// and reconciling it with the match files actually present.
+func NewMatchStore(dir string) (*MatchStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating match dir: %w", err)
+	}
+
+	s := &MatchStore{dir: dir, index: make(map[string]*IndexEntry)}
+	if err := s.loadIndex(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MatchStore) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// Save writes the match to disk and records it in the index.
+func (s *MatchStore) Save(match *models.Match) error {
+	if match.ParsedAt.IsZero() {
+		match.ParsedAt = time.Now().UTC()
+	}
+
+	size, err := s.writeMatch(match)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := newIndexEntry(match)
+	e.Size = size
+	s.index[match.ID] = e
+	return s.persistIndexLocked()
+}
+
+// writeMatch stores the match in the current format, removes any copies
+// in older formats and returns the size of the file written.
+func (s *MatchStore) writeMatch(match *models.Match) (int64, error) {
+	data, err := codec.Marshal(match)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := writeFile(s.path(match.ID, extensions[0]), buf.Bytes()); err != nil {
+		return 0, err
+	}
+	for _, ext := range extensions[1:] {
+		os.Remove(s.path(match.ID, ext))
+	}
+	return int64(buf.Len()), nil
+}
+
+// Open returns the stored file for a match, which holds its binary encoding
+// gzip compressed. The caller must close it.
+func (s *MatchStore) Open(id string) (*os.File, error) {
+	f, err := os.Open(s.path(id, extensions[0]))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening match %s: %w", id, err)
+	}
+	return f, nil
+}
+
+// Load reads a full match from disk.
+func (s *MatchStore) Load(id string) (*models.Match, error) {
+	data, err := s.readFile(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if codec.IsBinary(data) {
+		match, err := codec.Unmarshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding match %s: %w", id, err)
+		}
+		return match, nil
+	}
+
+	var match models.Match
+	if err := json.Unmarshal(data, &match); err != nil {
+		return nil, fmt.Errorf("decoding match %s: %w", id, err)
+	}
+	return &match, nil
+}
+
+// readFile returns the uncompressed contents of the newest file stored for
+// id.
+func (s *MatchStore) readFile(id string) ([]byte, error) {
+	for i, ext := range extensions {
+		data, err := os.ReadFile(s.path(id, ext))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading match %s: %w", id, err)
+		}
+		if i > 0 {
+			return data, nil
+		}
+
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("reading match %s: %w", id, err)
+		}
+		if data, err = io.ReadAll(gz); err != nil {
+			return nil, fmt.Errorf("reading match %s: %w", id, err)
+		}
+		return data, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Entry returns the index entry for a match.
+func (s *MatchStore) Entry(id string) (IndexEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.index[id]
+	if !ok {
+		return IndexEntry{}, false
+	}
+	return *e, true
+}
+
+// Entries returns the index sorted by parse time, oldest first.
+func (s *MatchStore) Entries() []IndexEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]IndexEntry, 0, len(s.index))
+	for _, e := range s.index {
+		entries = append(entries, *e)
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// EntriesForPlayer returns the matches a player appears in, oldest first.
+func (s *MatchStore) EntriesForPlayer(steamID uint64) []IndexEntry {
+	return s.EntriesWhere(func(e *IndexEntry) bool {
+		return e.HasPlayer(steamID)
+	})
+}
+
+// EntriesWhere returns the index entries accepted by keep, oldest first.
+func (s *MatchStore) EntriesWhere(keep func(*IndexEntry) bool) []IndexEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []IndexEntry
+	for _, e := range s.index {
+		if keep(e) {
+			entries = append(entries, *e)
+		}
+	}
+	sortEntries(entries)
+	return entries
+}
+
+// LoadAll reads the matches with the given IDs, in order.
+func (s *MatchStore) LoadAll(ids []string) ([]*models.Match, error) {
+	matches := make([]*models.Match, 0, len(ids))
+	for _, id := range ids {
+		match, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		if match.ParsedAt.IsZero() {
+			s.mu.RLock()
+			if e, ok := s.index[id]; ok {
+				match.ParsedAt = e.ParsedAt
+			}
+			s.mu.RUnlock()
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
+
+func (s *MatchStore) loadIndex() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, indexFile))
+	switch {
+	case err == nil:
+		// The index is only a cache of the match files; if it can't be
+		// decoded, reconcile below rebuilds it from scratch.
+		var entries []*IndexEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			slog.Warn("rebuilding unreadable match index", "dir", s.dir, "error", err)
+			break
+		}
+		for _, e := range entries {
+			s.index[e.ID] = e
+		}
+	case errors.Is(err, os.ErrNotExist):
+	default:
+		return fmt.Errorf("reading match index: %w", err)
+	}
+
+	return s.reconcile()
+}
+
+// reconcile drops index entries whose file has disappeared, converts match
+// files in older formats to the current one, and indexes any match file the
+// index doesn't know about yet (e.g. matches written before the index
+// existed).
+func (s *MatchStore) reconcile() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("reading match dir: %w", err)
+	}
+
+	// For each match, the position in extensions of its newest file.
+	formats := make(map[string]int)
+	modTimes := make(map[string]time.Time)
+	sizes := make(map[string]int64)
+	for _, f := range files {
+		if f.IsDir() || f.Name() == indexFile {
+			continue
+		}
+		for i, ext := range extensions {
+			id, ok := strings.CutSuffix(f.Name(), ext)
+			if !ok {
+				continue
+			}
+			if cur, seen := formats[id]; !seen || i < cur {
+				formats[id] = i
+				if info, err := f.Info(); err == nil {
+					modTimes[id] = info.ModTime().UTC()
+					sizes[id] = info.Size()
+				}
+			}
+			break
+		}
+	}
+
+	changed := false
+	for id, format := range formats {
+		entry, indexed := s.index[id]
+		if indexed && format == 0 {
+			// Indexes written before sizes were recorded lack them.
+			if entry.Size != sizes[id] {
+				entry.Size = sizes[id]
+				changed = true
+			}
+			continue
+		}
+
+		match, err := s.Load(id)
+		if err != nil {
+			// A corrupt file shouldn't prevent the server from starting.
+			continue
+		}
+		if indexed {
+			match.ParsedAt = entry.ParsedAt
+		} else if match.ParsedAt.IsZero() {
+			match.ParsedAt = modTimes[id]
+		}
+
+		size := sizes[id]
+		if format > 0 {
+			if size, err = s.writeMatch(match); err != nil {
+				return fmt.Errorf("converting match %s: %w", id, err)
+			}
+		}
+		if !indexed {
+			entry = newIndexEntry(match)
+			s.index[id] = entry
+			changed = true
+		}
+		if entry.Size != size {
+			entry.Size = size
+			changed = true
+		}
+	}
+
+	for id := range s.index {
+		if _, ok := formats[id]; !ok {
+			delete(s.index, id)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return s.persistIndexLocked()
+}
+
+func (s *MatchStore) persistIndexLocked() error {
+	entries := make([]IndexEntry, 0, len(s.index))
+	for _, e := range s.index {
+		entries = append(entries, *e)
+	}
+	sortEntries(entries)
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.dir, indexFile), data)
+}
+
+func newIndexEntry(match *models.Match) *IndexEntry {
+	e := &IndexEntry{
+		ID:       match.ID,
+		Map:      match.Map,
+		ParsedAt: match.ParsedAt,
+		Rounds:   len(match.Rounds),
+		Teams:    match.Teams,
+
+		Workspace: match.Workspace,
+	}
+
+	if n := len(match.Rounds); n > 0 {
+		e.CTScore = match.Rounds[n-1].EndCTScore
+		e.TScore = match.Rounds[n-1].EndTScore
+	}
+	return e
+}
+
+func sortEntries(entries []IndexEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].ParsedAt.Equal(entries[j].ParsedAt) {
+			return entries[i].ParsedAt.Before(entries[j].ParsedAt)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+}
+
+// writeFile writes data to path via a temporary file so readers never see a
+// partially written file.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
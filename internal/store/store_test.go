@@ -0,0 +1,98 @@
+package store
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/allending313/cs2-demo-parser/internal/codec"
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+func TestLegacyMatchesAreConverted(t *testing.T) {
+	dir := t.TempDir()
+	legacy := &models.Match{ID: "old", Map: "de_inferno", Rounds: []models.Round{{Number: 1, EndCTScore: 1}}}
+	data, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "old.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewMatchStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); !os.IsNotExist(err) {
+		t.Errorf("legacy file still present: %v", err)
+	}
+	if entries := s.Entries(); len(entries) != 1 || entries[0].CTScore != 1 || entries[0].ParsedAt.IsZero() {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	f, err := s.Open("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !codec.IsBinary(raw) {
+		t.Error("stored file is not the binary format")
+	}
+
+	match, err := s.Load("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match.Map != "de_inferno" {
+		t.Errorf("map = %q", match.Map)
+	}
+}
+
+func TestSaveReplacesOlderFormats(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewMatchStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "m.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Save(&models.Match{ID: "m", Map: "de_nuke"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "m.json")); !os.IsNotExist(err) {
+		t.Errorf("legacy file still present: %v", err)
+	}
+	if _, err := s.Open("missing"); err != ErrNotFound {
+		t.Errorf("Open(missing) error = %v", err)
+	}
+
+	reopened, err := NewMatchStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := reopened.Entries()
+	if len(entries) != 1 || entries[0].Map != "de_nuke" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	info, err := os.Stat(filepath.Join(dir, "m"+extensions[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Size != info.Size() {
+		t.Errorf("size = %d, want %d", entries[0].Size, info.Size())
+	}
+}
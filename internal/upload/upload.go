@@ -0,0 +1,299 @@
+// Package upload keeps partially received demo uploads on disk so clients
+// on unreliable connections can resume them instead of starting over.
+package upload
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+const (
+	dataExtension = ".upload"
+	metaExtension = ".upload.json"
+)
+
+var (
+	ErrNotFound       = errors.New("upload not found")
+	ErrOffsetMismatch = errors.New("offset does not match the received size")
+	ErrBusy           = errors.New("upload already receiving data")
+	ErrTooLarge       = errors.New("more data than the declared size")
+	ErrIncomplete     = errors.New("upload is not complete")
+)
+
+// Upload is a demo upload in progress. Offset is how many bytes have been
+// received; the next chunk must start there.
+type Upload struct {
+	ID        string              `json:"id"`
+	Size      int64               `json:"size"`
+	Offset    int64               `json:"offset"`
+	Options   models.ParseOptions `json:"options"`
+	ExpiresAt time.Time           `json:"expiresAt"`
+
+	// The workspace the demo is uploaded for.
+	Workspace string `json:"workspace,omitempty"`
+
+	busy bool
+}
+
+// Store tracks uploads in a directory. Each upload has a data file, which
+// grows as chunks arrive, and a metadata file written when it's created.
+// An upload expires once nothing has been written to it for the TTL.
+type Store struct {
+	dir string
+	ttl time.Duration
+
+	mu      sync.Mutex
+	uploads map[string]*Upload
+}
+
+// NewStore opens the store in dir, picking up uploads left by a previous
+// run. Their offsets are taken from the data files, so bytes written just
+// before a crash are kept.
+func NewStore(dir string, ttl time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating upload dir: %w", err)
+	}
+
+	s := &Store{dir: dir, ttl: ttl, uploads: make(map[string]*Upload)}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading upload dir: %w", err)
+	}
+	for _, f := range files {
+		id, ok := strings.CutSuffix(f.Name(), metaExtension)
+		if !ok {
+			continue
+		}
+		u, err := s.loadUpload(id)
+		if err != nil {
+			s.remove(id)
+			continue
+		}
+		s.uploads[id] = u
+	}
+	return s, nil
+}
+
+func (s *Store) loadUpload(id string) (*Upload, error) {
+	data, err := os.ReadFile(s.path(id, metaExtension))
+	if err != nil {
+		return nil, err
+	}
+	var u Upload
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(s.path(id, dataExtension))
+	if err != nil {
+		return nil, err
+	}
+	u.Offset = info.Size()
+	u.ExpiresAt = info.ModTime().Add(s.ttl)
+	return &u, nil
+}
+
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// Create starts an upload of size bytes for workspace, to be parsed with
+// opts.
+func (s *Store) Create(size int64, opts models.ParseOptions, workspace string) (Upload, error) {
+	id, err := newID()
+	if err != nil {
+		return Upload{}, err
+	}
+	u := &Upload{ID: id, Size: size, Options: opts, ExpiresAt: time.Now().Add(s.ttl), Workspace: workspace}
+
+	meta, err := json.Marshal(u)
+	if err != nil {
+		return Upload{}, err
+	}
+	if err := os.WriteFile(s.path(id, dataExtension), nil, 0644); err != nil {
+		return Upload{}, fmt.Errorf("creating upload: %w", err)
+	}
+	if err := os.WriteFile(s.path(id, metaExtension), meta, 0644); err != nil {
+		s.remove(id)
+		return Upload{}, fmt.Errorf("creating upload: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads[id] = u
+	return *u, nil
+}
+
+// Get returns the upload with the given id.
+func (s *Store) Get(id string) (Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return Upload{}, ErrNotFound
+	}
+	return *u, nil
+}
+
+// Append writes a chunk read from r, which must start at offset. Whatever
+// arrives before r fails is kept, so the client can resume from the
+// returned upload's offset.
+func (s *Store) Append(id string, offset int64, r io.Reader) (Upload, error) {
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	switch {
+	case !ok:
+		s.mu.Unlock()
+		return Upload{}, ErrNotFound
+	case u.busy:
+		s.mu.Unlock()
+		return *u, ErrBusy
+	case offset != u.Offset:
+		s.mu.Unlock()
+		return *u, ErrOffsetMismatch
+	}
+	u.busy = true
+	remaining := u.Size - u.Offset
+	s.mu.Unlock()
+
+	written, err := s.write(id, io.LimitReader(r, remaining))
+	if err == nil {
+		// Anything beyond the declared size means the client and server
+		// disagree about the file.
+		if n, _ := r.Read(make([]byte, 1)); n > 0 {
+			err = ErrTooLarge
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u.busy = false
+	u.Offset += written
+	u.ExpiresAt = time.Now().Add(s.ttl)
+	return *u, err
+}
+
+func (s *Store) write(id string, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(s.path(id, dataExtension), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// Finish ends a complete upload and returns it with the path of its data.
+// The upload is forgotten; the caller owns the file from then on.
+func (s *Store) Finish(id string) (Upload, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	switch {
+	case !ok:
+		return Upload{}, "", ErrNotFound
+	case u.busy:
+		return *u, "", ErrBusy
+	case u.Offset != u.Size:
+		return *u, "", ErrIncomplete
+	}
+
+	delete(s.uploads, id)
+	os.Remove(s.path(id, metaExtension))
+	return *u, s.path(id, dataExtension), nil
+}
+
+// Delete abandons an upload and removes its data.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if u.busy {
+		return ErrBusy
+	}
+	delete(s.uploads, id)
+	s.remove(id)
+	return nil
+}
+
+// Reserved returns the declared size of workspace's uploads, the space
+// they will take once complete.
+func (s *Store) Reserved(workspace string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total int64
+	for _, u := range s.uploads {
+		if u.Workspace == workspace {
+			total += u.Size
+		}
+	}
+	return total
+}
+
+// Expire removes uploads that have seen no data since before now minus
+// the TTL, and returns how many it removed.
+func (s *Store) Expire(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, u := range s.uploads {
+		if u.busy || now.Before(u.ExpiresAt) {
+			continue
+		}
+		delete(s.uploads, id)
+		s.remove(id)
+		removed++
+	}
+	return removed
+}
+
+// ExpireEvery runs Expire at the given interval until stop is closed.
+func (s *Store) ExpireEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.Expire(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *Store) remove(id string) {
+	os.Remove(s.path(id, dataExtension))
+	os.Remove(s.path(id, metaExtension))
+}
+
+// newID returns an ID long enough that uploads can't be guessed, since
+// anyone with the ID can write to the upload.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,101 @@
+package upload
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	models "github.com/allending313/cs2-demo-parser/internal/model"
+)
+
+// failingReader returns data and then an error, like a dropped connection.
+type failingReader struct{ r io.Reader }
+
+func (f *failingReader) Read(b []byte) (int, error) {
+	n, err := f.r.Read(b)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestResumeAfterFailedChunk(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := s.Create(10, models.DefaultParseOptions(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err = s.Append(u.ID, 0, &failingReader{strings.NewReader("hello")})
+	if err == nil || u.Offset != 5 {
+		t.Fatalf("offset = %d, err = %v; want 5 and an error", u.Offset, err)
+	}
+	if _, err := s.Append(u.ID, 0, strings.NewReader("hello")); err != ErrOffsetMismatch {
+		t.Fatalf("append at stale offset: err = %v", err)
+	}
+	if _, _, err := s.Finish(u.ID); err != ErrIncomplete {
+		t.Fatalf("finish incomplete: err = %v", err)
+	}
+
+	// A restart picks the upload back up at the same offset.
+	s, err = NewStore(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get(u.ID); err != nil || got.Offset != 5 || got.Size != 10 {
+		t.Fatalf("after reopen: %+v, %v", got, err)
+	}
+
+	if _, err := s.Append(u.ID, 5, strings.NewReader("world!")); err != ErrTooLarge {
+		t.Fatalf("oversized chunk: err = %v", err)
+	}
+	_, path, err := s.Finish(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "helloworld" {
+		t.Errorf("data = %q", data)
+	}
+	if _, err := s.Get(u.ID); err != ErrNotFound {
+		t.Errorf("finished upload still listed: %v", err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	s, err := NewStore(t.TempDir(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := s.Create(10, models.DefaultParseOptions(), "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Reserved("team"); n != 10 {
+		t.Fatalf("reserved %d bytes, want 10", n)
+	}
+
+	if n := s.Expire(time.Now()); n != 0 {
+		t.Fatalf("expired %d fresh uploads", n)
+	}
+	if n := s.Expire(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Fatalf("expired %d, want 1", n)
+	}
+	if _, err := s.Get(u.ID); err != ErrNotFound {
+		t.Errorf("expired upload still listed: %v", err)
+	}
+	if n := s.Reserved("team"); n != 0 {
+		t.Errorf("expired upload still reserves %d bytes", n)
+	}
+}
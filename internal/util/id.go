@@ -5,8 +5,10 @@ import (
 	"encoding/hex"
 )
 
+// GenerateID returns a random ID for a match. It is long enough that
+// matches can't be found by trying IDs.
 func GenerateID() string {
-	b := make([]byte, 2)
+	b := make([]byte, 8)
 	rand.Read(b)
 	return hex.EncodeToString(b)
 }
@@ -0,0 +1,15 @@
+package util
+
+import "strings"
+
+// SplitList splits a comma-separated list, as taken by query parameters
+// and flags, dropping blank entries.
+func SplitList(s string) []string {
+	var out []string
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			out = append(out, field)
+		}
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// dial performs the client side of the handshake by hand.
+func dial(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\n"+
+		"Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", res.StatusCode)
+	}
+	// The example from RFC 6455 section 1.3.
+	if got := res.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("accept = %q", got)
+	}
+	return conn, br
+}
+
+// writeClientFrame sends a masked frame, as browsers do.
+func writeClientFrame(conn net.Conn, op byte, payload []byte) {
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | op, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	conn.Write(frame)
+}
+
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(head[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+func TestEcho(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			c.WriteText(msg)
+		}
+	}))
+	defer srv.Close()
+
+	conn, br := dial(t, srv.URL)
+
+	writeClientFrame(conn, opPing, []byte("hi"))
+	if op, payload := readServerFrame(t, br); op != opPong || string(payload) != "hi" {
+		t.Fatalf("got op %#x %q, want pong", op, payload)
+	}
+
+	writeClientFrame(conn, opText, []byte("hello"))
+	if op, payload := readServerFrame(t, br); op != opText || string(payload) != "hello" {
+		t.Fatalf("got op %#x %q", op, payload)
+	}
+
+	// A message split over two frames arrives whole.
+	mask := [4]byte{9, 9, 9, 9}
+	first := append([]byte{opText, 0x80 | 2}, mask[:]...)
+	first = append(first, 'a'^9, 'b'^9)
+	conn.Write(first)
+	writeClientFrame(conn, opContinuation, []byte("c"))
+	if _, payload := readServerFrame(t, br); string(payload) != "abc" {
+		t.Fatalf("fragmented message = %q", payload)
+	}
+
+	writeClientFrame(conn, opClose, nil)
+	if op, _ := readServerFrame(t, br); op != opClose {
+		t.Fatalf("got op %#x, want close", op)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if _, err := Upgrade(rec, httptest.NewRequest("GET", "/", nil)); err != ErrNotWebSocket {
+		t.Fatalf("err = %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d", rec.Code)
+	}
+}
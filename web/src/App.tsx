@@ -1,12 +1,16 @@
 import { Route, Switch } from "wouter";
 import DemoUpload from "./components/DemoUpload";
 import MatchPage from "./components/MatchPage";
+import LivePage from "./components/LivePage";
+import SessionPage from "./components/SessionPage";
 import "./index.css";
 
 export default function App() {
   return (
     <Switch>
       <Route path="/match/:id" component={MatchPage} />
+      <Route path="/live/:id" component={LivePage} />
+      <Route path="/session/:id" component={SessionPage} />
       <Route path="/" component={DemoUpload} />
     </Switch>
   );
@@ -1,79 +1,145 @@
-import { useState, useCallback, useRef } from "react";
+import { useState, useCallback, useEffect, useRef } from "react";
 import { useLocation } from "wouter";
+import { resumableUpload, type Job } from "../utils/resumableUpload";
+import { watchJob, type ParseJob } from "../utils/jobEvents";
+import { apiFetch, authRequired, getApiToken, setApiToken } from "../utils/api";
+
+// Snapshot rates offered in the upload form; "tick" records every tick.
+const SNAPSHOT_RATES = ["5", "10", "16", "32", "tick"];
+
+// Demos may also be uploaded compressed or as a zip of several demos; the
+// server detects the format from the file contents.
+const ACCEPTED = [".dem", ".gz", ".bz2", ".zst", ".zip"];
+
+// Files this large are sent in resumable chunks, so a dropped connection
+// doesn't restart the upload. Smaller ones are parsed while they upload.
+const RESUMABLE_THRESHOLD = 200 << 20;
 
 type UploadState =
   | { step: "idle" }
-  | { step: "parsing"; id: string; progress: number }
+  | { step: "uploading"; progress: number }
+  | { step: "queued"; jobs: Job[] }
+  | { step: "parsing"; id: string; progress: number; roundsReady: number }
   | { step: "error"; message: string };
 
 export default function DemoUpload() {
   const [state, setState] = useState<UploadState>({ step: "idle" });
   const [dragging, setDragging] = useState(false);
+  const [snapshotRate, setSnapshotRate] = useState("5");
+  const [broadcastUrl, setBroadcastUrl] = useState("");
+  const [needsToken, setNeedsToken] = useState(false);
+  const [token, setToken] = useState(() => getApiToken() ?? "");
   const fileInputRef = useRef<HTMLInputElement>(null);
   const [, navigate] = useLocation();
 
   const uploadFile = useCallback(
     async (file: File) => {
-      if (!file.name.endsWith(".dem")) {
-        setState({ step: "error", message: "File must be a .dem file" });
+      if (!ACCEPTED.some((ext) => file.name.endsWith(ext))) {
+        setState({
+          step: "error",
+          message: "File must be a .dem file, or a .gz, .bz2, .zst or .zip of demos",
+        });
         return;
       }
 
-      const form = new FormData();
-      form.append("demo", file);
+      let body: Job | { jobs: Job[] };
+      if (file.size >= RESUMABLE_THRESHOLD) {
+        setState({ step: "uploading", progress: 0 });
+        try {
+          body = await resumableUpload(file, { snapshotRate }, (progress) =>
+            setState({ step: "uploading", progress })
+          );
+        } catch (err) {
+          setState({
+            step: "error",
+            message: err instanceof Error ? err.message : "Upload failed",
+          });
+          return;
+        }
+      } else {
+        const form = new FormData();
+        // Options must come before the demo: the server parses the demo
+        // while it is still being uploaded.
+        form.append("snapshotRate", snapshotRate);
+        form.append("demo", file);
 
-      setState({ step: "parsing", id: "", progress: 0 });
+        setState({ step: "parsing", id: "", progress: 0, roundsReady: 0 });
 
-      let res: Response;
-      try {
-        res = await fetch("/api/parse", { method: "POST", body: form });
-      } catch {
-        setState({ step: "error", message: "Failed to connect to server" });
-        return;
+        let res: Response;
+        try {
+          res = await apiFetch("/api/parse", { method: "POST", body: form });
+        } catch {
+          setState({ step: "error", message: "Failed to connect to server" });
+          return;
+        }
+
+        if (!res.ok) {
+          const error = await res.json().catch(() => null);
+          setState({
+            step: "error",
+            message: error?.error ?? `Upload failed (${res.status})`,
+          });
+          return;
+        }
+        body = await res.json();
       }
 
-      if (!res.ok) {
-        const body = await res.json().catch(() => null);
-        setState({
-          step: "error",
-          message: body?.error ?? `Upload failed (${res.status})`,
-        });
+      if ("jobs" in body && body.jobs.length > 1) {
+        setState({ step: "queued", jobs: body.jobs });
         return;
       }
+      const job = "jobs" in body ? body.jobs[0] : body;
+      setState({ step: "parsing", id: job.id, progress: 0, roundsReady: 0 });
 
-      const job: { id: string } = await res.json();
-      setState({ step: "parsing", id: job.id, progress: 0 });
-
-      const pollInterval = setInterval(async () => {
-        try {
-          const statusRes = await fetch(`/api/match/${job.id}/status`);
-          const status = await statusRes.json();
-
+      watchJob(
+        job.id,
+        (status) => {
           if (status.status === "ready") {
-            clearInterval(pollInterval);
             navigate(`/match/${job.id}`);
           } else if (status.status === "error") {
-            clearInterval(pollInterval);
-            setState({
-              step: "error",
-              message: status.error || "Parse failed",
-            });
+            setState({ step: "error", message: status.error || "Parse failed" });
           } else {
             setState({
               step: "parsing",
               id: job.id,
-              progress: status.progress ?? 0,
+              progress: status.progress,
+              roundsReady: status.roundsReady,
             });
           }
-        } catch {
-          clearInterval(pollInterval);
-          setState({ step: "error", message: "Lost connection to server" });
-        }
-      }, 500);
+        },
+        () => setState({ step: "error", message: "Lost connection to server" })
+      );
     },
-    [navigate]
+    [navigate, snapshotRate]
   );
 
+  const watchBroadcast = useCallback(
+    async (e: React.FormEvent) => {
+      e.preventDefault();
+      let res: Response;
+      try {
+        res = await apiFetch("/api/live", {
+          method: "POST",
+          body: new URLSearchParams({ url: broadcastUrl, snapshotRate }),
+        });
+      } catch {
+        setState({ step: "error", message: "Failed to connect to server" });
+        return;
+      }
+      const body = await res.json().catch(() => null);
+      if (!res.ok) {
+        setState({ step: "error", message: body?.error ?? `Failed to start (${res.status})` });
+        return;
+      }
+      navigate(`/live/${body.id}`);
+    },
+    [broadcastUrl, snapshotRate, navigate]
+  );
+
+  useEffect(() => {
+    authRequired().then(setNeedsToken);
+  }, []);
+
   const handleDrop = useCallback(
     (e: React.DragEvent) => {
       e.preventDefault();
@@ -95,9 +161,11 @@ export default function DemoUpload() {
   return (
     <div className="flex h-screen items-center justify-center bg-bg text-text-primary">
       <div className="w-full max-w-md px-4">
-        {state.step === "parsing" ? (
+        {state.step === "parsing" || state.step === "uploading" ? (
           <div className="text-center">
-            <h2 className="mb-4 text-lg font-semibold">Parsing demo...</h2>
+            <h2 className="mb-4 text-lg font-semibold">
+              {state.step === "uploading" ? "Uploading demo..." : "Parsing demo..."}
+            </h2>
             <div className="mx-auto h-2 w-64 overflow-hidden rounded-full bg-surface">
               <div
                 className="h-full rounded-full bg-ct transition-all duration-300"
@@ -107,6 +175,25 @@ export default function DemoUpload() {
             <p className="mt-2 text-sm text-text-muted">
               {Math.round(state.progress * 100)}%
             </p>
+            {state.step === "parsing" && state.roundsReady > 0 && (
+              <a
+                href={`/match/${state.id}`}
+                className="mt-4 inline-block text-sm text-ct hover:underline"
+              >
+                Start watching ({state.roundsReady} {state.roundsReady === 1 ? "round" : "rounds"} ready)
+              </a>
+            )}
+          </div>
+        ) : state.step === "queued" ? (
+          <div className="text-center">
+            <h2 className="mb-4 text-lg font-semibold">
+              Parsing {state.jobs.length} demos...
+            </h2>
+            <ul className="space-y-1 text-sm">
+              {state.jobs.map((job) => (
+                <QueuedJob key={job.id} id={job.id} />
+              ))}
+            </ul>
           </div>
         ) : (
           <>
@@ -129,19 +216,90 @@ export default function DemoUpload() {
               onDrop={handleDrop}
               onClick={() => fileInputRef.current?.click()}
             >
-              <p className="text-lg font-semibold">Drop a .dem file here</p>
+              <p className="text-lg font-semibold">Drop a demo here</p>
               <p className="mt-1 text-sm text-text-muted">or click to browse</p>
               <input
                 ref={fileInputRef}
                 type="file"
-                accept=".dem"
+                accept={ACCEPTED.join(",")}
                 className="hidden"
                 onChange={handleFileChange}
               />
             </div>
+            <label className="mt-3 flex items-center justify-center gap-2 text-sm text-text-muted">
+              Snapshots per second
+              <select
+                className="rounded border border-border bg-surface px-2 py-1 text-text-primary"
+                value={snapshotRate}
+                onChange={(e) => setSnapshotRate(e.target.value)}
+              >
+                {SNAPSHOT_RATES.map((rate) => (
+                  <option key={rate} value={rate}>
+                    {rate === "tick" ? "Every tick" : rate}
+                  </option>
+                ))}
+              </select>
+            </label>
+            {needsToken && (
+              <label className="mt-3 flex items-center justify-center gap-2 text-sm text-text-muted">
+                Workspace token
+                <input
+                  type="password"
+                  className="min-w-0 flex-1 rounded border border-border bg-surface px-2 py-1 text-text-primary"
+                  value={token}
+                  onChange={(e) => {
+                    setToken(e.target.value);
+                    setApiToken(e.target.value.trim() || null);
+                  }}
+                />
+              </label>
+            )}
+            <form className="mt-6 flex gap-2" onSubmit={watchBroadcast}>
+              <input
+                type="url"
+                required
+                placeholder="Broadcast URL, e.g. http://localhost:8080/s123t456"
+                className="min-w-0 flex-1 rounded border border-border bg-surface px-2 py-1 text-sm text-text-primary"
+                value={broadcastUrl}
+                onChange={(e) => setBroadcastUrl(e.target.value)}
+              />
+              <button
+                type="submit"
+                className="rounded border border-border px-3 py-1 text-sm hover:border-text-muted"
+              >
+                Watch live
+              </button>
+            </form>
           </>
         )}
       </div>
     </div>
   );
 }
+
+// One demo of a multi-demo upload, following its job until it's parsed.
+function QueuedJob({ id }: { id: string }) {
+  const [job, setJob] = useState<ParseJob | null>(null);
+  const [lost, setLost] = useState(false);
+
+  useEffect(() => watchJob(id, setJob, () => setLost(true)), [id]);
+
+  let status = "waiting";
+  if (lost) status = "connection lost";
+  else if (job?.status === "error") status = job.error || "parse failed";
+  else if (job?.status === "ready") status = "ready";
+  else if (job && job.progress > 0) status = `${Math.round(job.progress * 100)}%`;
+
+  return (
+    <li>
+      {job?.status === "ready" ? (
+        <a href={`/match/${id}`} className="text-ct hover:underline">
+          Match {id}
+        </a>
+      ) : (
+        <span>Match {id}</span>
+      )}
+      <span className="ml-2 text-text-muted">{status}</span>
+    </li>
+  );
+}
@@ -0,0 +1,85 @@
+import { useState, useEffect } from "react";
+import { useLocation } from "wouter";
+import type { MatchData } from "../types/match";
+import { applyLiveMessage, type LiveMessage } from "../utils/liveMatch";
+import { withToken } from "../utils/api";
+import MatchViewer from "./MatchViewer";
+
+function radarUrl(mapName: string): string {
+  return `/api/maps/${mapName}/radar.png`;
+}
+
+function socketUrl(id: string): string {
+  const scheme = window.location.protocol === "https:" ? "wss" : "ws";
+  return withToken(`${scheme}://${window.location.host}/api/live/${id}/ws`);
+}
+
+type State =
+  | { step: "connecting" }
+  | { step: "live"; match: MatchData }
+  | { step: "error"; message: string };
+
+// Follows a broadcast being ingested by the server. Once it ends the
+// stored match takes over.
+export default function LivePage({ params }: { params: { id: string } }) {
+  const [state, setState] = useState<State>({ step: "connecting" });
+  const [, navigate] = useLocation();
+
+  useEffect(() => {
+    const socket = new WebSocket(socketUrl(params.id));
+    let match: MatchData | null = null;
+    let ended = false;
+
+    socket.onmessage = (e) => {
+      const msg: LiveMessage = JSON.parse(e.data);
+      if (msg.type === "end") {
+        ended = true;
+        if (msg.data.status === "ready") navigate(`/match/${params.id}`);
+        else setState({ step: "error", message: msg.data.error || "Broadcast failed" });
+        return;
+      }
+      match = applyLiveMessage(match, msg);
+      if (match) setState({ step: "live", match });
+    };
+    socket.onclose = () => {
+      if (!ended) setState({ step: "error", message: "Lost connection to the broadcast" });
+    };
+
+    return () => {
+      ended = true;
+      socket.close();
+    };
+  }, [params.id, navigate]);
+
+  if (state.step === "connecting") {
+    return (
+      <div className="flex h-screen items-center justify-center bg-bg text-text-primary">
+        <p className="text-lg">Connecting to broadcast...</p>
+      </div>
+    );
+  }
+
+  if (state.step === "error") {
+    return (
+      <div className="flex h-screen items-center justify-center bg-bg text-text-primary">
+        <div className="text-center">
+          <p className="text-lg text-t">{state.message}</p>
+          <a href="/" className="mt-4 inline-block text-sm text-text-muted hover:text-text-primary">
+            Back
+          </a>
+        </div>
+      </div>
+    );
+  }
+
+  const rounds = state.match.rounds;
+  return (
+    <>
+      <div className="fixed left-1/2 top-2 z-10 -translate-x-1/2 rounded bg-surface px-3 py-1 text-xs text-text-muted">
+        <span className="mr-2 font-semibold text-t">LIVE</span>
+        {rounds.length > 0 ? `Round ${rounds[rounds.length - 1]!.number}` : "Waiting for the round to start"}
+      </div>
+      <MatchViewer match={state.match} radarImageUrl={radarUrl(state.match.map)} />
+    </>
+  );
+}
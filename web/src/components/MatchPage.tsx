@@ -1,6 +1,11 @@
 import { useState, useEffect } from "react";
+import { useLocation } from "wouter";
 import type { MatchData } from "../types/match";
-import MatchViewer from "./MatchViewer";
+import { decodeMatch, MATCH_CONTENT_TYPE } from "../utils/matchCodec";
+import { watchJob } from "../utils/jobEvents";
+import { apiFetch, getApiToken } from "../utils/api";
+import { createSession } from "../utils/watchSession";
+import MatchViewer, { type WatchParty } from "./MatchViewer";
 
 function radarUrl(mapName: string): string {
   return `/api/maps/${mapName}/radar.png`;
@@ -8,18 +13,57 @@ function radarUrl(mapName: string): string {
 
 type State =
   | { step: "loading" }
-  | { step: "ready"; match: MatchData }
+  | { step: "ready"; match: MatchData; parsing: boolean }
   | { step: "error"; message: string };
 
-export default function MatchPage({ params }: { params: { id: string } }) {
+interface MatchPageProps {
+  params: { id: string };
+  // Set when the page is shown by a watch party's session page.
+  party?: WatchParty;
+}
+
+export default function MatchPage({ params, party }: MatchPageProps) {
   const [state, setState] = useState<State>({ step: "loading" });
+  const [, navigate] = useLocation();
+  const [creating, setCreating] = useState(false);
+  const [sessionError, setSessionError] = useState<string | null>(null);
+  const [shareLink, setShareLink] = useState<string | null>(null);
+
+  async function watchTogether() {
+    setCreating(true);
+    setSessionError(null);
+    try {
+      const session = await createSession(params.id);
+      navigate(`/session/${session.id}`);
+    } catch (err) {
+      setCreating(false);
+      setSessionError(err instanceof Error ? err.message : "Failed to create session");
+    }
+  }
+
+  // Creates a read-only link to the match for someone outside the
+  // workspace.
+  async function share() {
+    setSessionError(null);
+    try {
+      const res = await apiFetch(`/api/match/${params.id}/shares`, { method: "POST" });
+      const body = await res.json().catch(() => null);
+      if (!res.ok) throw new Error(body?.error ?? `Failed to share match (${res.status})`);
+      setShareLink(`${window.location.origin}/match/${params.id}?share=${body.token}`);
+    } catch (err) {
+      setSessionError(err instanceof Error ? err.message : "Failed to share match");
+    }
+  }
 
   useEffect(() => {
     let cancelled = false;
+    let stopWatching: (() => void) | null = null;
 
     async function fetchMatch() {
       try {
-        const res = await fetch(`/api/match/${params.id}`);
+        const res = await apiFetch(`/api/match/${params.id}`, {
+          headers: { Accept: `${MATCH_CONTENT_TYPE}, application/json;q=0.9` },
+        });
         if (!res.ok) {
           const body = await res.json().catch(() => null);
           if (!cancelled)
@@ -29,8 +73,29 @@ export default function MatchPage({ params }: { params: { id: string } }) {
             });
           return;
         }
-        const match: MatchData = await res.json();
-        if (!cancelled) setState({ step: "ready", match });
+        const match: MatchData = res.headers.get("Content-Type")?.startsWith(MATCH_CONTENT_TYPE)
+          ? decodeMatch(await res.arrayBuffer())
+          : await res.json();
+        // While the demo is still parsing the server sends the rounds done
+        // so far; fetch again as more are ready.
+        const parsing = res.headers.get("Match-Status") === "parsing";
+        if (cancelled) return;
+        setState({ step: "ready", match, parsing });
+        if (parsing && !stopWatching) {
+          let rounds = match.rounds.length;
+          stopWatching = watchJob(
+            params.id,
+            (job) => {
+              if (job.status === "error") {
+                setState({ step: "error", message: job.error || "Parse failed" });
+              } else if (job.status === "ready" || job.roundsReady > rounds) {
+                rounds = job.roundsReady;
+                fetchMatch();
+              }
+            },
+            () => {}
+          );
+        }
       } catch {
         if (!cancelled)
           setState({ step: "error", message: "Failed to connect to server" });
@@ -40,6 +105,7 @@ export default function MatchPage({ params }: { params: { id: string } }) {
     fetchMatch();
     return () => {
       cancelled = true;
+      stopWatching?.();
     };
   }, [params.id]);
 
@@ -65,9 +131,45 @@ export default function MatchPage({ params }: { params: { id: string } }) {
   }
 
   return (
-    <MatchViewer
-      match={state.match}
-      radarImageUrl={radarUrl(state.match.map)}
-    />
+    <>
+      {state.parsing && (
+        <div className="fixed left-1/2 top-2 z-10 -translate-x-1/2 rounded bg-surface px-3 py-1 text-xs text-text-muted">
+          Still parsing: {state.match.rounds.length} rounds so far
+        </div>
+      )}
+      {!party && (
+        <div className="fixed right-3 top-3 z-10 flex items-center gap-2 text-xs">
+          {sessionError && <span className="text-t">{sessionError}</span>}
+          {shareLink && (
+            <input
+              readOnly
+              value={shareLink}
+              onFocus={(e) => e.target.select()}
+              className="w-64 rounded border border-border bg-surface px-2 py-1 text-text-primary"
+            />
+          )}
+          {getApiToken() && (
+            <button
+              onClick={share}
+              className="rounded border border-border bg-surface px-3 py-1 text-text-muted hover:text-text-primary"
+            >
+              Share
+            </button>
+          )}
+          <button
+            onClick={watchTogether}
+            disabled={creating}
+            className="rounded border border-border bg-surface px-3 py-1 text-text-muted hover:text-text-primary disabled:opacity-50"
+          >
+            Watch together
+          </button>
+        </div>
+      )}
+      <MatchViewer
+        match={state.match}
+        radarImageUrl={radarUrl(state.match.map)}
+        party={party}
+      />
+    </>
   );
 }
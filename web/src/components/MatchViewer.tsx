@@ -1,25 +1,94 @@
-import { useMemo, useEffect, useCallback } from "react";
+import { useMemo, useEffect, useCallback, useRef } from "react";
 import type { MatchData } from "../types/match";
-import { usePlayback } from "../hooks/usePlayback";
+import { usePlayback, type PlaybackControls as Controls, type PlaybackPosition } from "../hooks/usePlayback";
 import { formatTime, ROUND_TIME } from "../utils/format";
 import { getActiveGrenades } from "../utils/grenade";
+import { needsSync } from "../utils/watchSession";
 import MapCanvas from "./MapCanvas";
 import TeamPanel from "./TeamPanel";
 import RoundSelector from "./RoundSelector";
 import PlaybackControls from "./PlaybackControls";
 import KillFeed from "./KillFeed";
 
+// Set when the match is watched in a watch party.
+export interface WatchParty {
+  isHost: boolean;
+  // The host's latest position, which followers move to.
+  remote: PlaybackPosition | null;
+  // Called with the host's position when it changes, and periodically
+  // while watching so followers that drift are corrected.
+  onChange: (position: PlaybackPosition) => void;
+}
+
 interface MatchViewerProps {
   match: MatchData;
   radarImageUrl: string;
+  party?: WatchParty;
 }
 
 const MAP_SIZE = 700;
-
-export default function MatchViewer({ match, radarImageUrl }: MatchViewerProps) {
+const HEARTBEAT_MS = 5000;
+
+const noop = () => {};
+
+// Followers can't control playback themselves.
+const LOCKED: Controls = {
+  play: noop,
+  pause: noop,
+  togglePlay: noop,
+  setSpeed: noop,
+  cycleSpeed: noop,
+  setRound: noop,
+  seek: noop,
+  sync: noop,
+};
+
+export default function MatchViewer({ match, radarImageUrl, party }: MatchViewerProps) {
   const [playback, controls] = usePlayback(match.rounds);
   const round = match.rounds[playback.roundIndex];
 
+  const hosting = party?.isHost === true;
+  const following = party !== undefined && !party.isHost;
+
+  const position = useRef<PlaybackPosition>(null!);
+  position.current = {
+    roundIndex: playback.roundIndex,
+    time: playback.currentTime,
+    playing: playback.isPlaying,
+    speed: playback.speed,
+  };
+
+  const { sync, seek: seekTo } = controls;
+  const remote = party?.remote;
+  useEffect(() => {
+    if (following && remote && needsSync(position.current, remote)) sync(remote);
+  }, [following, remote, sync]);
+
+  const onChange = party?.onChange;
+  useEffect(() => {
+    if (!hosting || !onChange) return;
+    onChange(position.current);
+    const heartbeat = setInterval(() => onChange(position.current), HEARTBEAT_MS);
+    return () => clearInterval(heartbeat);
+  }, [hosting, onChange, playback.isPlaying, playback.speed, playback.roundIndex]);
+
+  // Seeking doesn't change anything the effect above watches, so the host
+  // reports it directly.
+  const { roundDuration } = playback;
+  const seek = useCallback(
+    (time: number) => {
+      seekTo(time);
+      if (hosting) onChange?.({ ...position.current, time: Math.max(0, Math.min(time, roundDuration)) });
+    },
+    [seekTo, hosting, onChange, roundDuration]
+  );
+
+  const { play, pause, togglePlay, setSpeed, cycleSpeed, setRound } = controls;
+  const input = useMemo<Controls>(
+    () => (following ? LOCKED : { play, pause, togglePlay, setSpeed, cycleSpeed, setRound, seek, sync }),
+    [following, play, pause, togglePlay, setSpeed, cycleSpeed, setRound, seek, sync]
+  );
+
   const ctPlayers = useMemo(
     () => playback.players.filter((p) => p.team === "ct"),
     [playback.players]
@@ -61,30 +130,30 @@ export default function MatchViewer({ match, radarImageUrl }: MatchViewerProps)
       switch (e.key) {
         case " ":
           e.preventDefault();
-          controls.togglePlay();
+          input.togglePlay();
           break;
         case "ArrowLeft":
           e.preventDefault();
-          controls.seek(playback.currentTime - 5);
+          input.seek(playback.currentTime - 5);
           break;
         case "ArrowRight":
           e.preventDefault();
-          controls.seek(playback.currentTime + 5);
+          input.seek(playback.currentTime + 5);
           break;
         case "ArrowUp":
           e.preventDefault();
-          controls.setRound(playback.roundIndex - 1);
+          input.setRound(playback.roundIndex - 1);
           break;
         case "ArrowDown":
           e.preventDefault();
-          controls.setRound(playback.roundIndex + 1);
+          input.setRound(playback.roundIndex + 1);
           break;
         case ".":
-          controls.cycleSpeed();
+          input.cycleSpeed();
           break;
       }
     },
-    [controls, playback.currentTime, playback.roundIndex]
+    [input, playback.currentTime, playback.roundIndex]
   );
 
   useEffect(() => {
@@ -130,7 +199,7 @@ export default function MatchViewer({ match, radarImageUrl }: MatchViewerProps)
         <RoundSelector
           rounds={match.rounds}
           currentIndex={playback.roundIndex}
-          onSelect={controls.setRound}
+          onSelect={input.setRound}
         />
         <PlaybackControls
           isPlaying={playback.isPlaying}
@@ -139,9 +208,9 @@ export default function MatchViewer({ match, radarImageUrl }: MatchViewerProps)
           duration={playback.roundDuration}
           kills={round?.kills ?? []}
           players={playback.players}
-          onTogglePlay={controls.togglePlay}
-          onSetSpeed={controls.setSpeed}
-          onSeek={controls.seek}
+          onTogglePlay={input.togglePlay}
+          onSetSpeed={input.setSpeed}
+          onSeek={input.seek}
         />
       </div>
     </div>
@@ -0,0 +1,103 @@
+import { useState, useEffect, useCallback, useRef } from "react";
+import type { PlaybackPosition } from "../hooks/usePlayback";
+import {
+  connectSession,
+  fetchSession,
+  hostToken,
+  toPosition,
+  type SessionConnection,
+  type WatchSession,
+} from "../utils/watchSession";
+import MatchPage from "./MatchPage";
+
+type State =
+  | { step: "loading" }
+  | { step: "joined"; session: WatchSession }
+  | { step: "error"; message: string };
+
+// A watch party: the session's match, with playback following the host.
+// Whoever created the session hosts it from the same browser; everyone
+// else joins through the link.
+export default function SessionPage({ params }: { params: { id: string } }) {
+  const [state, setState] = useState<State>({ step: "loading" });
+  const [remote, setRemote] = useState<PlaybackPosition | null>(null);
+  const [viewers, setViewers] = useState(0);
+  const [copied, setCopied] = useState(false);
+  const connection = useRef<SessionConnection | null>(null);
+  const token = hostToken(params.id);
+
+  useEffect(() => {
+    let cancelled = false;
+
+    fetchSession(params.id).then(
+      (session) => {
+        if (cancelled) return;
+        setState({ step: "joined", session });
+        setRemote(toPosition(session.state));
+        connection.current = connectSession(
+          params.id,
+          token,
+          (msg) => {
+            if (msg.type === "state") setRemote(toPosition(msg.state));
+            else if (msg.type === "viewers") setViewers(msg.viewers ?? 0);
+          },
+          () => setState({ step: "error", message: "Lost connection to the session" })
+        );
+      },
+      (err: Error) => {
+        if (!cancelled) setState({ step: "error", message: err.message });
+      }
+    );
+
+    return () => {
+      cancelled = true;
+      connection.current?.close();
+      connection.current = null;
+    };
+  }, [params.id, token]);
+
+  const onChange = useCallback((position: PlaybackPosition) => {
+    connection.current?.send(position);
+  }, []);
+
+  async function copyLink() {
+    await navigator.clipboard.writeText(window.location.href);
+    setCopied(true);
+    setTimeout(() => setCopied(false), 2000);
+  }
+
+  if (state.step === "loading") {
+    return (
+      <div className="flex h-screen items-center justify-center bg-bg text-text-primary">
+        <p className="text-lg">Joining session...</p>
+      </div>
+    );
+  }
+
+  if (state.step === "error") {
+    return (
+      <div className="flex h-screen items-center justify-center bg-bg text-text-primary">
+        <div className="text-center">
+          <p className="text-lg text-t">{state.message}</p>
+          <a href="/" className="mt-4 inline-block text-sm text-text-muted hover:text-text-primary">
+            Back
+          </a>
+        </div>
+      </div>
+    );
+  }
+
+  const isHost = token !== null;
+  return (
+    <>
+      <div className="fixed right-3 top-3 z-10 flex items-center gap-3 rounded bg-surface px-3 py-1 text-xs text-text-muted">
+        <span>{isHost ? "Hosting" : "Following the host"}</span>
+        <span>{viewers} watching</span>
+        <button onClick={copyLink} className="hover:text-text-primary">
+          {copied ? "Copied" : "Copy link"}
+        </button>
+      </div>
+      <MatchPage params={{ id: state.session.matchId }} party={{ isHost, remote, onChange }} />
+    </>
+  );
+}
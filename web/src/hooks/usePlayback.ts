@@ -20,6 +20,15 @@ export interface PlaybackControls {
   cycleSpeed: () => void;
   setRound: (index: number) => void;
   seek: (time: number) => void;
+  // Jumps straight to a position, e.g. one set by a watch-party host.
+  sync: (position: PlaybackPosition) => void;
+}
+
+export interface PlaybackPosition {
+  roundIndex: number;
+  time: number;
+  playing: boolean;
+  speed: number;
 }
 
 const SPEEDS = [0.5, 1, 2, 4];
@@ -103,9 +112,20 @@ export function usePlayback(rounds: Round[]): [PlaybackState, PlaybackControls]
     [roundDuration]
   );
 
+  const sync = useCallback(
+    (position: PlaybackPosition) => {
+      const index = Math.max(0, Math.min(position.roundIndex, rounds.length - 1));
+      setRoundIndex(index);
+      setCurrentTime(Math.max(0, position.time));
+      setSpeedState(position.speed);
+      setIsPlaying(position.playing);
+    },
+    [rounds.length]
+  );
+
   return [
     { isPlaying, speed, roundIndex, currentTime, roundDuration, players, currentSnapshot },
-    { play, pause, togglePlay, setSpeed, cycleSpeed, setRound, seek },
+    { play, pause, togglePlay, setSpeed, cycleSpeed, setRound, seek, sync },
   ];
 }
 
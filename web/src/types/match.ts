@@ -10,6 +10,7 @@ export interface TeamInfo {
 export interface TeamPlayer {
   steamId: string;
   name: string;
+  slot?: number;
 }
 
 export interface PlayerState {
@@ -28,7 +29,9 @@ export interface PlayerState {
   grenades: string[];
   hasDefuser: boolean;
   money: number;
+  equipValue: number;
   flashAlpha: number;
+  place?: string;
 }
 
 export interface BombInfo {
@@ -53,10 +56,18 @@ export interface KillEvent {
   weapon: string;
   headshot: boolean;
   wallbang: boolean;
+  noScope: boolean;
+  throughSmoke: boolean;
+  attackerBlind: boolean;
   attackerX: number;
   attackerY: number;
   victimX: number;
   victimY: number;
+  attackerPlace?: string;
+  victimPlace?: string;
+  traded: boolean;
+  tradeTime?: number;
+  refrag: boolean;
 }
 
 export interface TrajectoryPoint {
@@ -84,13 +95,47 @@ export interface GrenadeEvent {
 
 export interface Round {
   number: number;
-  winner: Team;
+  // Empty for a draw, or for a live round still being played.
+  winner: Team | "";
   winReason: WinReason;
   endTScore: number;
   endCTScore: number;
+  endTick?: number;
+  endTime?: number;
+  plant?: BombEvent;
+  defuse?: BombEvent;
+  clutch?: Clutch;
   snapshots: Snapshot[];
   kills: KillEvent[];
   grenades: GrenadeEvent[];
+  winProb?: WinProbPoint[];
+}
+
+export interface BombEvent {
+  tick: number;
+  timeInRound: number;
+  player: string;
+  site: string;
+  x: number;
+  y: number;
+}
+
+export interface Clutch {
+  player: string;
+  side: Team;
+  opponents: number;
+  won: boolean;
+  survived: boolean;
+  kills: number;
+  startTick: number;
+  startTime: number;
+  endTime: number;
+}
+
+export interface WinProbPoint {
+  tick: number;
+  timeInRound: number;
+  ct: number;
 }
 
 export interface MapConfig {
@@ -112,4 +157,13 @@ export interface MatchData {
   };
   rounds: Round[];
   mapConfig: MapConfig;
+  parsedAt: string;
+  options?: ParseOptions;
+}
+
+export interface ParseOptions {
+  snapshotRate: number;
+  postRoundSeconds: number;
+  trajectoryPoints: number;
+  events?: string[];
 }
\ No newline at end of file
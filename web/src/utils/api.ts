@@ -0,0 +1,49 @@
+// Credentials for a server with authentication enabled (GET /api/health
+// reports "auth": true): the workspace token entered on the upload page,
+// kept in this browser, or the token of a share link
+// (/match/{id}?share=...), which only opens that match.
+
+const TOKEN_KEY = "api-token";
+
+export function getApiToken(): string | null {
+  return localStorage.getItem(TOKEN_KEY);
+}
+
+export function setApiToken(token: string | null) {
+  if (token) localStorage.setItem(TOKEN_KEY, token);
+  else localStorage.removeItem(TOKEN_KEY);
+}
+
+// A share link's token takes precedence, so the link opens its match even
+// for someone signed in to another workspace.
+function currentToken(): string | null {
+  return new URLSearchParams(window.location.search).get("share") ?? getApiToken();
+}
+
+// fetch with the current token, if there is one.
+export function apiFetch(path: string, init: RequestInit = {}): Promise<Response> {
+  const token = currentToken();
+  if (!token) return fetch(path, init);
+  const headers = new Headers(init.headers);
+  headers.set("Authorization", `Bearer ${token}`);
+  return fetch(path, { ...init, headers });
+}
+
+// Adds the current token to a URL, for EventSource and WebSocket, which
+// can't send headers.
+export function withToken(url: string): string {
+  const token = currentToken();
+  if (!token) return url;
+  return `${url}${url.includes("?") ? "&" : "?"}token=${encodeURIComponent(token)}`;
+}
+
+// Reports whether the server wants a token.
+export async function authRequired(): Promise<boolean> {
+  try {
+    const res = await fetch("/api/health");
+    const body = await res.json();
+    return body.auth === true;
+  } catch {
+    return false;
+  }
+}
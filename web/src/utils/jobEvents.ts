@@ -0,0 +1,47 @@
+// Follows a parse job over the server's event stream
+// (GET /api/match/{id}/events) instead of polling its status.
+
+import { withToken } from "./api";
+
+export interface ParseJob {
+  id: string;
+  status: "parsing" | "ready" | "error";
+  error?: string;
+  progress: number;
+  roundsReady: number;
+}
+
+const EVENTS = ["status", "progress", "rounds", "error"];
+
+// Calls onUpdate with the job's state whenever it changes, until the job
+// finishes or the returned function is called. onLost is called if the
+// stream can't be opened at all.
+export function watchJob(
+  id: string,
+  onUpdate: (job: ParseJob) => void,
+  onLost: () => void
+): () => void {
+  const source = new EventSource(withToken(`/api/match/${id}/events`));
+  let received = false;
+
+  const handle = (e: MessageEvent) => {
+    received = true;
+    const job: ParseJob = JSON.parse(e.data);
+    onUpdate(job);
+    // The server ends the stream here; closing stops EventSource from
+    // reconnecting.
+    if (job.status !== "parsing") source.close();
+  };
+  for (const name of EVENTS) source.addEventListener(name, handle);
+
+  source.onerror = () => {
+    // EventSource retries dropped streams by itself; only give up if it
+    // never got through.
+    if (!received) {
+      source.close();
+      onLost();
+    }
+  };
+
+  return () => source.close();
+}
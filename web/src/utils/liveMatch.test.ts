@@ -0,0 +1,73 @@
+import { describe, it, expect } from "vitest";
+import { applyLiveMessage, type LiveMessage } from "./liveMatch";
+import type { MatchData, Round, Snapshot } from "../types/match";
+
+const header = {
+  id: "abc",
+  map: "de_mirage",
+  tickRate: 64,
+  duration: 0,
+  teams: { ct: { name: "", players: null }, t: { name: "", players: null } },
+  rounds: null,
+  parsedAt: "",
+} as unknown as MatchData;
+
+function snapshot(tick: number): Snapshot {
+  return { tick, timeInRound: tick / 64, bomb: { x: 0, y: 0, state: "carried", carrier: null }, players: [] };
+}
+
+function completed(number: number, winner: "ct" | "t", ct: number, t: number): Round {
+  return {
+    number,
+    winner,
+    winReason: "elimination",
+    endCTScore: ct,
+    endTScore: t,
+    snapshots: [snapshot(1), snapshot(2)],
+    kills: [],
+    grenades: [],
+  };
+}
+
+function apply(...messages: LiveMessage[]): MatchData | null {
+  return messages.reduce<MatchData | null>(applyLiveMessage, null);
+}
+
+describe("applyLiveMessage", () => {
+  it("ignores events before the match", () => {
+    expect(apply({ type: "snapshot", round: 1, data: snapshot(1) })).toBeNull();
+  });
+
+  it("fills in missing lists from the match header", () => {
+    const match = apply({ type: "match", data: header })!;
+    expect(match.rounds).toEqual([]);
+    expect(match.teams.ct.players).toEqual([]);
+  });
+
+  it("builds the round in progress from its events", () => {
+    const match = apply(
+      { type: "match", data: { ...header, rounds: [completed(1, "t", 0, 1)] } },
+      { type: "snapshot", round: 2, data: snapshot(10) },
+      { type: "snapshot", round: 2, data: snapshot(20) }
+    )!;
+    expect(match.rounds).toHaveLength(2);
+    const live = match.rounds[1]!;
+    expect(live.number).toBe(2);
+    expect(live.winner).toBe("");
+    expect(live.endTScore).toBe(1);
+    expect(live.snapshots.map((s) => s.tick)).toEqual([10, 20]);
+  });
+
+  it("replaces the live round once it completes", () => {
+    const match = apply(
+      { type: "match", data: header },
+      { type: "snapshot", round: 1, data: snapshot(10) },
+      { type: "round", round: 1, data: completed(1, "ct", 1, 0) },
+      // A late event for a completed round is dropped.
+      { type: "snapshot", round: 1, data: snapshot(30) }
+    )!;
+    expect(match.rounds).toHaveLength(1);
+    expect(match.rounds[0]!.winner).toBe("ct");
+    expect(match.rounds[0]!.snapshots).toHaveLength(2);
+  });
+});
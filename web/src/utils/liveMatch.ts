@@ -0,0 +1,75 @@
+// Builds the match a live viewer sees from the server's live messages
+// (GET /api/live/{id}/ws): the completed rounds, plus the round being
+// played assembled from its snapshots, kills and grenades as they arrive.
+
+import type { GrenadeEvent, KillEvent, MatchData, Round, Snapshot } from "../types/match";
+import type { ParseJob } from "./jobEvents";
+
+export type LiveMessage =
+  | { type: "match"; data: MatchData }
+  | { type: "snapshot"; round: number; data: Snapshot }
+  | { type: "kill"; round: number; data: KillEvent }
+  | { type: "grenade"; round: number; data: GrenadeEvent }
+  | { type: "round"; round: number; data: Round }
+  | { type: "end"; data: ParseJob };
+
+// The round in progress, started from the score of the one before it.
+function liveRound(number: number, previous: Round | undefined): Round {
+  return {
+    number,
+    winner: "",
+    winReason: "time",
+    endCTScore: previous?.endCTScore ?? 0,
+    endTScore: previous?.endTScore ?? 0,
+    snapshots: [],
+    kills: [],
+    grenades: [],
+  };
+}
+
+// Returns match with an event added to round number, creating that round
+// if it's the first event of a new one. Events for rounds the server has
+// already sent complete are dropped.
+function addToRound(match: MatchData, number: number, update: (round: Round) => Round): MatchData {
+  const rounds = match.rounds;
+  const last = rounds[rounds.length - 1];
+  if (last && last.number > number) return match;
+  if (last && last.number === number) {
+    if (last.winner !== "") return match;
+    return { ...match, rounds: [...rounds.slice(0, -1), update(last)] };
+  }
+  return { ...match, rounds: [...rounds, update(liveRound(number, last))] };
+}
+
+// Applies a message to the match so far, returning the new match. Nothing
+// is shown until the "match" message, which the server sends first.
+export function applyLiveMessage(match: MatchData | null, msg: LiveMessage): MatchData | null {
+  if (msg.type === "match") {
+    const data = msg.data;
+    return {
+      ...data,
+      rounds: data.rounds ?? [],
+      teams: {
+        ct: { ...data.teams.ct, players: data.teams.ct.players ?? [] },
+        t: { ...data.teams.t, players: data.teams.t.players ?? [] },
+      },
+    };
+  }
+  if (!match) return null;
+
+  switch (msg.type) {
+    case "snapshot":
+      return addToRound(match, msg.round, (r) => ({ ...r, snapshots: [...r.snapshots, msg.data] }));
+    case "kill":
+      return addToRound(match, msg.round, (r) => ({ ...r, kills: [...r.kills, msg.data] }));
+    case "grenade":
+      return addToRound(match, msg.round, (r) => ({ ...r, grenades: [...r.grenades, msg.data] }));
+    case "round": {
+      // Replaces the live version of the round with the complete one.
+      const rounds = match.rounds.filter((r) => r.number !== msg.round);
+      return { ...match, rounds: [...rounds, msg.data] };
+    }
+    default:
+      return match;
+  }
+}
@@ -0,0 +1,48 @@
+import { describe, it, expect } from "vitest";
+import { decodeMatch } from "./matchCodec";
+
+// Written by internal/codec.Marshal: one round, two snapshots of one player,
+// with a bomb in the second.
+const FIXTURE =
+  "Q1MyTQEHAAVhbHBoYQJjdAVVU1AtUwdDVFNwYXduCUZsYXNoYmFuZwdkcm9wcGVkAYGY+ZKQgICIAQGnAnsiaWQiOiJmaXh0dXJlIiwibWFwIjoiZGVfZHVzdDIiLCJ0aWNrUmF0ZSI6NjQsImR1cmF0aW9uIjowLCJ0ZWFtcyI6eyJjdCI6eyJuYW1lIjoiIiwicGxheWVycyI6bnVsbH0sInQiOnsibmFtZSI6IiIsInBsYXllcnMiOm51bGx9fSwicm91bmRzIjpbeyJudW1iZXIiOjEsIndpbm5lciI6ImN0Iiwid2luUmVhc29uIjoiIiwiZW5kVFNjb3JlIjowLCJlbmRDVFNjb3JlIjowLCJzbmFwc2hvdHMiOm51bGwsImtpbGxzIjpudWxsLCJncmVuYWRlcyI6bnVsbH1dLCJwYXJzZWRBdCI6IjAwMDEtMDEtMDFUMDA6MDA6MDBaIn0CgAoAAAEAp08C2g+lH8gBAgMBBcAMBBqWAw8GFCgBAKIEyAE1AA==";
+
+function fixture(): ArrayBuffer {
+  return Uint8Array.from(atob(FIXTURE), (c) => c.charCodeAt(0)).buffer;
+}
+
+describe("decodeMatch", () => {
+  it("decodes the JSON header", () => {
+    const match = decodeMatch(fixture());
+    expect(match.id).toBe("fixture");
+    expect(match.map).toBe("de_dust2");
+    expect(match.rounds).toHaveLength(1);
+    expect(match.rounds[0].winner).toBe("ct");
+  });
+
+  it("applies per-player deltas across snapshots", () => {
+    const [first, second] = decodeMatch(fixture()).rounds[0].snapshots;
+    expect(first.tick).toBe(640);
+    expect(second.tick).toBe(653);
+    expect(second.timeInRound).toBeCloseTo(0.203);
+
+    expect(first.players[0]).toMatchObject({ name: "alpha", team: "ct", x: 100.5, y: -200.3, hp: 100, weapon: "USP-S", money: 800, place: "CTSpawn" });
+    expect(first.players[0].grenades).toEqual(["Flashbang"]);
+    expect(second.players[0]).toMatchObject({ x: 110.5, y: -200.3, hp: 73, isAlive: true, place: "CTSpawn" });
+    expect(second.players[0].grenades).toBeNull();
+  });
+
+  it("decodes steam IDs the same way JSON.parse does", () => {
+    const player = decodeMatch(fixture()).rounds[0].snapshots[0].players[0];
+    expect(player.steamId).toBe(JSON.parse("76561198000000001"));
+  });
+
+  it("decodes the bomb only where present", () => {
+    const [first, second] = decodeMatch(fixture()).rounds[0].snapshots;
+    expect(first.bomb).toBeUndefined();
+    expect(second.bomb).toEqual({ x: 1, y: 2, state: "dropped" });
+  });
+
+  it("rejects other data", () => {
+    expect(() => decodeMatch(new TextEncoder().encode("{}").buffer)).toThrow();
+  });
+});
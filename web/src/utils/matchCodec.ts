@@ -0,0 +1,242 @@
+import type { BombInfo, MatchData, PlayerState, Snapshot } from "../types/match";
+
+// Decoder for the server's compact binary match format (internal/codec).
+// It produces the same shape as the JSON endpoint.
+
+export const MATCH_CONTENT_TYPE = "application/vnd.cs2demo.match";
+
+const MAGIC = "CS2M";
+const VERSION = 1;
+const POS_SCALE = 10;
+const TIME_SCALE = 1000;
+
+const FIELD_TEAM = 1 << 0;
+const FIELD_X = 1 << 1;
+const FIELD_Y = 1 << 2;
+const FIELD_Z = 1 << 3;
+const FIELD_YAW = 1 << 4;
+const FIELD_HP = 1 << 5;
+const FIELD_ARMOR = 1 << 6;
+const FIELD_FLAGS = 1 << 7;
+const FIELD_WEAPON = 1 << 8;
+const FIELD_GRENADES = 1 << 9;
+const FIELD_MONEY = 1 << 10;
+const FIELD_EQUIP_VALUE = 1 << 11;
+const FIELD_FLASH = 1 << 12;
+const FIELD_PLACE = 1 << 13;
+
+const BOMB_PRESENT = 1 << 0;
+const BOMB_STATE = 1 << 1;
+const BOMB_X = 1 << 2;
+const BOMB_Y = 1 << 3;
+const BOMB_CARRIER = 1 << 4;
+
+const FLAG_HELMET = 1 << 0;
+const FLAG_ALIVE = 1 << 1;
+const FLAG_DEFUSER = 1 << 2;
+
+class Reader {
+  private pos = 0;
+  private strings: string[] = [];
+
+  constructor(private readonly data: Uint8Array) {}
+
+  byte(): number {
+    if (this.pos >= this.data.length) throw new Error("corrupt match data");
+    return this.data[this.pos++];
+  }
+
+  uvarint(): number {
+    let result = 0;
+    let scale = 1;
+    for (;;) {
+      const b = this.byte();
+      result += (b & 0x7f) * scale;
+      if ((b & 0x80) === 0) return result;
+      scale *= 128;
+    }
+  }
+
+  // Steam IDs need all 64 bits. Converting from a BigInt rounds the same way
+  // JSON.parse does, so IDs decoded here match those in the JSON header.
+  steamId(): string {
+    let result = 0n;
+    let shift = 0n;
+    for (;;) {
+      const b = this.byte();
+      result |= BigInt(b & 0x7f) << shift;
+      if ((b & 0x80) === 0) return Number(result) as unknown as string;
+      shift += 7n;
+    }
+  }
+
+  varint(): number {
+    const u = this.uvarint();
+    return u % 2 === 0 ? u / 2 : -(u + 1) / 2;
+  }
+
+  bytes(n: number): Uint8Array {
+    if (this.pos + n > this.data.length) throw new Error("corrupt match data");
+    const out = this.data.subarray(this.pos, this.pos + n);
+    this.pos += n;
+    return out;
+  }
+
+  setStrings(strings: string[]) {
+    this.strings = strings;
+  }
+
+  str(): string {
+    const i = this.uvarint();
+    if (i >= this.strings.length) throw new Error("corrupt match data");
+    return this.strings[i];
+  }
+}
+
+interface PlayerEntry {
+  steamId: string;
+  name: string;
+}
+
+interface Quantized {
+  state: PlayerState;
+  x: number;
+  y: number;
+  z: number;
+  yaw: number;
+  flash: number;
+}
+
+export function decodeMatch(buffer: ArrayBuffer): MatchData {
+  const data = new Uint8Array(buffer);
+  const text = new TextDecoder();
+  if (text.decode(data.subarray(0, MAGIC.length)) !== MAGIC) {
+    throw new Error("not a binary match");
+  }
+  const r = new Reader(data.subarray(MAGIC.length));
+  const version = r.uvarint();
+  if (version !== VERSION) throw new Error(`unsupported match format version ${version}`);
+
+  const strings: string[] = [];
+  for (let n = r.uvarint(); n > 0; n--) {
+    strings.push(text.decode(r.bytes(r.uvarint())));
+  }
+  r.setStrings(strings);
+
+  const players: PlayerEntry[] = [];
+  for (let n = r.uvarint(); n > 0; n--) {
+    const steamId = r.steamId();
+    players.push({ steamId, name: r.str() });
+  }
+
+  const match: MatchData = JSON.parse(text.decode(r.bytes(r.uvarint())));
+  for (const round of match.rounds) {
+    round.snapshots = decodeRound(r, players);
+  }
+  return match;
+}
+
+function decodeRound(r: Reader, players: PlayerEntry[]): Snapshot[] {
+  const count = r.uvarint();
+  const snapshots: Snapshot[] = [];
+  const prev = new Map<number, Quantized>();
+  const bomb = { x: 0, y: 0, state: "", carrier: "" };
+  let tick = 0;
+  let time = 0;
+
+  for (let i = 0; i < count; i++) {
+    tick += r.varint();
+    time += r.varint();
+    const snap = { tick, timeInRound: time / TIME_SCALE, players: [] } as unknown as Snapshot;
+
+    const mask = r.byte();
+    if (mask & BOMB_PRESENT) {
+      if (mask & BOMB_STATE) bomb.state = r.str();
+      if (mask & BOMB_X) bomb.x += r.varint();
+      if (mask & BOMB_Y) bomb.y += r.varint();
+      if (mask & BOMB_CARRIER) bomb.carrier = r.steamId();
+      const info = { x: bomb.x / POS_SCALE, y: bomb.y / POS_SCALE, state: bomb.state } as BombInfo;
+      if (bomb.carrier) info.carrier = bomb.carrier;
+      snap.bomb = info;
+    }
+
+    for (let n = r.uvarint(); n > 0; n--) {
+      const idx = r.uvarint();
+      const entry = players[idx];
+      if (!entry) throw new Error("corrupt match data");
+      const q = prev.get(idx) ?? emptyState();
+      readPlayer(r, q, r.uvarint());
+      prev.set(idx, q);
+
+      const ps: PlayerState = { ...q.state, steamId: entry.steamId, name: entry.name };
+      ps.grenades = q.state.grenades ? [...q.state.grenades] : (null as unknown as string[]);
+      if (!ps.place) delete ps.place;
+      snap.players.push(ps);
+    }
+    snapshots.push(snap);
+  }
+  return snapshots;
+}
+
+function emptyState(): Quantized {
+  return {
+    state: {
+      steamId: "",
+      name: "",
+      team: "" as PlayerState["team"],
+      x: 0,
+      y: 0,
+      z: 0,
+      yaw: 0,
+      hp: 0,
+      armor: 0,
+      hasHelmet: false,
+      isAlive: false,
+      weapon: "",
+      grenades: null as unknown as string[],
+      hasDefuser: false,
+      money: 0,
+      equipValue: 0,
+      flashAlpha: 0,
+      place: "",
+    },
+    x: 0,
+    y: 0,
+    z: 0,
+    yaw: 0,
+    flash: 0,
+  };
+}
+
+function readPlayer(r: Reader, q: Quantized, mask: number) {
+  const ps = q.state;
+  if (mask & FIELD_TEAM) ps.team = r.str() as PlayerState["team"];
+  if (mask & FIELD_X) q.x += r.varint();
+  if (mask & FIELD_Y) q.y += r.varint();
+  if (mask & FIELD_Z) q.z += r.varint();
+  if (mask & FIELD_YAW) q.yaw += r.varint();
+  if (mask & FIELD_HP) ps.hp += r.varint();
+  if (mask & FIELD_ARMOR) ps.armor += r.varint();
+  if (mask & FIELD_FLAGS) {
+    const flags = r.byte();
+    ps.hasHelmet = (flags & FLAG_HELMET) !== 0;
+    ps.isAlive = (flags & FLAG_ALIVE) !== 0;
+    ps.hasDefuser = (flags & FLAG_DEFUSER) !== 0;
+  }
+  if (mask & FIELD_WEAPON) ps.weapon = r.str();
+  if (mask & FIELD_GRENADES) {
+    const grenades: string[] = [];
+    for (let n = r.uvarint(); n > 0; n--) grenades.push(r.str());
+    ps.grenades = grenades.length > 0 ? grenades : (null as unknown as string[]);
+  }
+  if (mask & FIELD_MONEY) ps.money += r.varint();
+  if (mask & FIELD_EQUIP_VALUE) ps.equipValue += r.varint();
+  if (mask & FIELD_FLASH) q.flash += r.varint();
+  if (mask & FIELD_PLACE) ps.place = r.str();
+
+  ps.x = q.x / POS_SCALE;
+  ps.y = q.y / POS_SCALE;
+  ps.z = q.z / POS_SCALE;
+  ps.yaw = q.yaw / POS_SCALE;
+  ps.flashAlpha = q.flash;
+}
@@ -0,0 +1,108 @@
+// Client for the server's resumable upload API (/api/uploads). Chunks that
+// fail are retried from the offset the server reports, so a dropped
+// connection only costs the chunk in flight.
+
+import { apiFetch } from "./api";
+
+const CHUNK_SIZE = 8 << 20;
+const MAX_RETRIES = 8;
+
+interface UploadState {
+  id: string;
+  size: number;
+  offset: number;
+}
+
+export interface Job {
+  id: string;
+}
+
+async function uploadError(res: Response): Promise<Error> {
+  const body = await res.json().catch(() => null);
+  return new Error(body?.error ?? `Upload failed (${res.status})`);
+}
+
+function sleep(ms: number) {
+  return new Promise((resolve) => setTimeout(resolve, ms));
+}
+
+// How long a rate-limited (429) response asks us to wait, in milliseconds.
+function retryAfter(res: Response): number {
+  const seconds = Number(res.headers.get("Retry-After"));
+  return (Number.isFinite(seconds) && seconds > 0 ? seconds : 5) * 1000;
+}
+
+// Asks the server where to resume, or returns null if it can't be reached.
+async function currentOffset(id: string): Promise<number | null> {
+  try {
+    const res = await apiFetch(`/api/uploads/${id}`);
+    if (res.status === 404) throw new Error("Upload expired, please try again");
+    if (!res.ok) return null;
+    const upload: UploadState = await res.json();
+    return upload.offset;
+  } catch (err) {
+    if (err instanceof Error && err.message.startsWith("Upload expired")) throw err;
+    return null;
+  }
+}
+
+// Uploads a file in chunks and starts parsing it. fields are the same
+// options the regular upload form takes. Resolves with the server's finish
+// response: a job, or { jobs } for a zip of several demos.
+export async function resumableUpload(
+  file: File,
+  fields: Record<string, string>,
+  onProgress: (fraction: number) => void
+): Promise<Job | { jobs: Job[] }> {
+  const created = await apiFetch("/api/uploads", {
+    method: "POST",
+    body: new URLSearchParams({ ...fields, size: String(file.size) }),
+  });
+  if (!created.ok) throw await uploadError(created);
+  const upload: UploadState = await created.json();
+
+  let offset = 0;
+  let retries = 0;
+  while (offset < file.size) {
+    const chunk = file.slice(offset, offset + CHUNK_SIZE);
+    try {
+      const res = await apiFetch(`/api/uploads/${upload.id}`, {
+        method: "PUT",
+        headers: { "Upload-Offset": String(offset) },
+        body: chunk,
+      });
+      if (res.ok || res.status === 409) {
+        const body = await res.json();
+        offset = (res.ok ? body : body.upload).offset;
+        retries = 0;
+        onProgress(offset / file.size);
+        continue;
+      }
+      if (res.status === 429) {
+        await sleep(retryAfter(res));
+        continue;
+      }
+      if (res.status !== 400) throw await uploadError(res);
+    } catch (err) {
+      if (!(err instanceof TypeError)) throw err;
+    }
+
+    // Network error or interrupted chunk: back off and resume from
+    // wherever the server got to.
+    if (++retries > MAX_RETRIES) throw new Error("Upload failed, connection lost");
+    await sleep(Math.min(1000 * 2 ** retries, 30000));
+    offset = (await currentOffset(upload.id)) ?? offset;
+  }
+
+  // The server refuses to start parsing while we have too many demos
+  // parsing already; the upload is kept until one finishes.
+  for (;;) {
+    const finished = await apiFetch(`/api/uploads/${upload.id}/finish`, { method: "POST" });
+    if (finished.status === 429) {
+      await sleep(retryAfter(finished));
+      continue;
+    }
+    if (!finished.ok) throw await uploadError(finished);
+    return finished.json();
+  }
+}
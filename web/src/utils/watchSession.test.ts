@@ -0,0 +1,28 @@
+import { describe, it, expect } from "vitest";
+import { needsSync, toPosition } from "./watchSession";
+
+const host = { roundIndex: 3, time: 20, playing: true, speed: 1 };
+
+describe("needsSync", () => {
+  it("tolerates small drift", () => {
+    expect(needsSync({ ...host, time: 20.5 }, host)).toBe(false);
+    expect(needsSync({ ...host, time: 19.5 }, host)).toBe(false);
+  });
+
+  it("syncs when the follower is too far off", () => {
+    expect(needsSync({ ...host, time: 25 }, host)).toBe(true);
+  });
+
+  it("syncs on any change of round, play state or speed", () => {
+    expect(needsSync({ ...host, roundIndex: 2 }, host)).toBe(true);
+    expect(needsSync({ ...host, playing: false }, host)).toBe(true);
+    expect(needsSync({ ...host, speed: 2 }, host)).toBe(true);
+  });
+});
+
+describe("toPosition", () => {
+  it("maps the session state to a playback position", () => {
+    const state = { round: 4, time: 12.5, playing: false, speed: 2, updatedAt: "" };
+    expect(toPosition(state)).toEqual({ roundIndex: 4, time: 12.5, playing: false, speed: 2 });
+  });
+});
@@ -0,0 +1,118 @@
+// Watch parties: a host's playback followed by everyone else in the session
+// (POST /api/sessions, GET /api/sessions/{id}/ws).
+
+import type { PlaybackPosition } from "../hooks/usePlayback";
+import { apiFetch, withToken } from "./api";
+
+export interface SessionState {
+  round: number;
+  time: number;
+  playing: boolean;
+  speed: number;
+  updatedAt: string;
+}
+
+export interface WatchSession {
+  id: string;
+  matchId: string;
+  createdAt: string;
+  state: SessionState;
+  viewers: number;
+  hostToken?: string;
+}
+
+export type SessionMessage =
+  | { type: "state"; state: SessionState }
+  | { type: "viewers"; viewers?: number }
+  | { type: "error"; error: string };
+
+// How far a follower may drift from the host before it's moved back.
+// Heartbeats from the host arrive every few seconds, and jumping on every
+// one would make playback stutter.
+const DRIFT_TOLERANCE = 0.75;
+
+export function toPosition(state: SessionState): PlaybackPosition {
+  return { roundIndex: state.round, time: state.time, playing: state.playing, speed: state.speed };
+}
+
+// Reports whether a follower at local should jump to the host's remote
+// position.
+export function needsSync(local: PlaybackPosition, remote: PlaybackPosition): boolean {
+  return (
+    local.roundIndex !== remote.roundIndex ||
+    local.playing !== remote.playing ||
+    local.speed !== remote.speed ||
+    Math.abs(local.time - remote.time) > DRIFT_TOLERANCE
+  );
+}
+
+// The host token is kept in the browser that created the session, so
+// reloading the page keeps control.
+function hostTokenKey(id: string): string {
+  return `watch-session-host:${id}`;
+}
+
+export function hostToken(id: string): string | null {
+  return localStorage.getItem(hostTokenKey(id));
+}
+
+export async function createSession(matchId: string): Promise<WatchSession> {
+  const res = await apiFetch("/api/sessions", {
+    method: "POST",
+    body: new URLSearchParams({ match: matchId }),
+  });
+  const body = await res.json().catch(() => null);
+  if (!res.ok) throw new Error(body?.error ?? `Failed to create session (${res.status})`);
+  const session: WatchSession = body;
+  if (session.hostToken) localStorage.setItem(hostTokenKey(session.id), session.hostToken);
+  return session;
+}
+
+export async function fetchSession(id: string): Promise<WatchSession> {
+  const res = await apiFetch(`/api/sessions/${id}`);
+  const body = await res.json().catch(() => null);
+  if (!res.ok) throw new Error(body?.error ?? `Failed to load session (${res.status})`);
+  return body;
+}
+
+export interface SessionConnection {
+  // Sends the host's position; ignored by the server for anyone else.
+  send: (position: PlaybackPosition) => void;
+  close: () => void;
+}
+
+// Joins a session, calling onMessage for everything the server sends and
+// onClose if the connection drops.
+export function connectSession(
+  id: string,
+  token: string | null,
+  onMessage: (msg: SessionMessage) => void,
+  onClose: () => void
+): SessionConnection {
+  const scheme = window.location.protocol === "https:" ? "wss" : "ws";
+  const query = token ? `?host=${encodeURIComponent(token)}` : "";
+  const socket = new WebSocket(withToken(`${scheme}://${window.location.host}/api/sessions/${id}/ws${query}`));
+  let closed = false;
+
+  socket.onmessage = (e) => onMessage(JSON.parse(e.data));
+  socket.onclose = () => {
+    if (!closed) onClose();
+  };
+
+  return {
+    send: (position) => {
+      if (socket.readyState !== WebSocket.OPEN) return;
+      const state = {
+        round: position.roundIndex,
+        time: position.time,
+        playing: position.playing,
+        speed: position.speed,
+      };
+      socket.send(JSON.stringify({ type: "state", state }));
+    },
+    close: () => {
+      closed = true;
+      socket.close();
+    },
+  };
+}